@@ -12,6 +12,7 @@ import (
 func main() {
 	// 解析命令行参数
 	configPath := flag.String("config", "", "配置文件路径")
+	mockUpstream := flag.Bool("mock-upstream", false, "使用内置的上游替身（伪造令牌、固定语音列表、静音音频），无需访问 Microsoft 服务")
 	flag.Parse()
 
 	// 如果没有指定配置文件，尝试默认位置
@@ -46,7 +47,7 @@ func main() {
 	log.Printf("使用配置文件: %s", absConfigPath)
 
 	// 创建并启动应用
-	app, err := server.NewApp(absConfigPath)
+	app, err := server.NewApp(absConfigPath, server.Options{MockUpstream: *mockUpstream})
 	if err != nil {
 		log.Fatalf("初始化应用失败: %v", err)
 	}
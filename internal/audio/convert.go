@@ -0,0 +1,165 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// resampleZeroCrossings 重采样 Lanczos 核单侧的过零点数，越大过渡带越窄
+	resampleZeroCrossings = 16
+	// 支持转换的采样率范围（Hz）
+	minConvertSampleRate = 8000
+	maxConvertSampleRate = 192000
+)
+
+// Convertible 判断能否不借助 ffmpeg 把 PCM / G.711 音频转换为目标格式：
+// 目标为 16 位 PCM 或 G.711 的 WAV / 原始数据，或 16 位 FLAC，声道数为 1 或 2
+func Convertible(f Format) bool {
+	if f.Channels < 1 || f.Channels > 2 {
+		return false
+	}
+	if f.SampleRate < minConvertSampleRate || f.SampleRate > maxConvertSampleRate {
+		return false
+	}
+	if f.Container == ContainerFLAC {
+		return f.Codec == CodecFLAC && f.BitsPerSample == 16
+	}
+	return Processable(f)
+}
+
+// Convert 把 PCM / G.711 音频转换为目标格式，按需混音、重采样并重新编码
+func Convert(data []byte, from, to Format) ([]byte, error) {
+	if !Processable(from) {
+		return nil, fmt.Errorf("不支持转换 %s 格式的音频", from.Name)
+	}
+	if !Convertible(to) {
+		return nil, fmt.Errorf("不支持转换为 %s 格式", to.Name)
+	}
+
+	pcm := data
+	if from.Container == ContainerRIFF {
+		var err error
+		if pcm, err = wavData(data); err != nil {
+			return nil, err
+		}
+	}
+
+	// 在声道数较少的一侧重采样，减少计算量
+	samples := decodeSamples(from, pcm)
+	if to.Channels > from.Channels {
+		samples = Resample(samples, from.Channels, from.SampleRate, to.SampleRate)
+		samples = Remix(samples, from.Channels, to.Channels)
+	} else {
+		samples = Remix(samples, from.Channels, to.Channels)
+		samples = Resample(samples, to.Channels, from.SampleRate, to.SampleRate)
+	}
+
+	switch to.Container {
+	case ContainerFLAC:
+		return EncodeFLAC(toPCM16(samples), to.Channels, to.SampleRate), nil
+	case ContainerRIFF:
+		return WAV(to, encodeSamples(to, samples)), nil
+	}
+	return encodeSamples(to, samples), nil
+}
+
+// Remix 转换交错采样的声道数：单声道复制到各声道，多声道取平均合为单声道
+func Remix(samples []float64, from, to int) []float64 {
+	if from == to || from < 1 || to < 1 {
+		return samples
+	}
+
+	frames := len(samples) / from
+	out := make([]float64, frames*to)
+	for i := 0; i < frames; i++ {
+		var sum float64
+		for ch := 0; ch < from; ch++ {
+			sum += samples[i*from+ch]
+		}
+		for ch := 0; ch < to; ch++ {
+			if from == 1 {
+				out[i*to+ch] = samples[i]
+			} else {
+				out[i*to+ch] = sum / float64(from)
+			}
+		}
+	}
+	return out
+}
+
+// Resample 以加窗 sinc（Lanczos）插值转换交错采样的采样率，降采样时同时低通滤波以避免混叠
+func Resample(samples []float64, channels, from, to int) []float64 {
+	if from == to || from <= 0 || to <= 0 || channels < 1 || len(samples) == 0 {
+		return samples
+	}
+
+	// 输出第 i 个采样对应输入位置 i*down/up，小数部分只有 up 种，预先计算每种相位的滤波系数
+	g := gcd(from, to)
+	up, down := to/g, from/g
+	cutoff := math.Min(1, float64(to)/float64(from))
+	width := int(math.Ceil(resampleZeroCrossings / cutoff))
+
+	kernels := make([][]float64, up)
+	for phase := range kernels {
+		frac := float64(phase) / float64(up)
+		kernel := make([]float64, 2*width)
+		var sum float64
+		for k := range kernel {
+			x := (float64(k-width+1) - frac) * cutoff
+			if math.Abs(x) < resampleZeroCrossings {
+				kernel[k] = sinc(x) * sinc(x/resampleZeroCrossings)
+				sum += kernel[k]
+			}
+		}
+		// 归一化系数和，保证直流增益为 1
+		for k := range kernel {
+			kernel[k] /= sum
+		}
+		kernels[phase] = kernel
+	}
+
+	frames := len(samples) / channels
+	outFrames := int(int64(frames) * int64(up) / int64(down))
+	out := make([]float64, outFrames*channels)
+	for i := 0; i < outFrames; i++ {
+		pos := int64(i) * int64(down)
+		base := int(pos / int64(up))
+		kernel := kernels[pos%int64(up)]
+		// 只使用落在输入范围内的系数
+		first := base - width + 1
+		lo, hi := max(-first, 0), min(frames-first, len(kernel))
+		for ch := 0; ch < channels; ch++ {
+			var sum float64
+			for k := lo; k < hi; k++ {
+				sum += samples[(first+k)*channels+ch] * kernel[k]
+			}
+			out[i*channels+ch] = sum
+		}
+	}
+	return out
+}
+
+// sinc 归一化 sinc 函数 sin(πx)/(πx)
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// toPCM16 把 [-1, 1] 的采样值量化为 16 位整数
+func toPCM16(samples []float64) []int16 {
+	out := make([]int16, len(samples))
+	for i, s := range samples {
+		out[i] = quantize16(s)
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestConvertible(t *testing.T) {
+	tests := []struct {
+		format string
+		want   bool
+	}{
+		{"riff-48khz-16bit-stereo-pcm", true},
+		{"raw-8khz-8bit-mono-alaw", true},
+		{"flac-24khz-16bit-mono-flac", true},
+		{"audio-24khz-48kbitrate-mono-mp3", false},
+		{"ogg-24khz-16bit-mono-opus", false},
+	}
+
+	for _, tt := range tests {
+		if got := Convertible(mustFormat(t, tt.format)); got != tt.want {
+			t.Errorf("Convertible(%s) = %v, want %v", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		from, to string
+	}{
+		{"riff-24khz-16bit-mono-pcm", "riff-48khz-16bit-stereo-pcm"},
+		{"riff-48khz-16bit-stereo-pcm", "raw-16khz-16bit-mono-pcm"},
+		{"raw-8khz-8bit-mono-mulaw", "riff-8khz-8bit-mono-alaw"},
+		{"riff-24khz-16bit-mono-pcm", "flac-48khz-16bit-stereo-flac"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			from, to := mustFormat(t, tt.from), mustFormat(t, tt.to)
+			out, err := Convert(mustSilence(t, from, time.Second), from, to)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if to.Container == ContainerFLAC {
+				info := out[8:42]
+				packed := binary.BigEndian.Uint64(info[10:18])
+				if rate := int(packed >> 44); rate != to.SampleRate {
+					t.Errorf("STREAMINFO sample rate = %d, want %d", rate, to.SampleRate)
+				}
+				if channels := int(packed>>41&0x07) + 1; channels != to.Channels {
+					t.Errorf("STREAMINFO channels = %d, want %d", channels, to.Channels)
+				}
+				if frames := int(packed & (1<<36 - 1)); frames != to.SampleRate {
+					t.Errorf("STREAMINFO samples = %d, want %d", frames, to.SampleRate)
+				}
+				return
+			}
+
+			d, err := Duration(to, out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d != time.Second {
+				t.Errorf("duration = %v, want 1s", d)
+			}
+		})
+	}
+
+	if _, err := Convert(nil, mustFormat(t, "audio-24khz-48kbitrate-mono-mp3"), mustFormat(t, "riff-24khz-16bit-mono-pcm")); err == nil {
+		t.Error("expected an error for an MP3 source")
+	}
+}
+
+func TestResample(t *testing.T) {
+	tests := []struct {
+		channels, from, to int
+	}{
+		{1, 24000, 48000},
+		{1, 48000, 16000},
+		{2, 22050, 44100},
+		{1, 44100, 24000},
+	}
+
+	for _, tt := range tests {
+		frames := tt.from / 10
+		samples := make([]float64, frames*tt.channels)
+		for i := range samples {
+			samples[i] = 0.5
+		}
+
+		out := Resample(samples, tt.channels, tt.from, tt.to)
+		if want := tt.to / 10 * tt.channels; len(out) != want {
+			t.Fatalf("%d→%d: %d samples, want %d", tt.from, tt.to, len(out), want)
+		}
+		// 远离边缘的直流分量保持不变
+		edge := len(out) / 4
+		for i := edge; i < len(out)-edge; i++ {
+			if math.Abs(out[i]-0.5) > 1e-6 {
+				t.Fatalf("%d→%d: sample %d = %v, want 0.5", tt.from, tt.to, i, out[i])
+			}
+		}
+	}
+}
+
+func TestRemix(t *testing.T) {
+	if got := Remix([]float64{0.2, 0.4}, 1, 2); !floatsEqual(got, []float64{0.2, 0.2, 0.4, 0.4}) {
+		t.Errorf("mono to stereo = %v", got)
+	}
+	if got := Remix([]float64{0.2, 0.4, -1, 1}, 2, 1); !floatsEqual(got, []float64{0.3, 0}) {
+		t.Errorf("stereo to mono = %v", got)
+	}
+}
+
+func floatsEqual(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFlacUTF8(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{0x7F, []byte{0x7F}},
+		{0x80, []byte{0xC2, 0x80}},
+		{0x7FF, []byte{0xDF, 0xBF}},
+		{0x800, []byte{0xE0, 0xA0, 0x80}},
+		{0x10000, []byte{0xF0, 0x90, 0x80, 0x80}},
+	}
+
+	for _, tt := range tests {
+		if got := flacUTF8(tt.n); !bytes.Equal(got, tt.want) {
+			t.Errorf("flacUTF8(%#x) = % x, want % x", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestFlacCRC(t *testing.T) {
+	check := []byte("123456789")
+	if got := flacCRC8(check); got != 0xF4 {
+		t.Errorf("CRC-8 = %#x, want 0xf4", got)
+	}
+	if got := flacCRC16(check); got != 0xFEE8 {
+		t.Errorf("CRC-16 = %#x, want 0xfee8", got)
+	}
+}
@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Duration 计算一段完整音频的播放时长
+func Duration(f Format, data []byte) (time.Duration, error) {
+	switch f.Container {
+	case ContainerRaw:
+		return pcmDuration(f, len(data)), nil
+	case ContainerRIFF:
+		samples, err := wavData(data)
+		if err != nil {
+			return 0, err
+		}
+		return pcmDuration(f, len(samples)), nil
+	case ContainerMP3:
+		return mp3Duration(data), nil
+	case ContainerOgg:
+		if f.Codec == CodecOpus {
+			return oggOpusDuration(data)
+		}
+	}
+	return 0, fmt.Errorf("不支持计算 %s 容器 %s 编码的时长", f.Container, f.Codec)
+}
+
+// pcmDuration 按每秒字节数换算 PCM / G.711 数据的时长
+func pcmDuration(f Format, size int) time.Duration {
+	bps := f.BytesPerSecond()
+	if bps == 0 {
+		return 0
+	}
+	return time.Duration(int64(size) * int64(time.Second) / int64(bps))
+}
+
+// mp3Duration 累加 MP3 音频帧的采样数，不计标签和元数据帧
+func mp3Duration(data []byte) time.Duration {
+	var d time.Duration
+	for _, frame := range mp3AudioFrames(data) {
+		_, samples, sampleRate, _ := mp3Frame(frame)
+		d += time.Duration(int64(samples) * int64(time.Second) / int64(sampleRate))
+	}
+	return d
+}
+
+// oggOpusDuration 由最后一页的采样位置减去 OpusHead 中的预跳过采样数得到时长，Opus 采样位置固定按 48kHz 计
+func oggOpusDuration(data []byte) (time.Duration, error) {
+	pages, err := splitOggPages(data)
+	if err != nil {
+		return 0, err
+	}
+
+	var preSkip, granule int64
+	for _, page := range pages {
+		body := page[27+int(page[26]):]
+		if len(body) >= 12 && string(body[:8]) == "OpusHead" {
+			preSkip = int64(binary.LittleEndian.Uint16(body[10:12]))
+		}
+		if g := int64(binary.LittleEndian.Uint64(page[6:14])); g > granule {
+			granule = g
+		}
+	}
+	if granule <= preSkip {
+		return 0, nil
+	}
+	return time.Duration((granule - preSkip) * int64(time.Second) / 48000), nil
+}
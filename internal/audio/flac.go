@@ -0,0 +1,336 @@
+package audio
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+const (
+	// flacBlockSize 每帧的采样数（每声道）
+	flacBlockSize = 4096
+	// flacMaxRiceParam 4 位 Rice 参数的最大值，15 为转义码
+	flacMaxRiceParam = 14
+	// flacMaxFixedOrder 固定预测器的最高阶数
+	flacMaxFixedOrder = 4
+)
+
+// FLAC 声道分配
+const (
+	flacIndependent = 0 // 各声道独立编码，取值为声道数减一
+	flacLeftSide    = 8 // 左声道 + 差值声道
+)
+
+// EncodeFLAC 把交错的 16 位采样编码为 FLAC 文件，每帧按 CONSTANT、VERBATIM
+// 和 0~4 阶固定预测中编码后最短的方式编码各子帧，立体声同时比较左/差值声道
+func EncodeFLAC(samples []int16, channels, sampleRate int) []byte {
+	frames := len(samples) / channels
+	samples = samples[:frames*channels]
+
+	out := make([]byte, 0, len(samples))
+	out = append(out, "fLaC"...)
+	// STREAMINFO 为最后一个元数据块，帧长度等字段在编码完成后回填
+	out = append(out, 0x80, 0, 0, 34)
+	streamInfo := len(out)
+	out = append(out, make([]byte, 34)...)
+
+	minFrame, maxFrame := 0, 0
+	channelData := make([][]int64, channels)
+	for number, start := 0, 0; start < frames; number, start = number+1, start+flacBlockSize {
+		size := min(flacBlockSize, frames-start)
+		for ch := range channelData {
+			channelData[ch] = make([]int64, size)
+			for i := range channelData[ch] {
+				channelData[ch][i] = int64(samples[(start+i)*channels+ch])
+			}
+		}
+
+		frame := flacFrame(number, channelData)
+		if minFrame == 0 || len(frame) < minFrame {
+			minFrame = len(frame)
+		}
+		maxFrame = max(maxFrame, len(frame))
+		out = append(out, frame...)
+	}
+
+	info := out[streamInfo : streamInfo+34]
+	binary.BigEndian.PutUint16(info[0:], flacBlockSize)
+	binary.BigEndian.PutUint16(info[2:], flacBlockSize)
+	putUint24(info[4:], minFrame)
+	putUint24(info[7:], maxFrame)
+	// 采样率 20 位、声道数减一 3 位、位深减一 5 位、总采样数 36 位
+	packed := uint64(sampleRate)<<44 | uint64(channels-1)<<41 | uint64(16-1)<<36 | uint64(frames)
+	binary.BigEndian.PutUint64(info[10:], packed)
+
+	// MD5 按小端交错的原始采样计算
+	pcm := make([]byte, 2*len(samples))
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(s))
+	}
+	sum := md5.Sum(pcm)
+	copy(info[18:], sum[:])
+	return out
+}
+
+// flacFrame 编码一帧，帧头中的块大小和采样率分别从帧头末尾和 STREAMINFO 读取
+func flacFrame(number int, channelData [][]int64) []byte {
+	size := len(channelData[0])
+	assignment := len(channelData) - 1 + flacIndependent
+
+	var subframes bitWriter
+	if len(channelData) == 2 {
+		// 左/差值编码时差值声道需要多一位
+		left, right := channelData[0], channelData[1]
+		side := make([]int64, size)
+		for i := range side {
+			side[i] = left[i] - right[i]
+		}
+		leftBits := flacSubframe(left, 16)
+		rightBits, sideBits := flacSubframe(right, 16), flacSubframe(side, 17)
+		subframes.append(leftBits)
+		if sideBits.len() < rightBits.len() {
+			subframes.append(sideBits)
+			assignment = flacLeftSide
+		} else {
+			subframes.append(rightBits)
+		}
+	} else {
+		for _, data := range channelData {
+			subframes.append(flacSubframe(data, 16))
+		}
+	}
+
+	var w bitWriter
+	w.write(0x3FFE, 14) // 同步码
+	w.write(0, 1)       // 保留位
+	w.write(0, 1)       // 固定块大小
+	w.write(0x7, 4)     // 块大小见帧头末尾的 16 位字段
+	w.write(0, 4)       // 采样率见 STREAMINFO
+	w.write(uint64(assignment), 4)
+	w.write(0x4, 3) // 16 位
+	w.write(0, 1)
+	for _, b := range flacUTF8(uint64(number)) {
+		w.write(uint64(b), 8)
+	}
+	w.write(uint64(size-1), 16)
+	w.write(uint64(flacCRC8(w.bytes())), 8)
+
+	w.append(&subframes)
+	w.alignByte()
+	w.write(uint64(flacCRC16(w.bytes())), 16)
+	return w.bytes()
+}
+
+// flacSubframe 编码一个子帧，选择编码后最短的方式
+func flacSubframe(data []int64, bps int) *bitWriter {
+	constant := true
+	for _, s := range data[1:] {
+		if s != data[0] {
+			constant = false
+			break
+		}
+	}
+	if constant {
+		var w bitWriter
+		w.write(0, 8) // 填充位、类型 000000、无浪费位
+		w.write(uint64(data[0]), bps)
+		return &w
+	}
+
+	// VERBATIM 的长度作为基准
+	bestOrder, bestParam := -1, 0
+	bestBits := len(data) * bps
+	residual := make([]int64, len(data))
+	for order := 0; order <= flacMaxFixedOrder && order < len(data); order++ {
+		fixedResidual(data, order, residual)
+		param, cost := riceParam(residual[order:])
+		cost += order*bps + 6
+		if cost < bestBits {
+			bestOrder, bestParam, bestBits = order, param, cost
+		}
+	}
+
+	var w bitWriter
+	if bestOrder < 0 {
+		w.write(0x02, 8) // 类型 000001
+		for _, s := range data {
+			w.write(uint64(s), bps)
+		}
+		return &w
+	}
+
+	w.write(uint64(0x08|bestOrder)<<1, 8) // 类型 001xxx
+	for _, s := range data[:bestOrder] {
+		w.write(uint64(s), bps)
+	}
+	fixedResidual(data, bestOrder, residual)
+	w.write(0, 2) // 4 位 Rice 参数
+	w.write(0, 4) // 分区阶数 0
+	w.write(uint64(bestParam), 4)
+	for _, r := range residual[bestOrder:] {
+		u := uint64(r<<1 ^ r>>63)
+		w.writeUnary(u >> bestParam)
+		w.write(u, bestParam)
+	}
+	return &w
+}
+
+// fixedResidual 计算固定预测器的残差，前 order 个位置不使用
+func fixedResidual(data []int64, order int, residual []int64) {
+	for i := order; i < len(data); i++ {
+		switch order {
+		case 0:
+			residual[i] = data[i]
+		case 1:
+			residual[i] = data[i] - data[i-1]
+		case 2:
+			residual[i] = data[i] - 2*data[i-1] + data[i-2]
+		case 3:
+			residual[i] = data[i] - 3*data[i-1] + 3*data[i-2] - data[i-3]
+		case 4:
+			residual[i] = data[i] - 4*data[i-1] + 6*data[i-2] - 4*data[i-3] + data[i-4]
+		}
+	}
+}
+
+// riceParam 返回使残差编码最短的 Rice 参数及编码后的位数（含 2 位编码方式、4 位分区阶数和 4 位参数），
+// 最优参数接近残差均值的二进制位数，只比较其附近的几个取值
+func riceParam(residual []int64) (int, int) {
+	var sum uint64
+	for _, r := range residual {
+		sum += uint64(r<<1 ^ r>>63)
+	}
+	estimate := 0
+	if len(residual) > 0 {
+		estimate = bits.Len64(sum / uint64(len(residual)))
+	}
+
+	bestParam, bestBits := 0, math.MaxInt
+	for param := max(estimate-2, 0); param <= min(estimate+1, flacMaxRiceParam); param++ {
+		cost := 10 + len(residual)*(param+1)
+		for _, r := range residual {
+			cost += int(uint64(r<<1^r>>63) >> param)
+		}
+		if cost < bestBits {
+			bestParam, bestBits = param, cost
+		}
+	}
+	return bestParam, bestBits
+}
+
+// flacUTF8 以类 UTF-8 方式编码帧序号
+func flacUTF8(n uint64) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	count := 2
+	for n >= 1<<(5*count+1) {
+		count++
+	}
+	out := make([]byte, count)
+	for i := count - 1; i > 0; i-- {
+		out[i] = 0x80 | byte(n&0x3F)
+		n >>= 6
+	}
+	out[0] = byte(0xFF<<(8-count)) | byte(n)
+	return out
+}
+
+// flacCRC8 计算帧头校验，多项式 x^8+x^2+x+1
+func flacCRC8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// flacCRC16 计算帧校验，多项式 x^16+x^15+x^2+1
+func flacCRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v>>16), byte(v>>8), byte(v)
+}
+
+// bitWriter 按高位在前的顺序写入比特
+type bitWriter struct {
+	buf   []byte
+	nbits int // 最后一个字节中已写入的位数，0 表示已对齐
+}
+
+// write 写入 v 的低 n 位
+func (w *bitWriter) write(v uint64, n int) {
+	for n > 0 {
+		if w.nbits == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		free := 8 - w.nbits
+		take := min(free, n)
+		chunk := v >> (n - take) & (1<<take - 1)
+		w.buf[len(w.buf)-1] |= byte(chunk) << (free - take)
+		w.nbits = (w.nbits + take) % 8
+		n -= take
+	}
+}
+
+// writeUnary 写入 n 个 0 和一个 1
+func (w *bitWriter) writeUnary(n uint64) {
+	for ; n >= 32; n -= 32 {
+		w.write(0, 32)
+	}
+	w.write(1, int(n)+1)
+}
+
+// append 追加另一个 bitWriter 写入的全部比特
+func (w *bitWriter) append(other *bitWriter) {
+	full := len(other.buf)
+	if other.nbits != 0 {
+		full--
+	}
+	if w.nbits == 0 {
+		w.buf = append(w.buf, other.buf[:full]...)
+	} else {
+		for _, b := range other.buf[:full] {
+			w.write(uint64(b), 8)
+		}
+	}
+	if other.nbits != 0 {
+		w.write(uint64(other.buf[full]>>(8-other.nbits)), other.nbits)
+	}
+}
+
+func (w *bitWriter) alignByte() {
+	w.nbits = 0
+}
+
+func (w *bitWriter) len() int {
+	if w.nbits == 0 {
+		return 8 * len(w.buf)
+	}
+	return 8*(len(w.buf)-1) + w.nbits
+}
+
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
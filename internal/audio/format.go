@@ -0,0 +1,123 @@
+package audio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 容器类型
+const (
+	ContainerRaw  = "raw"
+	ContainerRIFF = "riff"
+	ContainerMP3  = "mp3"
+	ContainerOgg  = "ogg"
+	ContainerWebM = "webm"
+	ContainerFLAC = "flac"
+	ContainerM4A  = "m4a"
+	ContainerAAC  = "aac"
+)
+
+// 编码类型
+const (
+	CodecPCM   = "pcm"
+	CodecMulaw = "mulaw"
+	CodecAlaw  = "alaw"
+	CodecMP3   = "mp3"
+	CodecOpus  = "opus"
+	CodecFLAC  = "flac"
+	CodecAAC   = "aac"
+)
+
+// Format 描述一个 Microsoft 风格格式名（如 audio-24khz-48kbitrate-mono-mp3）的音频参数，
+// 也用于描述上游不提供、需要转码的格式（如 flac-48khz-16bit-stereo-flac）
+type Format struct {
+	Name          string
+	Container     string
+	Codec         string
+	SampleRate    int // Hz
+	BitsPerSample int // 仅 PCM / G.711 有效
+	Bitrate       int // kbps，仅 MP3 有效
+	Channels      int
+}
+
+// ParseFormat 解析格式名
+func ParseFormat(name string) (Format, error) {
+	parts := strings.Split(strings.ToLower(name), "-")
+	if len(parts) < 3 {
+		return Format{}, fmt.Errorf("无法解析音频格式: %s", name)
+	}
+
+	f := Format{
+		Name:     name,
+		Codec:    parts[len(parts)-1],
+		Channels: 1,
+	}
+
+	switch parts[0] {
+	case "raw":
+		f.Container = ContainerRaw
+	case "riff":
+		f.Container = ContainerRIFF
+	case "audio":
+		f.Container = ContainerMP3
+	case "ogg":
+		f.Container = ContainerOgg
+	case "webm":
+		f.Container = ContainerWebM
+	case "flac":
+		f.Container = ContainerFLAC
+	case "m4a":
+		f.Container = ContainerM4A
+	case "aac":
+		f.Container = ContainerAAC
+	default:
+		return Format{}, fmt.Errorf("未知的音频容器: %s", name)
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		switch {
+		case part == "mono":
+			f.Channels = 1
+		case part == "stereo":
+			f.Channels = 2
+		case strings.HasSuffix(part, "kbitrate"):
+			f.Bitrate, _ = strconv.Atoi(strings.TrimSuffix(part, "kbitrate"))
+		case strings.HasSuffix(part, "khz"):
+			khz, _ := strconv.Atoi(strings.TrimSuffix(part, "khz"))
+			switch khz {
+			case 22:
+				f.SampleRate = 22050
+			case 44:
+				f.SampleRate = 44100
+			default:
+				f.SampleRate = khz * 1000
+			}
+		case strings.HasSuffix(part, "bit"):
+			f.BitsPerSample, _ = strconv.Atoi(strings.TrimSuffix(part, "bit"))
+		}
+	}
+
+	if f.SampleRate == 0 {
+		return Format{}, fmt.Errorf("音频格式缺少采样率: %s", name)
+	}
+	if f.Codec == CodecMulaw || f.Codec == CodecAlaw {
+		f.BitsPerSample = 8
+	}
+
+	return f, nil
+}
+
+// IsMP3 判断是否为 MP3 格式
+func (f Format) IsMP3() bool {
+	return f.Codec == CodecMP3
+}
+
+// BytesPerSecond 返回 PCM / G.711 数据每秒的字节数，其它编码返回 0
+func (f Format) BytesPerSecond() int {
+	switch f.Codec {
+	case CodecPCM, CodecMulaw, CodecAlaw:
+		return f.SampleRate * f.Channels * f.BitsPerSample / 8
+	}
+	return 0
+}
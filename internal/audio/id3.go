@@ -0,0 +1,117 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+	"unicode/utf16"
+)
+
+// id3MaxChapters CTOC 帧的子元素数只有一个字节
+const id3MaxChapters = 255
+
+// ID3Tag 描述写入 MP3 开头的 ID3v2.3 标签
+type ID3Tag struct {
+	Title     string
+	Artist    string
+	Album     string
+	Cover     []byte // 封面图片，为空时不写入 APIC 帧
+	CoverMIME string // 封面的 MIME 类型，如 image/jpeg
+	Chapters  []Chapter
+}
+
+// Chapter 描述一个章节（CHAP 帧），时间相对音频开头
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+// TagMP3 去掉 MP3 开头已有的 ID3v2 标签，写入新的标签
+func TagMP3(data []byte, tag ID3Tag) []byte {
+	if size := id3v2Size(data); size > 0 && size <= len(data) {
+		data = data[size:]
+	}
+	header := tag.Bytes()
+	out := make([]byte, 0, len(header)+len(data))
+	return append(append(out, header...), data...)
+}
+
+// Bytes 编码 ID3v2.3 标签；章节超过 255 个时只写入前 255 个，最后一个延续到音频结尾
+func (t ID3Tag) Bytes() []byte {
+	var frames bytes.Buffer
+	writeTextFrame(&frames, "TIT2", t.Title)
+	writeTextFrame(&frames, "TPE1", t.Artist)
+	writeTextFrame(&frames, "TALB", t.Album)
+
+	if len(t.Cover) > 0 {
+		var body bytes.Buffer
+		body.WriteByte(0) // ISO-8859-1
+		body.WriteString(t.CoverMIME)
+		body.WriteByte(0)
+		body.WriteByte(0x03) // 封面
+		body.WriteByte(0)    // 空描述
+		body.Write(t.Cover)
+		writeFrame(&frames, "APIC", body.Bytes())
+	}
+
+	chapters := t.Chapters
+	if len(chapters) > id3MaxChapters {
+		last := chapters[len(chapters)-1].End
+		chapters = append([]Chapter(nil), chapters[:id3MaxChapters]...)
+		chapters[len(chapters)-1].End = last
+	}
+	if len(chapters) > 0 {
+		// CTOC：顶层、有序，列出全部章节
+		var toc bytes.Buffer
+		toc.WriteString("toc\x00")
+		toc.WriteByte(0x03)
+		toc.WriteByte(byte(len(chapters)))
+		for i := range chapters {
+			toc.WriteString(chapterID(i) + "\x00")
+		}
+		writeFrame(&frames, "CTOC", toc.Bytes())
+
+		for i, chapter := range chapters {
+			var chap bytes.Buffer
+			chap.WriteString(chapterID(i) + "\x00")
+			binary.Write(&chap, binary.BigEndian, uint32(chapter.Start.Milliseconds()))
+			binary.Write(&chap, binary.BigEndian, uint32(chapter.End.Milliseconds()))
+			// 不提供字节偏移
+			binary.Write(&chap, binary.BigEndian, uint32(0xFFFFFFFF))
+			binary.Write(&chap, binary.BigEndian, uint32(0xFFFFFFFF))
+			writeTextFrame(&chap, "TIT2", chapter.Title)
+			writeFrame(&frames, "CHAP", chap.Bytes())
+		}
+	}
+
+	size := frames.Len()
+	header := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+	return append(header, frames.Bytes()...)
+}
+
+func chapterID(index int) string {
+	return fmt.Sprintf("chp%d", index)
+}
+
+// writeTextFrame 写入 UTF-16（带 BOM）编码的文本帧，文本为空时不写入
+func writeTextFrame(buf *bytes.Buffer, id, text string) {
+	if text == "" {
+		return
+	}
+	body := []byte{1, 0xFF, 0xFE}
+	for _, unit := range utf16.Encode([]rune(text)) {
+		body = binary.LittleEndian.AppendUint16(body, unit)
+	}
+	writeFrame(buf, id, body)
+}
+
+// writeFrame 写入 ID3v2.3 帧，帧长度不使用同步安全整数
+func writeFrame(buf *bytes.Buffer, id string, body []byte) {
+	buf.WriteString(id)
+	binary.Write(buf, binary.BigEndian, uint32(len(body)))
+	buf.Write([]byte{0, 0})
+	buf.Write(body)
+}
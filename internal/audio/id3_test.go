@@ -0,0 +1,235 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// id3Frame 是测试中解析出的 ID3v2.3 帧
+type id3Frame struct {
+	id   string
+	body []byte
+}
+
+func parseID3Frames(t *testing.T, data []byte) []id3Frame {
+	t.Helper()
+	var frames []id3Frame
+	for len(data) > 0 {
+		if len(data) < 10 {
+			t.Fatalf("truncated frame header: % x", data)
+		}
+		size := int(binary.BigEndian.Uint32(data[4:8]))
+		if len(data) < 10+size {
+			t.Fatalf("frame %s size %d exceeds remaining %d bytes", data[:4], size, len(data)-10)
+		}
+		frames = append(frames, id3Frame{id: string(data[:4]), body: data[10 : 10+size]})
+		data = data[10+size:]
+	}
+	return frames
+}
+
+// parseID3Tag 校验标签头并返回顶层帧
+func parseID3Tag(t *testing.T, data []byte) []id3Frame {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte{'I', 'D', '3', 3, 0, 0}) {
+		t.Fatalf("header = % x", data[:6])
+	}
+	for _, b := range data[6:10] {
+		if b&0x80 != 0 {
+			t.Fatalf("size is not syncsafe: % x", data[6:10])
+		}
+	}
+	size := id3v2Size(data)
+	if size != len(data) {
+		t.Fatalf("tag size = %d, want %d", size, len(data))
+	}
+	return parseID3Frames(t, data[10:size])
+}
+
+func id3Text(t *testing.T, body []byte) string {
+	t.Helper()
+	if !bytes.HasPrefix(body, []byte{1, 0xFF, 0xFE}) || len(body)%2 != 1 {
+		t.Fatalf("text frame is not UTF-16 with BOM: % x", body)
+	}
+	units := make([]uint16, 0, len(body)/2)
+	for i := 3; i < len(body); i += 2 {
+		units = append(units, binary.LittleEndian.Uint16(body[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// id3Chapter 解析 CHAP 帧的元素 ID、起止时间和标题
+func id3Chapter(t *testing.T, body []byte) (string, Chapter) {
+	t.Helper()
+	end := bytes.IndexByte(body, 0)
+	if end < 0 || len(body) < end+17 {
+		t.Fatalf("invalid CHAP frame: % x", body)
+	}
+	id := string(body[:end])
+	times := body[end+1:]
+	chapter := Chapter{
+		Start: time.Duration(binary.BigEndian.Uint32(times[0:4])) * time.Millisecond,
+		End:   time.Duration(binary.BigEndian.Uint32(times[4:8])) * time.Millisecond,
+	}
+	if !bytes.Equal(times[8:16], []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}) {
+		t.Fatalf("chapter %s has byte offsets: % x", id, times[8:16])
+	}
+	for _, sub := range parseID3Frames(t, times[16:]) {
+		if sub.id == "TIT2" {
+			chapter.Title = id3Text(t, sub.body)
+		}
+	}
+	return id, chapter
+}
+
+// id3TOC 解析 CTOC 帧的标志和子元素 ID
+func id3TOC(t *testing.T, body []byte) (byte, []string) {
+	t.Helper()
+	if !bytes.HasPrefix(body, []byte("toc\x00")) || len(body) < 6 {
+		t.Fatalf("invalid CTOC frame: % x", body)
+	}
+	count := int(body[5])
+	ids := bytes.Split(bytes.TrimSuffix(body[6:], []byte{0}), []byte{0})
+	if len(ids) != count {
+		t.Fatalf("CTOC lists %d ids, entry count is %d", len(ids), count)
+	}
+	out := make([]string, count)
+	for i, id := range ids {
+		out[i] = string(id)
+	}
+	return body[4], out
+}
+
+func TestID3TagBytes(t *testing.T) {
+	chapters := func(n int) []Chapter {
+		out := make([]Chapter, n)
+		for i := range out {
+			out[i] = Chapter{
+				Title: fmt.Sprintf("第 %d 章", i+1),
+				Start: time.Duration(i) * time.Second,
+				End:   time.Duration(i+1) * time.Second,
+			}
+		}
+		return out
+	}
+	truncated := chapters(id3MaxChapters)
+	truncated[id3MaxChapters-1].End = 300 * time.Second
+
+	tests := []struct {
+		name         string
+		tag          ID3Tag
+		wantText     map[string]string
+		wantCover    bool
+		wantChapters []Chapter
+	}{
+		{
+			name:     "text frames",
+			tag:      ID3Tag{Title: "标题 🎧", Artist: "Xiaoxiao", Album: "Album"},
+			wantText: map[string]string{"TIT2": "标题 🎧", "TPE1": "Xiaoxiao", "TALB": "Album"},
+		},
+		{
+			name:     "empty text is omitted",
+			tag:      ID3Tag{Title: "only title"},
+			wantText: map[string]string{"TIT2": "only title"},
+		},
+		{
+			name:      "cover",
+			tag:       ID3Tag{Cover: []byte{0x89, 'P', 'N', 'G'}, CoverMIME: "image/png"},
+			wantText:  map[string]string{},
+			wantCover: true,
+		},
+		{
+			name:         "chapters",
+			tag:          ID3Tag{Title: "book", Chapters: chapters(3)},
+			wantText:     map[string]string{"TIT2": "book"},
+			wantChapters: chapters(3),
+		},
+		{
+			name:         "chapters beyond the CTOC limit",
+			tag:          ID3Tag{Chapters: chapters(300)},
+			wantText:     map[string]string{},
+			wantChapters: truncated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := map[string]string{}
+			var cover bool
+			var tocIDs []string
+			var got []Chapter
+			for _, frame := range parseID3Tag(t, tt.tag.Bytes()) {
+				switch frame.id {
+				case "TIT2", "TPE1", "TALB":
+					text[frame.id] = id3Text(t, frame.body)
+				case "APIC":
+					want := append([]byte("\x00image/png\x00\x03\x00"), tt.tag.Cover...)
+					if !bytes.Equal(frame.body, want) {
+						t.Fatalf("APIC = % x, want % x", frame.body, want)
+					}
+					cover = true
+				case "CTOC":
+					flags, ids := id3TOC(t, frame.body)
+					if flags != 0x03 {
+						t.Fatalf("CTOC flags = %x, want 3", flags)
+					}
+					tocIDs = ids
+				case "CHAP":
+					id, chapter := id3Chapter(t, frame.body)
+					if want := chapterID(len(got)); id != want {
+						t.Fatalf("chapter id = %s, want %s", id, want)
+					}
+					got = append(got, chapter)
+				default:
+					t.Fatalf("unexpected frame %s", frame.id)
+				}
+			}
+
+			if !reflect.DeepEqual(text, tt.wantText) {
+				t.Errorf("text frames = %v, want %v", text, tt.wantText)
+			}
+			if cover != tt.wantCover {
+				t.Errorf("cover = %v, want %v", cover, tt.wantCover)
+			}
+			if !reflect.DeepEqual(got, tt.wantChapters) {
+				t.Errorf("chapters = %v, want %v", got, tt.wantChapters)
+			}
+			if len(tocIDs) != len(tt.wantChapters) {
+				t.Fatalf("CTOC entries = %d, want %d", len(tocIDs), len(tt.wantChapters))
+			}
+			for i, id := range tocIDs {
+				if id != chapterID(i) {
+					t.Fatalf("CTOC entry %d = %s, want %s", i, id, chapterID(i))
+				}
+			}
+		})
+	}
+}
+
+func TestTagMP3(t *testing.T) {
+	f := mustFormat(t, "audio-24khz-48kbitrate-mono-mp3")
+	frames := mustSilence(t, f, 240*time.Millisecond)
+	tag := ID3Tag{Title: "new"}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"untagged", frames},
+		{"existing tag is replaced", append(ID3Tag{Title: "old", Album: "old"}.Bytes(), frames...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := append(tag.Bytes(), frames...)
+			if got := TagMP3(tt.data, tag); !bytes.Equal(got, want) {
+				t.Fatalf("TagMP3 = %d bytes, want %d", len(got), len(want))
+			}
+		})
+	}
+}
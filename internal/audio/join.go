@@ -0,0 +1,221 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// wavUnknownSize 流式 WAV 文件头中未知的长度字段
+const wavUnknownSize = 0xFFFFFFFF
+
+// Joiner 把同一格式、各自完整的多段音频按顺序拼接成一个可以边写边播的流
+type Joiner struct {
+	format Format
+	count  int
+	next   int
+
+	// Ogg 拼接状态：沿用第一段的流序列号，页序号和采样位置接续累加
+	serial   uint32
+	sequence uint32
+	granule  int64
+}
+
+// Joinable 判断格式是否支持不经转码直接拼接
+func Joinable(f Format) bool {
+	switch f.Container {
+	case ContainerMP3, ContainerRaw, ContainerRIFF:
+		return true
+	case ContainerOgg:
+		return f.Codec == CodecOpus
+	}
+	return false
+}
+
+// NewJoiner 创建拼接 count 段音频的 Joiner，不支持拼接的格式返回错误
+func NewJoiner(f Format, count int) (*Joiner, error) {
+	if !Joinable(f) {
+		return nil, fmt.Errorf("不支持拼接 %s 容器 %s 编码的音频", f.Container, f.Codec)
+	}
+	return &Joiner{format: f, count: count}, nil
+}
+
+// Merge 把各段拼接为一个完整的音频文件，WAV 文件头中的长度按实际数据填写，MP3 写入 Xing/Info 帧
+func Merge(f Format, segments [][]byte) ([]byte, error) {
+	if f.Container == ContainerMP3 {
+		return MergeMP3(segments)
+	}
+
+	j, err := NewJoiner(f, len(segments))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, segment := range segments {
+		data, err := j.Next(segment)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+	}
+
+	merged := buf.Bytes()
+	if f.Container == ContainerRIFF && len(merged) >= 44 {
+		binary.LittleEndian.PutUint32(merged[4:8], uint32(len(merged)-8))
+		binary.LittleEndian.PutUint32(merged[40:44], uint32(len(merged)-44))
+	}
+	return merged, nil
+}
+
+// Next 返回下一段音频应写出的数据，各段必须按顺序传入
+func (j *Joiner) Next(data []byte) ([]byte, error) {
+	if j.next >= j.count {
+		return nil, errors.New("拼接的音频段数超过预期")
+	}
+	index := j.next
+	j.next++
+
+	switch j.format.Container {
+	case ContainerRIFF:
+		return j.nextWAV(index, data)
+	case ContainerOgg:
+		return j.nextOgg(index, data)
+	case ContainerMP3:
+		return j.nextMP3(data), nil
+	}
+	// 原始 PCM / G.711 没有文件头，直接相接即可
+	return data, nil
+}
+
+// nextMP3 只写出音频帧；流式输出时总帧数未知，不写 Xing 帧
+func (j *Joiner) nextMP3(data []byte) []byte {
+	frames := mp3AudioFrames(data)
+	size := 0
+	for _, frame := range frames {
+		size += len(frame)
+	}
+	out := make([]byte, 0, size)
+	for _, frame := range frames {
+		out = append(out, frame...)
+	}
+	return out
+}
+
+// nextWAV 第一段写出长度未知的文件头，之后各段只写出采样数据
+func (j *Joiner) nextWAV(index int, data []byte) ([]byte, error) {
+	samples, err := wavData(data)
+	if err != nil {
+		return nil, fmt.Errorf("第 %d 段: %w", index+1, err)
+	}
+	if index > 0 {
+		return samples, nil
+	}
+
+	header := WAVHeader(waveFormatTag(j.format), j.format.Channels, j.format.SampleRate, j.format.BitsPerSample, 0)
+	binary.LittleEndian.PutUint32(header[4:8], wavUnknownSize)
+	binary.LittleEndian.PutUint32(header[40:44], wavUnknownSize)
+	return append(header, samples...), nil
+}
+
+// nextOgg 把各段合并为一个逻辑流：丢弃后续段的头页，统一流序列号，
+// 接续页序号和采样位置，只保留第一页的 BOS 和最后一页的 EOS 标志
+func (j *Joiner) nextOgg(index int, data []byte) ([]byte, error) {
+	pages, err := splitOggPages(data)
+	if err != nil {
+		return nil, fmt.Errorf("第 %d 段: %w", index+1, err)
+	}
+	if len(pages) == 0 {
+		return nil, nil
+	}
+	if index == 0 {
+		j.serial = binary.LittleEndian.Uint32(pages[0][14:18])
+	}
+	last := index == j.count-1
+
+	out := make([]byte, 0, len(data))
+	var segmentGranule int64
+	for i, page := range pages {
+		granule := int64(binary.LittleEndian.Uint64(page[6:14]))
+		// OpusHead、OpusTags 所在的头页采样位置为 0
+		if index > 0 && granule == 0 {
+			continue
+		}
+
+		page = append([]byte(nil), page...)
+		headerType := page[5] &^ (OggBOS | OggEOS)
+		if index == 0 && i == 0 {
+			headerType |= OggBOS
+		}
+		if last && i == len(pages)-1 {
+			headerType |= OggEOS
+		}
+		page[5] = headerType
+
+		if granule != -1 {
+			if granule > segmentGranule {
+				segmentGranule = granule
+			}
+			binary.LittleEndian.PutUint64(page[6:14], uint64(j.granule+granule))
+		}
+		binary.LittleEndian.PutUint32(page[14:18], j.serial)
+		binary.LittleEndian.PutUint32(page[18:22], j.sequence)
+		j.sequence++
+
+		binary.LittleEndian.PutUint32(page[22:26], 0)
+		binary.LittleEndian.PutUint32(page[22:26], oggCRC(page))
+		out = append(out, page...)
+	}
+	j.granule += segmentGranule
+	return out, nil
+}
+
+// splitOggPages 把 Ogg 数据切分为完整的页
+func splitOggPages(data []byte) ([][]byte, error) {
+	var pages [][]byte
+	for len(data) > 0 {
+		if len(data) < 27 || string(data[:4]) != "OggS" {
+			return nil, errors.New("无效的 Ogg 页")
+		}
+		segments := int(data[26])
+		if len(data) < 27+segments {
+			return nil, errors.New("Ogg 页头不完整")
+		}
+		size := 27 + segments
+		for _, lacing := range data[27 : 27+segments] {
+			size += int(lacing)
+		}
+		if len(data) < size {
+			return nil, errors.New("Ogg 页数据不完整")
+		}
+		pages = append(pages, data[:size])
+		data = data[size:]
+	}
+	return pages, nil
+}
+
+// wavData 返回 WAV 文件中 data 块的采样数据
+func wavData(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("无效的 WAV 文件头")
+	}
+	data = data[12:]
+	for len(data) >= 8 {
+		id := string(data[:4])
+		size := int64(binary.LittleEndian.Uint32(data[4:8]))
+		data = data[8:]
+		if id == "data" {
+			// 流式输出的 WAV 长度字段可能未知，以实际数据为准
+			if size > int64(len(data)) {
+				size = int64(len(data))
+			}
+			return data[:size], nil
+		}
+		if size+size%2 > int64(len(data)) {
+			break
+		}
+		data = data[size+size%2:]
+	}
+	return nil, errors.New("WAV 文件缺少 data 块")
+}
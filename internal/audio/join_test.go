@@ -0,0 +1,230 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func mustFormat(t *testing.T, name string) Format {
+	t.Helper()
+	f, err := ParseFormat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func mustSilence(t *testing.T, f Format, d time.Duration) []byte {
+	t.Helper()
+	data, err := Silence(f, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// oggPageInfo 是测试中检查的 Ogg 页头字段
+type oggPageInfo struct {
+	headerType byte
+	granule    int64
+	serial     uint32
+	sequence   uint32
+	body       []byte
+}
+
+func parseOggPages(t *testing.T, data []byte) []oggPageInfo {
+	t.Helper()
+	pages, err := splitOggPages(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos := make([]oggPageInfo, len(pages))
+	for i, page := range pages {
+		check := append([]byte(nil), page...)
+		binary.LittleEndian.PutUint32(check[22:26], 0)
+		if crc := binary.LittleEndian.Uint32(page[22:26]); crc != oggCRC(check) {
+			t.Fatalf("page %d CRC = %08x, want %08x", i, crc, oggCRC(check))
+		}
+		infos[i] = oggPageInfo{
+			headerType: page[5],
+			granule:    int64(binary.LittleEndian.Uint64(page[6:14])),
+			serial:     binary.LittleEndian.Uint32(page[14:18]),
+			sequence:   binary.LittleEndian.Uint32(page[18:22]),
+			body:       page[27+int(page[26]):],
+		}
+	}
+	return infos
+}
+
+func TestJoinable(t *testing.T) {
+	tests := []struct {
+		format string
+		want   bool
+	}{
+		{"audio-24khz-48kbitrate-mono-mp3", true},
+		{"riff-24khz-16bit-mono-pcm", true},
+		{"raw-8khz-8bit-mono-mulaw", true},
+		{"ogg-24khz-16bit-mono-opus", true},
+		{"webm-24khz-16bit-mono-opus", false},
+		{"flac-24khz-16bit-mono-flac", false},
+	}
+
+	for _, tt := range tests {
+		if got := Joinable(mustFormat(t, tt.format)); got != tt.want {
+			t.Errorf("Joinable(%s) = %v, want %v", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestJoinerPCM(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		wantHeader bool
+	}{
+		{"wav", "riff-16khz-16bit-mono-pcm", true},
+		{"raw pcm", "raw-16khz-16bit-mono-pcm", false},
+		{"raw mulaw", "raw-8khz-8bit-mono-mulaw", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mustFormat(t, tt.format)
+			segments := [][]byte{
+				mustSilence(t, f, 100*time.Millisecond),
+				mustSilence(t, f, 50*time.Millisecond),
+			}
+			j, err := NewJoiner(f, len(segments))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			for _, segment := range segments {
+				data, err := j.Next(segment)
+				if err != nil {
+					t.Fatal(err)
+				}
+				out.Write(data)
+			}
+			if _, err := j.Next(segments[0]); err == nil {
+				t.Fatal("expected an error for an extra segment")
+			}
+
+			samples := out.Bytes()
+			if tt.wantHeader {
+				// 流式 WAV 的长度字段未知
+				if got := binary.LittleEndian.Uint32(samples[4:8]); got != wavUnknownSize {
+					t.Fatalf("RIFF size = %x, want %x", got, wavUnknownSize)
+				}
+				if got := binary.LittleEndian.Uint32(samples[40:44]); got != wavUnknownSize {
+					t.Fatalf("data size = %x, want %x", got, wavUnknownSize)
+				}
+				samples = samples[44:]
+			}
+			if want := f.BytesPerSecond() * 150 / 1000; len(samples) != want {
+				t.Fatalf("sample bytes = %d, want %d", len(samples), want)
+			}
+		})
+	}
+}
+
+func TestJoinerMP3StripsMetadata(t *testing.T) {
+	f := mustFormat(t, "audio-24khz-48kbitrate-mono-mp3")
+	frames := mustSilence(t, f, 240*time.Millisecond)
+
+	tag := ID3Tag{Title: "segment"}.Bytes()
+	id3v1 := append([]byte("TAG"), make([]byte, 125)...)
+	segment := append(append(append([]byte(nil), tag...), frames...), id3v1...)
+
+	j, err := NewJoiner(f, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		data, err := j.Next(segment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, frames) {
+			t.Fatalf("segment %d: got %d bytes, want only the %d bytes of audio frames", i, len(data), len(frames))
+		}
+	}
+}
+
+func TestJoinerOgg(t *testing.T) {
+	f := mustFormat(t, "ogg-24khz-16bit-mono-opus")
+	durations := []time.Duration{time.Second, 1500 * time.Millisecond, 200 * time.Millisecond}
+
+	j, err := NewJoiner(f, len(durations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	var wantGranule int64
+	for _, d := range durations {
+		segment := mustSilence(t, f, d)
+		pages := parseOggPages(t, segment)
+		wantGranule += pages[len(pages)-1].granule
+
+		data, err := j.Next(segment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.Write(data)
+	}
+
+	pages := parseOggPages(t, out.Bytes())
+	heads := 0
+	for i, page := range pages {
+		if page.serial != pages[0].serial {
+			t.Fatalf("page %d serial = %d, want %d", i, page.serial, pages[0].serial)
+		}
+		if page.sequence != uint32(i) {
+			t.Fatalf("page %d sequence = %d", i, page.sequence)
+		}
+		if bos := page.headerType&OggBOS != 0; bos != (i == 0) {
+			t.Fatalf("page %d BOS = %v", i, bos)
+		}
+		if eos := page.headerType&OggEOS != 0; eos != (i == len(pages)-1) {
+			t.Fatalf("page %d EOS = %v", i, eos)
+		}
+		if i > 0 && page.granule < pages[i-1].granule {
+			t.Fatalf("page %d granule %d goes backwards", i, page.granule)
+		}
+		if bytes.HasPrefix(page.body, []byte("OpusHead")) {
+			heads++
+		}
+	}
+	if heads != 1 {
+		t.Fatalf("OpusHead pages = %d, want 1", heads)
+	}
+	if last := pages[len(pages)-1].granule; last != wantGranule {
+		t.Fatalf("final granule = %d, want %d", last, wantGranule)
+	}
+}
+
+func TestJoinerRejectsInvalidSegments(t *testing.T) {
+	tests := []struct {
+		format string
+		data   []byte
+	}{
+		{"riff-16khz-16bit-mono-pcm", []byte("not a wav file")},
+		{"ogg-24khz-16bit-mono-opus", []byte("OggS truncated")},
+	}
+
+	for _, tt := range tests {
+		j, err := NewJoiner(mustFormat(t, tt.format), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := j.Next(tt.data); err == nil {
+			t.Errorf("%s: expected an error", tt.format)
+		}
+	}
+	if _, err := NewJoiner(mustFormat(t, "webm-24khz-16bit-mono-opus"), 1); err == nil {
+		t.Error("webm: expected an error")
+	}
+}
@@ -0,0 +1,87 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		format    string
+		durations []time.Duration
+		want      time.Duration
+	}{
+		{"riff-24khz-16bit-mono-pcm", []time.Duration{time.Second, 500 * time.Millisecond}, 1500 * time.Millisecond},
+		{"riff-8khz-8bit-mono-alaw", []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond}, 300 * time.Millisecond},
+		{"raw-16khz-16bit-mono-pcm", []time.Duration{250 * time.Millisecond, 750 * time.Millisecond}, time.Second},
+		{"raw-8khz-8bit-mono-mulaw", []time.Duration{time.Second}, time.Second},
+		// 各段的 OpusHead 预跳过采样只在合并后的开头跳过一次
+		{"ogg-24khz-16bit-mono-opus", []time.Duration{time.Second, time.Second}, 2*time.Second + opusPreSkip*time.Second/48000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			f := mustFormat(t, tt.format)
+			segments := make([][]byte, len(tt.durations))
+			for i, d := range tt.durations {
+				segments[i] = mustSilence(t, f, d)
+			}
+
+			merged, err := Merge(f, segments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Duration(f, merged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("duration = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeWAVHeader(t *testing.T) {
+	f := mustFormat(t, "riff-16khz-16bit-mono-pcm")
+	first := WAV(f, []byte{1, 0, 2, 0})
+	second := WAV(f, []byte{3, 0})
+
+	merged, err := Merge(f, [][]byte{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := binary.LittleEndian.Uint32(merged[4:8]); got != uint32(len(merged)-8) {
+		t.Fatalf("RIFF size = %d, want %d", got, len(merged)-8)
+	}
+
+	parsed, samples, err := ParseWAV(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(samples, []byte{1, 0, 2, 0, 3, 0}) {
+		t.Fatalf("samples = %v", samples)
+	}
+	if parsed.SampleRate != f.SampleRate || parsed.Channels != 1 || parsed.Codec != CodecPCM {
+		t.Fatalf("format = %+v", parsed)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	tests := []struct {
+		format   string
+		segments [][]byte
+	}{
+		{"webm-24khz-16bit-mono-opus", [][]byte{{0}}},
+		{"riff-16khz-16bit-mono-pcm", [][]byte{[]byte("RIFF")}},
+		{"audio-24khz-48kbitrate-mono-mp3", [][]byte{[]byte("no frames here")}},
+	}
+
+	for _, tt := range tests {
+		if _, err := Merge(mustFormat(t, tt.format), tt.segments); err == nil {
+			t.Errorf("%s: expected an error", tt.format)
+		}
+	}
+}
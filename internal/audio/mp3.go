@@ -0,0 +1,258 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MPEG 版本（帧头中的 2 位编码）
+const (
+	mpegVersion25 = 0
+	mpegVersion2  = 2
+	mpegVersion1  = 3
+)
+
+var (
+	// Layer III 码率表 (kbps)，下标为帧头中的码率索引
+	mpeg1L3Bitrates = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mpeg2L3Bitrates = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+
+	// 采样率表，第一维为 MPEG 版本编码
+	mpegSampleRates = [4][3]int{
+		mpegVersion25: {11025, 12000, 8000},
+		mpegVersion2:  {22050, 24000, 16000},
+		mpegVersion1:  {44100, 48000, 32000},
+	}
+)
+
+// mp3FrameHeader 构造单声道、无 CRC 的 Layer III 帧头
+func mp3FrameHeader(sampleRate, bitrate int) ([4]byte, int, int, error) {
+	var header [4]byte
+
+	version, srIndex := -1, -1
+	for v, rates := range mpegSampleRates {
+		for i, rate := range rates {
+			if rate == sampleRate && rate != 0 {
+				version, srIndex = v, i
+			}
+		}
+	}
+	if version < 0 {
+		return header, 0, 0, fmt.Errorf("MP3 不支持的采样率: %d", sampleRate)
+	}
+
+	bitrates := mpeg2L3Bitrates
+	if version == mpegVersion1 {
+		bitrates = mpeg1L3Bitrates
+	}
+	brIndex := -1
+	for i, br := range bitrates {
+		if br == bitrate && br != 0 {
+			brIndex = i
+		}
+	}
+	if brIndex < 0 {
+		return header, 0, 0, fmt.Errorf("MP3 不支持的码率: %dkbps", bitrate)
+	}
+
+	header[0] = 0xFF
+	header[1] = 0xE0 | byte(version)<<3 | 0x01<<1 | 0x01 // Layer III，无 CRC
+	header[2] = byte(brIndex)<<4 | byte(srIndex)<<2
+	header[3] = 0x03 << 6 // 单声道
+
+	frameSize, samples := 144*bitrate*1000/sampleRate, 1152
+	if version != mpegVersion1 {
+		frameSize, samples = 72*bitrate*1000/sampleRate, 576
+	}
+	return header, frameSize, samples, nil
+}
+
+// mp3Frame 解析 MPEG 音频帧头，返回帧长度、每帧采样数和采样率；不是有效的 Layer III 帧头时 ok 为 false
+func mp3Frame(header []byte) (frameSize, samples, sampleRate int, ok bool) {
+	if len(header) < 4 || header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return 0, 0, 0, false
+	}
+	version := int(header[1]>>3) & 0x03
+	layer := int(header[1]>>1) & 0x03
+	brIndex := int(header[2] >> 4)
+	srIndex := int(header[2]>>2) & 0x03
+	padding := int(header[2]>>1) & 0x01
+	if version == 1 || layer != 0x01 || srIndex == 3 {
+		return 0, 0, 0, false
+	}
+
+	bitrates := mpeg2L3Bitrates
+	if version == mpegVersion1 {
+		bitrates = mpeg1L3Bitrates
+	}
+	bitrate := bitrates[brIndex]
+	if bitrate == 0 {
+		return 0, 0, 0, false
+	}
+
+	sampleRate = mpegSampleRates[version][srIndex]
+	if version == mpegVersion1 {
+		return 144*bitrate*1000/sampleRate + padding, 1152, sampleRate, true
+	}
+	return 72*bitrate*1000/sampleRate + padding, 576, sampleRate, true
+}
+
+// id3v2Size 返回数据开头 ID3v2 标签的长度，没有标签时返回 0
+func id3v2Size(data []byte) int {
+	if len(data) < 10 || string(data[:3]) != "ID3" {
+		return 0
+	}
+	size := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+	if data[5]&0x10 != 0 {
+		size += 10 // 标签尾
+	}
+	return 10 + size
+}
+
+// mp3SideInfoSize 返回帧头之后边信息的长度，Xing/Info 标签紧随其后
+func mp3SideInfoSize(header []byte) int {
+	version := int(header[1]>>3) & 0x03
+	mono := header[3]>>6 == 0x03
+
+	size := 17
+	switch {
+	case version == mpegVersion1 && !mono:
+		size = 32
+	case version != mpegVersion1 && mono:
+		size = 9
+	}
+	if header[1]&0x01 == 0 {
+		size += 2 // CRC
+	}
+	return size
+}
+
+// isMP3InfoFrame 判断帧是否为 Xing/Info 或 VBRI 元数据帧
+func isMP3InfoFrame(frame []byte) bool {
+	offset := 4 + mp3SideInfoSize(frame)
+	if len(frame) >= offset+4 {
+		if tag := string(frame[offset : offset+4]); tag == "Xing" || tag == "Info" {
+			return true
+		}
+	}
+	return len(frame) >= 40 && string(frame[36:40]) == "VBRI"
+}
+
+// mp3AudioFrames 切分出 MP3 数据中的音频帧，跳过 ID3v2、ID3v1 标签、Xing/Info/VBRI 元数据帧
+// 以及无法识别或不完整的数据
+func mp3AudioFrames(data []byte) [][]byte {
+	if len(data) >= 128 && string(data[len(data)-128:len(data)-125]) == "TAG" {
+		data = data[:len(data)-128]
+	}
+
+	var frames [][]byte
+	for pos := id3v2Size(data); pos+4 <= len(data); {
+		frameSize, _, _, ok := mp3Frame(data[pos:])
+		if !ok {
+			pos++
+			continue
+		}
+		if pos+frameSize > len(data) {
+			break
+		}
+		if frame := data[pos : pos+frameSize]; !isMP3InfoFrame(frame) {
+			frames = append(frames, frame)
+		}
+		pos += frameSize
+	}
+	return frames
+}
+
+// MergeMP3 拼接多段 MP3：去掉各段的标签和元数据帧，并在开头写入一个覆盖全部帧的 Xing/Info 帧，
+// 使播放器能得到准确的时长并按时间定位
+func MergeMP3(segments [][]byte) ([]byte, error) {
+	var frames [][]byte
+	for _, segment := range segments {
+		frames = append(frames, mp3AudioFrames(segment)...)
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("没有可合并的 MP3 帧")
+	}
+
+	info, err := xingFrame(frames)
+	if err != nil {
+		return nil, err
+	}
+
+	size := len(info)
+	for _, frame := range frames {
+		size += len(frame)
+	}
+	merged := make([]byte, 0, size)
+	merged = append(merged, info...)
+	for _, frame := range frames {
+		merged = append(merged, frame...)
+	}
+	return merged, nil
+}
+
+// xingFrame 按第一帧的参数构造 Xing/Info 帧，包含帧数、字节数和 100 项定位表；
+// 码率恒定时使用 Info 标识
+func xingFrame(frames [][]byte) ([]byte, error) {
+	const (
+		xingFlags    = 0x07 // 帧数、字节数、定位表
+		xingDataSize = 4 + 4 + 4 + 4 + 100
+	)
+
+	first := frames[0]
+	_, _, sampleRate, _ := mp3Frame(first)
+	version := int(first[1]>>3) & 0x03
+
+	// 复制第一帧的版本、采样率和声道模式，去掉 CRC 和填充，选择能容纳 Xing 数据的最低码率
+	header := [4]byte{first[0], first[1] | 0x01, first[2] & 0x0C, first[3]}
+	offset := 4 + mp3SideInfoSize(header[:])
+	bitrates := mpeg2L3Bitrates
+	if version == mpegVersion1 {
+		bitrates = mpeg1L3Bitrates
+	}
+	frameSize := 0
+	for index, bitrate := range bitrates {
+		if bitrate == 0 {
+			continue
+		}
+		header[2] = first[2]&0x0C | byte(index)<<4
+		size, _, _, _ := mp3Frame(header[:])
+		if size >= offset+xingDataSize {
+			frameSize = size
+			break
+		}
+	}
+	if frameSize == 0 {
+		return nil, fmt.Errorf("无法为采样率 %d 构造 Xing 帧", sampleRate)
+	}
+
+	tag := "Info"
+	audioBytes := 0
+	offsets := make([]int, len(frames))
+	for i, frame := range frames {
+		if frame[2]&0xF0 != first[2]&0xF0 {
+			tag = "Xing"
+		}
+		offsets[i] = frameSize + audioBytes
+		audioBytes += len(frame)
+	}
+	totalBytes := frameSize + audioBytes
+
+	frame := make([]byte, frameSize)
+	copy(frame, header[:])
+	data := frame[offset:]
+	copy(data, tag)
+	binary.BigEndian.PutUint32(data[4:8], xingFlags)
+	binary.BigEndian.PutUint32(data[8:12], uint32(len(frames)))
+	binary.BigEndian.PutUint32(data[12:16], uint32(totalBytes))
+	for i := 0; i < 100; i++ {
+		position := offsets[i*len(frames)/100]
+		entry := position * 256 / totalBytes
+		if entry > 255 {
+			entry = 255
+		}
+		data[16+i] = byte(entry)
+	}
+	return frame, nil
+}
@@ -0,0 +1,163 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// xingInfo 解析合并结果开头的 Xing/Info 帧
+func xingInfo(t *testing.T, data []byte) (tag string, frames, size uint32, toc []byte) {
+	t.Helper()
+	frameSize, _, _, ok := mp3Frame(data)
+	if !ok {
+		t.Fatal("merged data does not start with an MP3 frame")
+	}
+	frame := data[:frameSize]
+	if !isMP3InfoFrame(frame) {
+		t.Fatal("first frame is not a Xing/Info frame")
+	}
+	info := frame[4+mp3SideInfoSize(frame):]
+	if flags := binary.BigEndian.Uint32(info[4:8]); flags != 0x07 {
+		t.Fatalf("Xing flags = %x, want 7", flags)
+	}
+	return string(info[:4]), binary.BigEndian.Uint32(info[8:12]), binary.BigEndian.Uint32(info[12:16]), info[16:116]
+}
+
+// withBitrate 把一段 MP3 中所有帧的码率索引改为 index，用于构造可变码率的输入
+func withBitrate(t *testing.T, data []byte, index byte) []byte {
+	t.Helper()
+	var out []byte
+	for _, frame := range mp3AudioFrames(data) {
+		header := append([]byte(nil), frame[:4]...)
+		header[2] = header[2]&0x0F | index<<4
+		size, _, _, ok := mp3Frame(header)
+		if !ok {
+			t.Fatalf("invalid bitrate index %d", index)
+		}
+		resized := make([]byte, size)
+		copy(resized, header)
+		out = append(out, resized...)
+	}
+	return out
+}
+
+func TestMergeMP3(t *testing.T) {
+	f := mustFormat(t, "audio-24khz-48kbitrate-mono-mp3")
+	plain := mustSilence(t, f, 480*time.Millisecond)
+	tagged := append(ID3Tag{Title: "segment"}.Bytes(), mustSilence(t, f, 240*time.Millisecond)...)
+	remerged, err := MergeMP3([][]byte{plain})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		segments   [][]byte
+		wantTag    string
+		wantFrames uint32
+		wantTime   time.Duration
+	}{
+		{"single segment", [][]byte{plain}, "Info", 20, 480 * time.Millisecond},
+		{"tags are dropped", [][]byte{tagged, plain}, "Info", 30, 720 * time.Millisecond},
+		{"existing Info frame is replaced", [][]byte{remerged, plain}, "Info", 40, 960 * time.Millisecond},
+		{"variable bitrate", [][]byte{plain, withBitrate(t, plain, 10)}, "Xing", 40, 960 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := MergeMP3(tt.segments)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tag, frames, size, toc := xingInfo(t, merged)
+			if tag != tt.wantTag {
+				t.Errorf("tag = %s, want %s", tag, tt.wantTag)
+			}
+			if frames != tt.wantFrames {
+				t.Errorf("frames = %d, want %d", frames, tt.wantFrames)
+			}
+			if size != uint32(len(merged)) {
+				t.Errorf("bytes = %d, want %d", size, len(merged))
+			}
+			if got := len(mp3AudioFrames(merged)); got != int(tt.wantFrames) {
+				t.Errorf("audio frames after merge = %d, want %d", got, tt.wantFrames)
+			}
+			for i := 1; i < len(toc); i++ {
+				if toc[i] < toc[i-1] {
+					t.Fatalf("seek table decreases at %d: %v", i, toc)
+				}
+			}
+
+			d, err := Duration(f, merged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d != tt.wantTime {
+				t.Errorf("duration = %v, want %v", d, tt.wantTime)
+			}
+		})
+	}
+}
+
+func TestMP3AudioFrames(t *testing.T) {
+	f := mustFormat(t, "audio-16khz-32kbitrate-mono-mp3")
+	frames := mustSilence(t, f, 360*time.Millisecond) // 10 帧
+	frameSize, _, _, _ := mp3Frame(frames)
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"plain", frames, 10},
+		{"leading garbage", append([]byte{0x00, 0xFF, 0x12}, frames...), 10},
+		{"id3v2", append(ID3Tag{Album: "album"}.Bytes(), frames...), 10},
+		{"id3v1", append(append([]byte(nil), frames...), append([]byte("TAG"), make([]byte, 125)...)...), 10},
+		{"truncated last frame", frames[:len(frames)-frameSize/2], 9},
+		{"empty", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mp3AudioFrames(tt.data)
+			if len(got) != tt.want {
+				t.Fatalf("frames = %d, want %d", len(got), tt.want)
+			}
+			for _, frame := range got {
+				if !bytes.Equal(frame, frames[:frameSize]) {
+					t.Fatal("frame content changed")
+				}
+			}
+		})
+	}
+}
+
+func TestMP3Frame(t *testing.T) {
+	tests := []struct {
+		header     []byte
+		size       int
+		samples    int
+		sampleRate int
+		ok         bool
+	}{
+		{[]byte{0xFF, 0xFB, 0x90, 0xC0}, 417, 1152, 44100, true}, // MPEG-1 128kbps 44.1kHz
+		{[]byte{0xFF, 0xFB, 0x92, 0xC0}, 418, 1152, 44100, true}, // 带填充
+		{[]byte{0xFF, 0xF3, 0x64, 0xC0}, 144, 576, 24000, true},  // MPEG-2 48kbps 24kHz
+		{[]byte{0xFF, 0xE3, 0x14, 0xC0}, 48, 576, 12000, true},   // MPEG-2.5 8kbps 12kHz
+		{[]byte{0xFF, 0xFD, 0x90, 0xC0}, 0, 0, 0, false},         // Layer II
+		{[]byte{0xFF, 0xFB, 0xF0, 0xC0}, 0, 0, 0, false},         // 无效码率
+		{[]byte{0xFF, 0xFB, 0x9C, 0xC0}, 0, 0, 0, false},         // 无效采样率
+		{[]byte{0xFF, 0xFB}, 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		size, samples, sampleRate, ok := mp3Frame(tt.header)
+		if ok != tt.ok || size != tt.size || samples != tt.samples || sampleRate != tt.sampleRate {
+			t.Errorf("mp3Frame(% x) = %d, %d, %d, %v, want %d, %d, %d, %v",
+				tt.header, size, samples, sampleRate, ok, tt.size, tt.samples, tt.sampleRate, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Ogg 页头类型标志
+const (
+	OggContinued = 0x01
+	OggBOS       = 0x02
+	OggEOS       = 0x04
+)
+
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04C11DB7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// oggCRC 计算 Ogg 页校验和（多项式 0x04C11DB7，不反转）
+func oggCRC(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// OggPage 表示一个 Ogg 页
+type OggPage struct {
+	HeaderType byte
+	Granule    int64
+	Serial     uint32
+	Sequence   uint32
+	Packets    [][]byte
+}
+
+// Bytes 编码页数据并填入校验和；调用方需保证单页数据不超过 255 个分段
+func (p *OggPage) Bytes() []byte {
+	var segments []byte
+	var body bytes.Buffer
+	for _, packet := range p.Packets {
+		n := len(packet)
+		for n >= 255 {
+			segments = append(segments, 255)
+			n -= 255
+		}
+		segments = append(segments, byte(n))
+		body.Write(packet)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("OggS")
+	buf.WriteByte(0)
+	buf.WriteByte(p.HeaderType)
+	binary.Write(&buf, binary.LittleEndian, p.Granule)
+	binary.Write(&buf, binary.LittleEndian, p.Serial)
+	binary.Write(&buf, binary.LittleEndian, p.Sequence)
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	buf.WriteByte(byte(len(segments)))
+	buf.Write(segments)
+	buf.Write(body.Bytes())
+
+	page := buf.Bytes()
+	binary.LittleEndian.PutUint32(page[22:26], oggCRC(page))
+	return page
+}
+
+// opusHead 构造 OpusHead 头包
+func opusHead(channels, preSkip, inputRate int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OpusHead")
+	buf.WriteByte(1)
+	buf.WriteByte(byte(channels))
+	binary.Write(&buf, binary.LittleEndian, uint16(preSkip))
+	binary.Write(&buf, binary.LittleEndian, uint32(inputRate))
+	binary.Write(&buf, binary.LittleEndian, int16(0))
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+// opusTags 构造不含注释的 OpusTags 头包
+func opusTags(vendor string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("OpusTags")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(vendor)))
+	buf.WriteString(vendor)
+	binary.Write(&buf, binary.LittleEndian, uint32(0))
+	return buf.Bytes()
+}
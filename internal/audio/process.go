@@ -0,0 +1,232 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// trimPadding 裁剪静音时在首尾保留的时长，避免切掉轻声的起音和收尾
+	trimPadding = 20 * time.Millisecond
+	// normalizePeak 响度归一化后允许的最大峰值（-1dBFS）
+	normalizePeak = 0.891
+	// normalizeMaxGain 响度归一化的最大增益（+20dB），避免放大几乎无声的段
+	normalizeMaxGain = 10.0
+)
+
+// Processable 判断格式是否支持 TrimSilence 和 Normalize，目前只支持 16 位 PCM 和 G.711
+func Processable(f Format) bool {
+	if f.Container != ContainerRaw && f.Container != ContainerRIFF {
+		return false
+	}
+	switch f.Codec {
+	case CodecPCM:
+		return f.BitsPerSample == 16
+	case CodecMulaw, CodecAlaw:
+		return true
+	}
+	return false
+}
+
+// TrimSilence 去掉音频首尾低于阈值（dBFS）的部分，整段都低于阈值时原样返回
+func TrimSilence(f Format, data []byte, thresholdDB float64) ([]byte, error) {
+	return processSamples(f, data, func(samples []float64) []float64 {
+		threshold := dbToLinear(thresholdDB)
+		start, end := -1, -1
+		for i, s := range samples {
+			if math.Abs(s) >= threshold {
+				if start < 0 {
+					start = i
+				}
+				end = i + 1
+			}
+		}
+		if start < 0 {
+			return samples
+		}
+
+		padding := int(int64(f.SampleRate) * int64(trimPadding) / int64(time.Second))
+		start = max(start-padding, 0)
+		end = min(end+padding, len(samples))
+		return samples[start:end]
+	})
+}
+
+// Normalize 按 RMS 把音频响度调整到目标值（dBFS），峰值不超过 -1dBFS
+func Normalize(f Format, data []byte, targetDB float64) ([]byte, error) {
+	return processSamples(f, data, func(samples []float64) []float64 {
+		var sum, peak float64
+		for _, s := range samples {
+			sum += s * s
+			peak = math.Max(peak, math.Abs(s))
+		}
+		if len(samples) == 0 || sum == 0 {
+			return samples
+		}
+
+		rms := math.Sqrt(sum / float64(len(samples)))
+		gain := math.Min(dbToLinear(targetDB)/rms, normalizeMaxGain)
+		gain = math.Min(gain, normalizePeak/peak)
+		for i := range samples {
+			samples[i] *= gain
+		}
+		return samples
+	})
+}
+
+// processSamples 解码为 [-1, 1] 的采样，处理后按原格式重新编码
+func processSamples(f Format, data []byte, process func([]float64) []float64) ([]byte, error) {
+	if !Processable(f) {
+		return nil, fmt.Errorf("不支持处理 %s 格式的音频", f.Name)
+	}
+
+	pcm := data
+	if f.Container == ContainerRIFF {
+		var err error
+		if pcm, err = wavData(data); err != nil {
+			return nil, err
+		}
+	}
+
+	out := encodeSamples(f, process(decodeSamples(f, pcm)))
+	if f.Container == ContainerRIFF {
+		return WAV(f, out), nil
+	}
+	return out, nil
+}
+
+// decodeSamples 把 16 位小端 PCM 或 G.711 数据解码为采样值
+func decodeSamples(f Format, data []byte) []float64 {
+	if f.Codec == CodecPCM {
+		samples := make([]float64, len(data)/2)
+		for i := range samples {
+			samples[i] = float64(int16(binary.LittleEndian.Uint16(data[2*i:]))) / 32768
+		}
+		return samples
+	}
+
+	samples := make([]float64, len(data))
+	for i, b := range data {
+		if f.Codec == CodecMulaw {
+			samples[i] = float64(mulawToLinear(b)) / 32768
+		} else {
+			samples[i] = float64(alawToLinear(b)) / 32768
+		}
+	}
+	return samples
+}
+
+// encodeSamples 把采样值编码为 16 位小端 PCM 或 G.711 数据
+func encodeSamples(f Format, samples []float64) []byte {
+	if f.Codec == CodecPCM {
+		data := make([]byte, 2*len(samples))
+		for i, s := range samples {
+			binary.LittleEndian.PutUint16(data[2*i:], uint16(quantize16(s)))
+		}
+		return data
+	}
+
+	data := make([]byte, len(samples))
+	for i, s := range samples {
+		if f.Codec == CodecMulaw {
+			data[i] = linearToMulaw(quantize16(s))
+		} else {
+			data[i] = linearToAlaw(quantize16(s))
+		}
+	}
+	return data
+}
+
+// quantize16 把 [-1, 1] 的采样值量化为 16 位整数
+func quantize16(s float64) int16 {
+	return int16(math.Max(-32768, math.Min(32767, math.Round(s*32768))))
+}
+
+// dbToLinear 把 dBFS 换算为线性幅度
+func dbToLinear(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// G.711 编解码，算法与 ITU-T G.711 参考实现一致
+var (
+	mulawSegEnd = [8]int{0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF, 0x1FFF}
+	alawSegEnd  = [8]int{0x1F, 0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF}
+)
+
+func g711Segment(value int, ends [8]int) int {
+	for i, end := range ends {
+		if value <= end {
+			return i
+		}
+	}
+	return 8
+}
+
+func linearToMulaw(sample int16) byte {
+	const (
+		bias = 0x21
+		clip = 8159
+	)
+	pcm := int(sample) >> 2
+	mask := 0xFF
+	if pcm < 0 {
+		pcm = -pcm
+		mask = 0x7F
+	}
+	pcm = min(pcm, clip) + bias
+
+	seg := g711Segment(pcm, mulawSegEnd)
+	if seg >= 8 {
+		return byte(0x7F ^ mask)
+	}
+	return byte((seg<<4 | (pcm>>(seg+1))&0x0F) ^ mask)
+}
+
+func mulawToLinear(u byte) int16 {
+	u = ^u
+	t := (int(u&0x0F)<<3 + 0x84) << ((u & 0x70) >> 4)
+	if u&0x80 != 0 {
+		return int16(0x84 - t)
+	}
+	return int16(t - 0x84)
+}
+
+func linearToAlaw(sample int16) byte {
+	pcm := int(sample) >> 3
+	mask := 0xD5
+	if pcm < 0 {
+		mask = 0x55
+		pcm = -pcm - 1
+	}
+
+	seg := g711Segment(pcm, alawSegEnd)
+	if seg >= 8 {
+		return byte(0x7F ^ mask)
+	}
+	aval := seg << 4
+	if seg < 2 {
+		aval |= (pcm >> 1) & 0x0F
+	} else {
+		aval |= (pcm >> seg) & 0x0F
+	}
+	return byte(aval ^ mask)
+}
+
+func alawToLinear(a byte) int16 {
+	a ^= 0x55
+	t := int(a&0x0F) << 4
+	switch seg := int(a&0x70) >> 4; seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t = (t + 0x108) << (seg - 1)
+	}
+	if a&0x80 != 0 {
+		return int16(t)
+	}
+	return int16(-t)
+}
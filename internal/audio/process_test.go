@@ -0,0 +1,132 @@
+package audio
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+)
+
+// constantSamples 返回 n 个幅度为 amplitude、正负交替的采样，其 RMS 与峰值都等于 amplitude
+func constantSamples(n int, amplitude float64) []float64 {
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = amplitude
+		if i%2 == 1 {
+			samples[i] = -amplitude
+		}
+	}
+	return samples
+}
+
+func TestTrimSilence(t *testing.T) {
+	f := mustFormat(t, "raw-16khz-16bit-mono-pcm")
+	const ms = 16 // 16kHz 下每毫秒的采样数
+	padding := 20 * ms
+
+	tests := []struct {
+		name                string
+		lead, sound, trail  int // 各部分的采样数
+		wantLead, wantTrail int
+	}{
+		{"long silence keeps padding", 100 * ms, 100 * ms, 100 * ms, padding, padding},
+		{"short silence is kept", 10 * ms, 100 * ms, 5 * ms, 10 * ms, 5 * ms},
+		{"no silence", 0, 100 * ms, 0, 0, 0},
+		{"leading silence only", 50 * ms, 100 * ms, 0, padding, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples := make([]float64, tt.lead+tt.sound+tt.trail)
+			copy(samples[tt.lead:], constantSamples(tt.sound, 0.5))
+
+			out, err := TrimSilence(f, encodeSamples(f, samples), -50)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := decodeSamples(f, out)
+			if want := tt.wantLead + tt.sound + tt.wantTrail; len(got) != want {
+				t.Fatalf("%d samples, want %d", len(got), want)
+			}
+			if got[tt.wantLead] != 0.5 || got[tt.wantLead+tt.sound-1] == 0 {
+				t.Fatalf("sound is not at sample %d", tt.wantLead)
+			}
+		})
+	}
+
+	// 整段都低于阈值时原样返回
+	silence := mustSilence(t, f, 100*time.Millisecond)
+	out, err := TrimSilence(f, silence, -50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, silence) {
+		t.Errorf("silent input trimmed to %d bytes, want %d", len(out), len(silence))
+	}
+
+	// WAV 输入裁剪后仍为 WAV
+	wavFormat := mustFormat(t, "riff-16khz-16bit-mono-pcm")
+	samples := make([]float64, 200*ms)
+	copy(samples[100*ms:], constantSamples(50*ms, 0.5))
+	out, err = TrimSilence(wavFormat, WAV(wavFormat, encodeSamples(wavFormat, samples)), -50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Duration(wavFormat, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 90*time.Millisecond {
+		t.Errorf("trimmed WAV duration = %v, want 90ms", d)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		samples   []float64
+		targetDB  float64
+		wantPeak  float64
+		tolerance float64
+	}{
+		{"already at target", "raw-16khz-16bit-mono-pcm", constantSamples(1600, 0.1), -20, 0.1, 1e-4},
+		{"raised to target", "raw-16khz-16bit-mono-pcm", constantSamples(1600, 0.01), -20, 0.1, 1e-4},
+		{"lowered to target", "raw-16khz-16bit-mono-pcm", constantSamples(1600, 0.5), -20, 0.1, 1e-4},
+		{"gain clamped to normalizeMaxGain", "raw-16khz-16bit-mono-pcm", constantSamples(1600, 0.001), -20, 0.001 * normalizeMaxGain, 1e-4},
+		{"peak clamped to normalizePeak", "raw-16khz-16bit-mono-pcm", constantSamples(1600, 0.5), 0, normalizePeak, 1e-4},
+		{"mu-law", "raw-8khz-8bit-mono-mulaw", constantSamples(800, 0.01), -20, 0.1, 0.005},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := mustFormat(t, tt.format)
+			out, err := Normalize(f, encodeSamples(f, tt.samples), tt.targetDB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var peak float64
+			for _, s := range decodeSamples(f, out) {
+				peak = math.Max(peak, math.Abs(s))
+			}
+			if math.Abs(peak-tt.wantPeak) > tt.tolerance {
+				t.Errorf("peak = %v, want %v", peak, tt.wantPeak)
+			}
+		})
+	}
+
+	// 静音不做处理
+	f := mustFormat(t, "raw-16khz-16bit-mono-pcm")
+	silence := mustSilence(t, f, 100*time.Millisecond)
+	out, err := Normalize(f, silence, -20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, silence) {
+		t.Error("silent input was changed")
+	}
+
+	if _, err := Normalize(mustFormat(t, "audio-24khz-48kbitrate-mono-mp3"), nil, -20); err == nil {
+		t.Error("expected an error for MP3")
+	}
+}
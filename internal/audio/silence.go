@@ -0,0 +1,134 @@
+package audio
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	opusPreSkip         = 312
+	opusFrameSamples    = 960 // 48kHz 下 20ms
+	opusPacketsPerPage  = 50
+	opusSilentPacketTOC = 0xF8 // CELT 全频带 20ms 单帧，零长度帧按静音处理
+)
+
+// Silence 生成指定格式和时长的静音音频
+func Silence(f Format, d time.Duration) ([]byte, error) {
+	if d < 0 {
+		d = 0
+	}
+
+	switch f.Codec {
+	case CodecPCM, CodecMulaw, CodecAlaw:
+		data := silentSamples(f, d)
+		if f.Container == ContainerRIFF {
+			return WAV(f, data), nil
+		}
+		return data, nil
+	case CodecMP3:
+		return silentMP3(f, d)
+	case CodecOpus:
+		if f.Container != ContainerOgg {
+			return nil, fmt.Errorf("不支持生成 %s 容器的静音", f.Container)
+		}
+		return silentOggOpus(f, d), nil
+	}
+	return nil, fmt.Errorf("不支持生成 %s 编码的静音", f.Codec)
+}
+
+// silentSamples 生成不带文件头的静音采样
+func silentSamples(f Format, d time.Duration) []byte {
+	blockAlign := f.Channels * f.BitsPerSample / 8
+	samples := int(int64(f.SampleRate) * int64(d) / int64(time.Second))
+	data := make([]byte, samples*blockAlign)
+
+	// G.711 的零电平不是 0x00
+	var fill byte
+	switch f.Codec {
+	case CodecMulaw:
+		fill = 0xFF
+	case CodecAlaw:
+		fill = 0xD5
+	}
+	if fill != 0 {
+		for i := range data {
+			data[i] = fill
+		}
+	}
+	return data
+}
+
+// silentMP3 生成全零边信息的 Layer III 帧，解码结果为静音
+func silentMP3(f Format, d time.Duration) ([]byte, error) {
+	header, frameSize, samplesPerFrame, err := mp3FrameHeader(f.SampleRate, f.Bitrate)
+	if err != nil {
+		return nil, err
+	}
+
+	frames := int((int64(f.SampleRate)*int64(d)/int64(time.Second) + int64(samplesPerFrame) - 1) / int64(samplesPerFrame))
+	frame := make([]byte, frameSize)
+	copy(frame, header[:])
+
+	return bytes.Repeat(frame, frames), nil
+}
+
+// silentOggOpus 生成只含零长度帧的 Ogg Opus 流
+func silentOggOpus(f Format, d time.Duration) []byte {
+	serial := rand.Uint32()
+	var buf bytes.Buffer
+
+	head := OggPage{HeaderType: OggBOS, Serial: serial, Sequence: 0, Packets: [][]byte{opusHead(f.Channels, opusPreSkip, f.SampleRate)}}
+	buf.Write(head.Bytes())
+	tags := OggPage{Serial: serial, Sequence: 1, Packets: [][]byte{opusTags("tts")}}
+	buf.Write(tags.Bytes())
+
+	packets := int((d + 20*time.Millisecond - 1) / (20 * time.Millisecond))
+	if packets == 0 {
+		packets = 1
+	}
+
+	sequence := uint32(2)
+	granule := int64(opusPreSkip)
+	for written := 0; written < packets; {
+		n := packets - written
+		if n > opusPacketsPerPage {
+			n = opusPacketsPerPage
+		}
+		page := OggPage{Serial: serial, Sequence: sequence}
+		for i := 0; i < n; i++ {
+			page.Packets = append(page.Packets, []byte{opusSilentPacketTOC})
+		}
+		written += n
+		granule += int64(n * opusFrameSamples)
+		page.Granule = granule
+		if written == packets {
+			page.HeaderType = OggEOS
+		}
+		buf.Write(page.Bytes())
+		sequence++
+	}
+
+	return buf.Bytes()
+}
+
+// EstimateDuration 按正常语速估算纯文本的朗读时长：汉字约 250ms，其它字符约 70ms，最短 500ms
+func EstimateDuration(text string) time.Duration {
+	var d time.Duration
+	for _, r := range strings.TrimSpace(text) {
+		switch {
+		case unicode.IsSpace(r):
+		case unicode.Is(unicode.Han, r):
+			d += 250 * time.Millisecond
+		default:
+			d += 70 * time.Millisecond
+		}
+	}
+	if d < 500*time.Millisecond {
+		d = 500 * time.Millisecond
+	}
+	return d
+}
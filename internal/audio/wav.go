@@ -0,0 +1,101 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// WAV 格式标签
+const (
+	WaveFormatPCM   = 1
+	WaveFormatAlaw  = 6
+	WaveFormatMulaw = 7
+)
+
+// WAVHeader 生成 44 字节的标准 RIFF/WAVE 文件头
+func WAVHeader(formatTag, channels, sampleRate, bitsPerSample, dataLen int) []byte {
+	blockAlign := channels * bitsPerSample / 8
+
+	var buf bytes.Buffer
+	buf.Grow(44)
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataLen))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(formatTag))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataLen))
+	return buf.Bytes()
+}
+
+// WAV 为原始采样数据加上 WAV 文件头
+func WAV(f Format, data []byte) []byte {
+	header := WAVHeader(waveFormatTag(f), f.Channels, f.SampleRate, f.BitsPerSample, len(data))
+	return append(header, data...)
+}
+
+// waveFormatTag 返回编码对应的 WAV 格式标签
+func waveFormatTag(f Format) int {
+	switch f.Codec {
+	case CodecMulaw:
+		return WaveFormatMulaw
+	case CodecAlaw:
+		return WaveFormatAlaw
+	}
+	return WaveFormatPCM
+}
+
+// ParseWAV 读取 WAV 文件 fmt 块描述的格式，返回格式和 data 块的采样数据
+func ParseWAV(data []byte) (Format, []byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return Format{}, nil, errors.New("无效的 WAV 文件头")
+	}
+
+	f := Format{Container: ContainerRIFF}
+	for chunks := data[12:]; len(chunks) >= 8; {
+		id := string(chunks[:4])
+		size := int64(binary.LittleEndian.Uint32(chunks[4:8]))
+		chunks = chunks[8:]
+		if id == "fmt " {
+			if size < 16 || int64(len(chunks)) < 16 {
+				return Format{}, nil, errors.New("WAV fmt 块不完整")
+			}
+			switch binary.LittleEndian.Uint16(chunks[0:2]) {
+			case WaveFormatPCM:
+				f.Codec = CodecPCM
+			case WaveFormatMulaw:
+				f.Codec = CodecMulaw
+			case WaveFormatAlaw:
+				f.Codec = CodecAlaw
+			default:
+				return Format{}, nil, fmt.Errorf("不支持的 WAV 编码: %d", binary.LittleEndian.Uint16(chunks[0:2]))
+			}
+			f.Channels = int(binary.LittleEndian.Uint16(chunks[2:4]))
+			f.SampleRate = int(binary.LittleEndian.Uint32(chunks[4:8]))
+			f.BitsPerSample = int(binary.LittleEndian.Uint16(chunks[14:16]))
+			break
+		}
+		if size+size%2 > int64(len(chunks)) {
+			break
+		}
+		chunks = chunks[size+size%2:]
+	}
+	if f.Codec == "" {
+		return Format{}, nil, errors.New("WAV 文件缺少 fmt 块")
+	}
+	f.Name = fmt.Sprintf("riff-%dhz-%dbit-%dch-%s", f.SampleRate, f.BitsPerSample, f.Channels, f.Codec)
+
+	samples, err := wavData(data)
+	if err != nil {
+		return Format{}, nil, err
+	}
+	return f, samples, nil
+}
@@ -0,0 +1,115 @@
+// Package cache 提供按请求内容寻址的合成音频缓存（内存 LRU + 可选磁盘层）
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"tts/internal/config"
+)
+
+const (
+	defaultMemoryMaxMB      = 64
+	defaultMemoryMaxEntries = 1000
+	defaultDiskMaxMB        = 1024
+)
+
+// Entry 是一条缓存的音频
+type Entry struct {
+	Audio       []byte
+	ContentType string
+}
+
+// AudioCache 先查内存层，再查磁盘层；磁盘命中的条目会回填内存层
+type AudioCache struct {
+	memory *memoryCache
+	disk   *diskCache
+
+	hits       uint64
+	diskHits   uint64
+	misses     uint64
+	stores     uint64
+	storeBytes uint64
+}
+
+// New 按配置创建音频缓存
+func New(cfg config.CacheConfig) (*AudioCache, error) {
+	ttl := time.Duration(cfg.TTL) * time.Second
+
+	memoryMaxMB := cfg.MemoryMaxMB
+	if memoryMaxMB <= 0 {
+		memoryMaxMB = defaultMemoryMaxMB
+	}
+	memoryMaxEntries := cfg.MemoryMaxEntries
+	if memoryMaxEntries <= 0 {
+		memoryMaxEntries = defaultMemoryMaxEntries
+	}
+
+	c := &AudioCache{
+		memory: newMemoryCache(int64(memoryMaxMB)<<20, memoryMaxEntries, ttl),
+	}
+
+	if cfg.DiskDir != "" {
+		diskMaxMB := cfg.DiskMaxMB
+		if diskMaxMB <= 0 {
+			diskMaxMB = defaultDiskMaxMB
+		}
+		disk, err := newDiskCache(cfg.DiskDir, int64(diskMaxMB)<<20, ttl)
+		if err != nil {
+			return nil, err
+		}
+		c.disk = disk
+	}
+
+	return c, nil
+}
+
+// Get 查找缓存
+func (c *AudioCache) Get(key string) (Entry, bool) {
+	if entry, ok := c.memory.get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return entry, true
+	}
+	if c.disk != nil {
+		if entry, ok := c.disk.get(key); ok {
+			atomic.AddUint64(&c.hits, 1)
+			atomic.AddUint64(&c.diskHits, 1)
+			c.memory.set(key, entry)
+			return entry, true
+		}
+	}
+	atomic.AddUint64(&c.misses, 1)
+	return Entry{}, false
+}
+
+// Set 写入缓存
+func (c *AudioCache) Set(key string, entry Entry) {
+	if len(entry.Audio) == 0 {
+		return
+	}
+	atomic.AddUint64(&c.stores, 1)
+	atomic.AddUint64(&c.storeBytes, uint64(len(entry.Audio)))
+	c.memory.set(key, entry)
+	if c.disk != nil {
+		c.disk.set(key, entry)
+	}
+}
+
+// Stats 返回缓存统计信息
+func (c *AudioCache) Stats() map[string]interface{} {
+	memoryEntries, memoryBytes := c.memory.stats()
+	stats := map[string]interface{}{
+		"hits":           atomic.LoadUint64(&c.hits),
+		"misses":         atomic.LoadUint64(&c.misses),
+		"stores":         atomic.LoadUint64(&c.stores),
+		"memory_entries": memoryEntries,
+		"memory_bytes":   memoryBytes,
+	}
+	if c.disk != nil {
+		diskEntries, diskBytes := c.disk.stats()
+		stats["disk_hits"] = atomic.LoadUint64(&c.diskHits)
+		stats["disk_entries"] = diskEntries
+		stats["disk_bytes"] = diskBytes
+	}
+	return stats
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// diskFile 是磁盘层索引中的一个文件
+type diskFile struct {
+	size     int64
+	storedAt time.Time
+}
+
+// diskCache 将条目保存为 <dir>/<key>.audio，文件首行为 MIME 类型
+type diskCache struct {
+	dir      string
+	maxBytes int64
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	files map[string]diskFile
+	bytes int64
+}
+
+// newDiskCache 创建磁盘层并扫描已有文件建立索引
+func newDiskCache(dir string, maxBytes int64, ttl time.Duration) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+
+	d := &diskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		files:    make(map[string]diskFile),
+	}
+
+	d.sweepTemp()
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.audio"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		key := filepath.Base(path[:len(path)-len(".audio")])
+		d.files[key] = diskFile{size: info.Size(), storedAt: info.ModTime()}
+		d.bytes += info.Size()
+	}
+
+	d.mu.Lock()
+	d.evict()
+	d.mu.Unlock()
+
+	log.Printf("磁盘音频缓存: %s, %d 个文件", dir, len(d.files))
+	return d, nil
+}
+
+// sweepTemp 删除上次运行中写入中断留下的临时文件，它们不在索引中，不删除会一直占用磁盘
+func (d *diskCache) sweepTemp() {
+	matches, err := filepath.Glob(filepath.Join(d.dir, "*.tmp"))
+	if err != nil {
+		return
+	}
+	removed := 0
+	for _, path := range matches {
+		if err := os.Remove(path); err != nil {
+			log.Printf("删除磁盘缓存临时文件失败: %v", err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		log.Printf("已删除 %d 个磁盘缓存临时文件", removed)
+	}
+}
+
+func (d *diskCache) path(key string) string {
+	return filepath.Join(d.dir, key+".audio")
+}
+
+func (d *diskCache) get(key string) (Entry, bool) {
+	d.mu.Lock()
+	file, ok := d.files[key]
+	if ok && d.ttl > 0 && time.Since(file.storedAt) > d.ttl {
+		d.remove(key)
+		ok = false
+	}
+	d.mu.Unlock()
+	if !ok {
+		return Entry{}, false
+	}
+
+	raw, err := os.ReadFile(d.path(key))
+	if err != nil {
+		d.mu.Lock()
+		d.remove(key)
+		d.mu.Unlock()
+		return Entry{}, false
+	}
+
+	contentType, audio, found := bytes.Cut(raw, []byte("\n"))
+	if !found {
+		return Entry{}, false
+	}
+	return Entry{Audio: audio, ContentType: string(contentType)}, true
+}
+
+func (d *diskCache) set(key string, entry Entry) {
+	size := int64(len(entry.ContentType) + 1 + len(entry.Audio))
+	if d.maxBytes > 0 && size > d.maxBytes {
+		return
+	}
+
+	// 先写临时文件再改名，避免读到不完整的文件
+	tmp, err := os.CreateTemp(d.dir, "*.tmp")
+	if err != nil {
+		log.Printf("写入磁盘缓存失败: %v", err)
+		return
+	}
+	w := bufio.NewWriter(tmp)
+	w.WriteString(entry.ContentType)
+	w.WriteByte('\n')
+	w.Write(entry.Audio)
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		log.Printf("写入磁盘缓存失败: %v", err)
+		return
+	}
+	tmp.Close()
+	if err := os.Rename(tmp.Name(), d.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("写入磁盘缓存失败: %v", err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if old, ok := d.files[key]; ok {
+		d.bytes -= old.size
+	}
+	d.files[key] = diskFile{size: size, storedAt: time.Now()}
+	d.bytes += size
+	d.evict()
+}
+
+// evict 删除过期文件，并按写入时间从旧到新删除直至不超过容量，调用方需持有锁
+func (d *diskCache) evict() {
+	if d.ttl > 0 {
+		for key, file := range d.files {
+			if time.Since(file.storedAt) > d.ttl {
+				d.remove(key)
+			}
+		}
+	}
+	if d.maxBytes <= 0 || d.bytes <= d.maxBytes {
+		return
+	}
+
+	keys := make([]string, 0, len(d.files))
+	for key := range d.files {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return d.files[keys[i]].storedAt.Before(d.files[keys[j]].storedAt)
+	})
+	for _, key := range keys {
+		if d.bytes <= d.maxBytes {
+			break
+		}
+		d.remove(key)
+	}
+}
+
+// remove 删除文件及其索引，调用方需持有锁
+func (d *diskCache) remove(key string) {
+	if file, ok := d.files[key]; ok {
+		d.bytes -= file.size
+		delete(d.files, key)
+	}
+	os.Remove(d.path(key))
+}
+
+func (d *diskCache) stats() (int, int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.files), d.bytes
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestDiskCacheSweepsTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.audio":        "audio/mpeg\nfirst",
+		"123456.tmp":     "audio/mpeg\ninterrupted",
+		"b.audio.tmp":    "audio/mpeg\ninterrupted",
+		"unrelated.json": "{}",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := newDiskCache(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dirEntries(t, dir); !equalStrings(got, []string{"a.audio", "unrelated.json"}) {
+		t.Fatalf("directory = %v, want [a.audio unrelated.json]", got)
+	}
+	if count, _ := d.stats(); count != 1 {
+		t.Fatalf("indexed files = %d, want 1", count)
+	}
+	if entry, ok := d.get("a"); !ok || string(entry.Audio) != "first" {
+		t.Fatalf("get(a) = %q, %v", entry.Audio, ok)
+	}
+
+	// 正常写入不留下临时文件
+	d.set("c", Entry{Audio: []byte("second"), ContentType: "audio/mpeg"})
+	if got := dirEntries(t, dir); !equalStrings(got, []string{"a.audio", "c.audio", "unrelated.json"}) {
+		t.Fatalf("directory = %v, want [a.audio c.audio unrelated.json]", got)
+	}
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	d, err := newDiskCache(t.TempDir(), 40, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 每个条目 "audio/mpeg\n" 加 8 字节音频，共 19 字节，容量只够两个
+	for _, key := range []string{"a", "b", "c"} {
+		d.set(key, Entry{Audio: []byte("12345678"), ContentType: "audio/mpeg"})
+		time.Sleep(time.Millisecond)
+	}
+	if count, size := d.stats(); count != 2 || size != 38 {
+		t.Fatalf("stats = %d files, %d bytes, want 2 files, 38 bytes", count, size)
+	}
+	if _, ok := d.get("a"); ok {
+		t.Error("oldest entry was not evicted")
+	}
+	for _, key := range []string{"b", "c"} {
+		if _, ok := d.get(key); !ok {
+			t.Errorf("entry %s was evicted", key)
+		}
+	}
+}
+
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
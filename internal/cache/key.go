@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"tts/internal/models"
+)
+
+// Key 根据归一化后的文本、语音、风格、语速、音调和格式计算缓存键
+func Key(req models.TTSRequest) string {
+	style := req.Style
+	if style == "" {
+		style = "general"
+	}
+
+	h := sha256.New()
+	for _, field := range []string{
+		normalizeText(req.Text),
+		req.Provider,
+		req.Voice,
+		style,
+		normalizePercent(req.Rate),
+		normalizePercent(req.Pitch),
+		req.Format,
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeText 去除首尾空白并将连续空白合并为一个空格
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// normalizePercent 将 "+10"、"10%"、"10" 统一为 "10"
+func normalizePercent(value string) string {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "%")
+	if n, err := strconv.Atoi(value); err == nil {
+		return strconv.Itoa(n)
+	}
+	return value
+}
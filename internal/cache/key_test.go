@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"testing"
+
+	"tts/internal/models"
+)
+
+func TestKeyNormalization(t *testing.T) {
+	base := models.TTSRequest{
+		Text:   "你好 世界",
+		Voice:  "zh-CN-XiaoxiaoNeural",
+		Rate:   "10",
+		Pitch:  "0",
+		Format: "audio-24khz-48kbitrate-mono-mp3",
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*models.TTSRequest)
+		same   bool
+	}{
+		{"identical", func(r *models.TTSRequest) {}, true},
+		{"surrounding whitespace", func(r *models.TTSRequest) { r.Text = "  你好 世界\n" }, true},
+		{"repeated whitespace", func(r *models.TTSRequest) { r.Text = "你好 \t\n 世界" }, true},
+		{"rate with plus sign", func(r *models.TTSRequest) { r.Rate = "+10" }, true},
+		{"rate with percent", func(r *models.TTSRequest) { r.Rate = "10%" }, true},
+		{"pitch with spaces", func(r *models.TTSRequest) { r.Pitch = " 0% " }, true},
+		{"empty style is general", func(r *models.TTSRequest) { r.Style = "general" }, true},
+		{"different text", func(r *models.TTSRequest) { r.Text = "你好世界" }, false},
+		{"different voice", func(r *models.TTSRequest) { r.Voice = "zh-CN-YunxiNeural" }, false},
+		{"different style", func(r *models.TTSRequest) { r.Style = "cheerful" }, false},
+		{"different rate", func(r *models.TTSRequest) { r.Rate = "-10" }, false},
+		{"different format", func(r *models.TTSRequest) { r.Format = "riff-24khz-16bit-mono-pcm" }, false},
+		{"different provider", func(r *models.TTSRequest) { r.Provider = "local" }, false},
+	}
+
+	want := Key(base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := base
+			tt.modify(&req)
+			if got := Key(req) == want; got != tt.same {
+				t.Fatalf("same key = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestNormalizePercent(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"0", "0"},
+		{"+20", "20"},
+		{"20%", "20"},
+		{"-5%", "-5"},
+		{"007", "7"},
+		{"fast", "fast"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizePercent(tt.in); got != tt.want {
+			t.Errorf("normalizePercent(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
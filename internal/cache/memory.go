@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// memoryItem 是 LRU 链表中的一个条目
+type memoryItem struct {
+	key      string
+	entry    Entry
+	storedAt time.Time
+}
+
+// memoryCache 是按字节数和条目数限制的 LRU 缓存
+type memoryCache struct {
+	maxBytes   int64
+	maxEntries int
+	ttl        time.Duration
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	order *list.List // 最近使用的在前
+	bytes int64
+}
+
+func newMemoryCache(maxBytes int64, maxEntries int, ttl time.Duration) *memoryCache {
+	return &memoryCache{
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (m *memoryCache) get(key string) (Entry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	item := el.Value.(*memoryItem)
+	if m.ttl > 0 && time.Since(item.storedAt) > m.ttl {
+		m.remove(el)
+		return Entry{}, false
+	}
+	m.order.MoveToFront(el)
+	return item.entry, true
+}
+
+func (m *memoryCache) set(key string, entry Entry) {
+	size := int64(len(entry.Audio))
+	if m.maxBytes > 0 && size > m.maxBytes {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		m.remove(el)
+	}
+	m.items[key] = m.order.PushFront(&memoryItem{key: key, entry: entry, storedAt: time.Now()})
+	m.bytes += size
+
+	for m.order.Len() > 0 &&
+		((m.maxBytes > 0 && m.bytes > m.maxBytes) || (m.maxEntries > 0 && m.order.Len() > m.maxEntries)) {
+		m.remove(m.order.Back())
+	}
+}
+
+func (m *memoryCache) remove(el *list.Element) {
+	item := el.Value.(*memoryItem)
+	m.order.Remove(el)
+	delete(m.items, item.key)
+	m.bytes -= int64(len(item.entry.Audio))
+}
+
+func (m *memoryCache) stats() (int, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len(), m.bytes
+}
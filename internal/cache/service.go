@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"tts/internal/models"
+	"tts/internal/tts"
+)
+
+// Service 为 tts.Service 加上音频缓存
+type Service struct {
+	inner tts.Service
+	cache *AudioCache
+}
+
+// NewService 用缓存包装一个 tts.Service
+func NewService(inner tts.Service, cache *AudioCache) *Service {
+	return &Service{
+		inner: inner,
+		cache: cache,
+	}
+}
+
+// ListVoices 直接转发
+func (s *Service) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	return s.inner.ListVoices(ctx, locale)
+}
+
+// WarmupVoicesCache 直接转发
+func (s *Service) WarmupVoicesCache(ctx context.Context) error {
+	return s.inner.WarmupVoicesCache(ctx)
+}
+
+// SynthesizeSpeech 命中缓存时直接返回并设置 CacheHit
+func (s *Service) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	key := Key(req)
+	if entry, ok := s.cache.Get(key); ok {
+		return &models.TTSResponse{
+			AudioContent: entry.Audio,
+			ContentType:  entry.ContentType,
+			CacheHit:     true,
+		}, nil
+	}
+
+	resp, err := s.inner.SynthesizeSpeech(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	// 备用后端使用不同的语音合成，不能作为该请求的缓存
+	if !resp.Fallback {
+		s.cache.Set(key, Entry{Audio: resp.AudioContent, ContentType: resp.ContentType})
+	}
+	return resp, nil
+}
+
+// SynthesizeStream 命中缓存时返回 HitReader；未命中时边转发边缓冲，完整读完后写入缓存，备用后端的音频流不缓存
+func (s *Service) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	key := Key(req)
+	if entry, ok := s.cache.Get(key); ok {
+		return &HitReader{Reader: bytes.NewReader(entry.Audio)}, entry.ContentType, nil
+	}
+
+	body, contentType, err := s.inner.SynthesizeStream(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	if tts.IsFallback(body) {
+		return body, contentType, nil
+	}
+	return &teeReader{
+		inner: body,
+		done: func(audio []byte) {
+			s.cache.Set(key, Entry{Audio: audio, ContentType: contentType})
+		},
+	}, contentType, nil
+}
+
+// SynthesizeWithEvents 事件结果不缓存，直接转发
+func (s *Service) SynthesizeWithEvents(ctx context.Context, req models.TTSRequest) (*models.TTSEventsResponse, error) {
+	synthesizer, ok := s.inner.(tts.EventSynthesizer)
+	if !ok {
+		return nil, errors.New("当前后端不支持合成事件")
+	}
+	return synthesizer.SynthesizeWithEvents(ctx, req)
+}
+
+// Health 附加缓存统计
+func (s *Service) Health() map[string]interface{} {
+	health := map[string]interface{}{}
+	if reporter, ok := s.inner.(tts.HealthReporter); ok {
+		health = reporter.Health()
+	}
+	health["cache"] = s.cache.Stats()
+	return health
+}
+
+// CatalogueStatus 直接转发
+func (s *Service) CatalogueStatus() map[string]interface{} {
+	if reporter, ok := s.inner.(tts.CatalogueReporter); ok {
+		return reporter.CatalogueStatus()
+	}
+	return nil
+}
+
+// HitReader 是来自缓存的音频流
+type HitReader struct {
+	*bytes.Reader
+}
+
+// Close 无需释放资源
+func (r *HitReader) Close() error {
+	return nil
+}
+
+// IsHit 判断音频流是否来自缓存
+func IsHit(body io.Reader) bool {
+	_, ok := body.(*HitReader)
+	return ok
+}
+
+// teeReader 转发上游音频流并保留副本，只有完整读到 EOF 才回调 done
+type teeReader struct {
+	inner  io.ReadCloser
+	buf    bytes.Buffer
+	done   func([]byte)
+	failed bool
+}
+
+func (t *teeReader) Read(p []byte) (int, error) {
+	n, err := t.inner.Read(p)
+	if n > 0 {
+		t.buf.Write(p[:n])
+	}
+	if err == io.EOF && !t.failed && t.done != nil {
+		t.done(t.buf.Bytes())
+		t.done = nil
+	} else if err != nil && err != io.EOF {
+		t.failed = true
+	}
+	return n, err
+}
+
+func (t *teeReader) Close() error {
+	return t.inner.Close()
+}
+
+// Close 直接转发
+func (s *Service) Close() error {
+	if closer, ok := s.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"tts/internal/config"
+	"tts/internal/models"
+)
+
+// stubService 每次合成返回固定音频，fallback 为 true 时模拟故障转移的备用后端
+type stubService struct {
+	fallback bool
+	calls    int
+}
+
+func (s *stubService) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	return nil, nil
+}
+
+func (s *stubService) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	s.calls++
+	return &models.TTSResponse{AudioContent: []byte("audio"), ContentType: "audio/mpeg", Fallback: s.fallback}, nil
+}
+
+func (s *stubService) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	s.calls++
+	var body io.ReadCloser = io.NopCloser(bytes.NewReader([]byte("audio")))
+	if s.fallback {
+		body = fallbackBody{body}
+	}
+	return body, "audio/mpeg", nil
+}
+
+func (s *stubService) WarmupVoicesCache(ctx context.Context) error {
+	return nil
+}
+
+type fallbackBody struct {
+	io.ReadCloser
+}
+
+func (fallbackBody) Fallback() bool {
+	return true
+}
+
+func TestServiceCaching(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   bool
+		fallback bool
+		calls    int
+	}{
+		{"speech is cached", false, false, 1},
+		{"stream is cached", true, false, 1},
+		{"fallback speech is not cached", false, true, 2},
+		{"fallback stream is not cached", true, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audioCache, err := New(config.CacheConfig{Enabled: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			inner := &stubService{fallback: tt.fallback}
+			s := NewService(inner, audioCache)
+			req := models.TTSRequest{Text: "hello", Voice: "v"}
+
+			for i := 0; i < 2; i++ {
+				if tt.stream {
+					body, _, err := s.SynthesizeStream(context.Background(), req)
+					if err != nil {
+						t.Fatal(err)
+					}
+					io.ReadAll(body)
+					body.Close()
+				} else if _, err := s.SynthesizeSpeech(context.Background(), req); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if inner.calls != tt.calls {
+				t.Fatalf("upstream calls = %d, want %d", inner.calls, tt.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+// Package coalesce 合并同时进行的相同合成请求，让它们共享一次上游调用及其音频数据
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"tts/internal/cache"
+	"tts/internal/models"
+	"tts/internal/tts"
+)
+
+// Service 为 tts.Service 加上请求合并
+type Service struct {
+	inner tts.Service
+
+	mu      sync.Mutex
+	flights map[string]*flight
+
+	leaders   uint64 // 实际发起的上游调用次数
+	coalesced uint64 // 合并到已有上游调用的请求数
+}
+
+// NewService 用请求合并包装一个 tts.Service
+func NewService(inner tts.Service) *Service {
+	return &Service{
+		inner:   inner,
+		flights: make(map[string]*flight),
+	}
+}
+
+// flight 是一次正在进行的上游调用，音频边接收边追加到 buf，所有订阅者从各自的偏移读取
+type flight struct {
+	key string
+
+	ready       chan struct{} // 上游返回响应头或出错后关闭
+	contentType string
+	fallback    bool // 音频来自备用后端
+	startErr    error
+	cancel      context.CancelFunc
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  []byte
+	done bool
+	err  error
+	refs int
+}
+
+// ListVoices 直接转发
+func (s *Service) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	return s.inner.ListVoices(ctx, locale)
+}
+
+// WarmupVoicesCache 直接转发
+func (s *Service) WarmupVoicesCache(ctx context.Context) error {
+	return s.inner.WarmupVoicesCache(ctx)
+}
+
+// SynthesizeSpeech 与相同的进行中请求共享上游调用，读完整段音频后返回；
+// 由它发起的上游调用走 SynthesizeSpeech，读取音频中途的失败也能由故障转移计入熔断并转移
+func (s *Service) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	if ctx.Value(bypassKey{}) != nil {
+		return s.inner.SynthesizeSpeech(ctx, req)
+	}
+
+	r, err := s.subscribe(ctx, req, false)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	audio, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TTSResponse{
+		AudioContent: audio,
+		ContentType:  r.f.contentType,
+		Fallback:     r.f.fallback,
+	}, nil
+}
+
+// bypassKey 标记不参与请求合并的上下文
+type bypassKey struct{}
+
+// WithoutCoalescing 返回不参与请求合并的上下文，用于需要独立上游调用的对冲请求
+func WithoutCoalescing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// joinReportKey 保存请求合并时需要置位的标记
+type joinReportKey struct{}
+
+// ReportJoin 返回的上下文用于合成请求时，若请求合并到了已有的上游调用，joined 会被置为 true
+func ReportJoin(ctx context.Context, joined *atomic.Bool) context.Context {
+	return context.WithValue(ctx, joinReportKey{}, joined)
+}
+
+// SynthesizeStream 与相同的进行中请求共享上游调用，每个调用方都能从头读到完整音频流
+func (s *Service) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	if ctx.Value(bypassKey{}) != nil {
+		return s.inner.SynthesizeStream(ctx, req)
+	}
+
+	r, err := s.subscribe(ctx, req, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return r, r.f.contentType, nil
+}
+
+// subscribe 加入或发起上游调用，等到上游返回响应头后返回该调用方的读取器
+func (s *Service) subscribe(ctx context.Context, req models.TTSRequest, stream bool) (*reader, error) {
+	f := s.join(ctx, req, stream)
+
+	select {
+	case <-f.ready:
+	case <-ctx.Done():
+		s.release(f)
+		return nil, ctx.Err()
+	}
+	if f.startErr != nil {
+		s.release(f)
+		return nil, f.startErr
+	}
+
+	r := &reader{s: s, f: f, ctx: ctx}
+	// 调用方取消时唤醒阻塞中的 Read
+	r.stop = context.AfterFunc(ctx, func() {
+		f.mu.Lock()
+		f.cond.Broadcast()
+		f.mu.Unlock()
+	})
+	return r, nil
+}
+
+// join 加入相同请求的进行中调用，没有时发起新的上游调用；
+// stream 为 false 时以 SynthesizeSpeech 发起，之后加入的流式请求在整段音频返回后读到全部数据
+func (s *Service) join(ctx context.Context, req models.TTSRequest, stream bool) *flight {
+	key := cache.Key(req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.flights[key]; ok {
+		f.mu.Lock()
+		f.refs++
+		f.mu.Unlock()
+		atomic.AddUint64(&s.coalesced, 1)
+		if joined, ok := ctx.Value(joinReportKey{}).(*atomic.Bool); ok {
+			joined.Store(true)
+		}
+		log.Printf("合并相同的合成请求: voice=%s, 文本长度=%d", req.Voice, len(req.Text))
+		return f
+	}
+
+	f := &flight{
+		key:   key,
+		ready: make(chan struct{}),
+		refs:  1,
+	}
+	f.cond = sync.NewCond(&f.mu)
+	s.flights[key] = f
+	atomic.AddUint64(&s.leaders, 1)
+
+	// 上游调用不随发起者取消，只在所有订阅者都放弃后取消
+	upstreamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	f.cancel = cancel
+	if stream {
+		go s.run(upstreamCtx, f, req)
+	} else {
+		go s.runSpeech(upstreamCtx, f, req)
+	}
+	return f
+}
+
+// run 发起上游调用，并把音频流追加到共享缓冲区
+func (s *Service) run(ctx context.Context, f *flight, req models.TTSRequest) {
+	defer f.cancel()
+
+	body, contentType, err := s.inner.SynthesizeStream(ctx, req)
+	f.contentType = contentType
+	f.fallback = err == nil && tts.IsFallback(body)
+	f.startErr = err
+	close(f.ready)
+	if err != nil {
+		s.finish(f, err)
+		return
+	}
+	defer body.Close()
+
+	chunk := make([]byte, 32*1024)
+	for {
+		n, readErr := body.Read(chunk)
+		if n > 0 {
+			f.mu.Lock()
+			f.buf = append(f.buf, chunk[:n]...)
+			f.cond.Broadcast()
+			f.mu.Unlock()
+		}
+		if readErr != nil {
+			if readErr == io.EOF {
+				readErr = nil
+			}
+			s.finish(f, readErr)
+			return
+		}
+	}
+}
+
+// runSpeech 以 SynthesizeSpeech 发起上游调用，整段音频返回后写入共享缓冲区
+func (s *Service) runSpeech(ctx context.Context, f *flight, req models.TTSRequest) {
+	defer f.cancel()
+
+	resp, err := s.inner.SynthesizeSpeech(ctx, req)
+	f.startErr = err
+	if err == nil {
+		f.contentType = resp.ContentType
+		f.fallback = resp.Fallback
+		f.mu.Lock()
+		f.buf = resp.AudioContent
+		f.mu.Unlock()
+	}
+	close(f.ready)
+	s.finish(f, err)
+}
+
+// finish 标记调用结束，之后到达的相同请求会发起新的上游调用
+func (s *Service) finish(f *flight, err error) {
+	s.mu.Lock()
+	if s.flights[f.key] == f {
+		delete(s.flights, f.key)
+	}
+	s.mu.Unlock()
+
+	f.mu.Lock()
+	f.done = true
+	f.err = err
+	f.cond.Broadcast()
+	f.mu.Unlock()
+}
+
+// release 释放一个订阅者，最后一个订阅者离开且调用尚未结束时取消上游调用
+func (s *Service) release(f *flight) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.refs--
+	if f.refs > 0 || f.done {
+		return
+	}
+	if s.flights[f.key] == f {
+		delete(s.flights, f.key)
+	}
+	f.cancel()
+}
+
+// reader 从共享缓冲区读取一个订阅者的音频流
+type reader struct {
+	s      *Service
+	f      *flight
+	ctx    context.Context
+	stop   func() bool
+	off    int
+	closed bool
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	f := r.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for r.off >= len(f.buf) && !f.done && r.ctx.Err() == nil {
+		f.cond.Wait()
+	}
+	if r.off < len(f.buf) {
+		n := copy(p, f.buf[r.off:])
+		r.off += n
+		return n, nil
+	}
+	if f.done {
+		if f.err != nil {
+			return 0, f.err
+		}
+		return 0, io.EOF
+	}
+	return 0, r.ctx.Err()
+}
+
+// Fallback 报告共享的上游音频流是否来自备用后端
+func (r *reader) Fallback() bool {
+	return r.f.fallback
+}
+
+func (r *reader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.stop()
+	r.s.release(r.f)
+	return nil
+}
+
+// SynthesizeWithEvents 事件结果各不相同，直接转发
+func (s *Service) SynthesizeWithEvents(ctx context.Context, req models.TTSRequest) (*models.TTSEventsResponse, error) {
+	synthesizer, ok := s.inner.(tts.EventSynthesizer)
+	if !ok {
+		return nil, errors.New("当前后端不支持合成事件")
+	}
+	return synthesizer.SynthesizeWithEvents(ctx, req)
+}
+
+// CatalogueStatus 直接转发
+func (s *Service) CatalogueStatus() map[string]interface{} {
+	if reporter, ok := s.inner.(tts.CatalogueReporter); ok {
+		return reporter.CatalogueStatus()
+	}
+	return nil
+}
+
+// Stats 返回请求合并的计数
+func (s *Service) Stats() map[string]interface{} {
+	s.mu.Lock()
+	inFlight := len(s.flights)
+	s.mu.Unlock()
+
+	return map[string]interface{}{
+		"upstream_calls": atomic.LoadUint64(&s.leaders),
+		"coalesced":      atomic.LoadUint64(&s.coalesced),
+		"in_flight":      inFlight,
+	}
+}
+
+// Health 附加请求合并的计数
+func (s *Service) Health() map[string]interface{} {
+	health := map[string]interface{}{}
+	if reporter, ok := s.inner.(tts.HealthReporter); ok {
+		health = reporter.Health()
+	}
+	health["coalesce"] = s.Stats()
+	return health
+}
+
+// Close 直接转发
+func (s *Service) Close() error {
+	if closer, ok := s.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
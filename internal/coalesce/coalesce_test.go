@@ -0,0 +1,250 @@
+package coalesce
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tts/internal/models"
+	"tts/internal/tts"
+)
+
+// gatedService 的合成请求在 gate 关闭前阻塞，便于让多个请求同时进行
+type gatedService struct {
+	gate    chan struct{}
+	calls   atomic.Int32
+	streams atomic.Int32 // 以 SynthesizeStream 发起的调用数
+	err     error
+}
+
+func (s *gatedService) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	return nil, nil
+}
+
+func (s *gatedService) wait(ctx context.Context) error {
+	s.calls.Add(1)
+	select {
+	case <-s.gate:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return s.err
+}
+
+func (s *gatedService) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return &models.TTSResponse{AudioContent: []byte(req.Text), ContentType: "audio/mpeg", Fallback: req.Voice == "fallback"}, nil
+}
+
+func (s *gatedService) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	s.streams.Add(1)
+	if err := s.wait(ctx); err != nil {
+		return nil, "", err
+	}
+	return io.NopCloser(bytes.NewReader([]byte(req.Text))), "audio/mpeg", nil
+}
+
+func (s *gatedService) WarmupVoicesCache(ctx context.Context) error {
+	return nil
+}
+
+func TestCoalesce(t *testing.T) {
+	tests := []struct {
+		name      string
+		texts     []string
+		bypass    bool
+		err       error
+		wantCalls int32
+	}{
+		{"identical requests share a call", []string{"a", "a", "a"}, false, nil, 1},
+		{"whitespace differences share a call", []string{"a b", " a  b "}, false, nil, 1},
+		{"different requests", []string{"a", "b"}, false, nil, 2},
+		{"bypass", []string{"a", "a"}, true, nil, 2},
+		{"shared failure", []string{"a", "a"}, false, errors.New("upstream"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &gatedService{gate: make(chan struct{}), err: tt.err}
+			s := NewService(inner)
+
+			var joined atomic.Int32
+			var wg sync.WaitGroup
+			results := make([]*models.TTSResponse, len(tt.texts))
+			errs := make([]error, len(tt.texts))
+			for i, text := range tt.texts {
+				ctx := context.Background()
+				if tt.bypass {
+					ctx = WithoutCoalescing(ctx)
+				}
+				var flag atomic.Bool
+				ctx = ReportJoin(ctx, &flag)
+
+				// 依次发起，保证后面的请求能加入前面的调用
+				started := make(chan struct{})
+				wg.Add(1)
+				go func(i int, text string) {
+					defer wg.Done()
+					close(started)
+					results[i], errs[i] = s.SynthesizeSpeech(ctx, models.TTSRequest{Text: text, Voice: "v"})
+					if flag.Load() {
+						joined.Add(1)
+					}
+				}(i, text)
+				<-started
+				waitFor(t, func() bool { return inner.calls.Load()+int32(s.Stats()["coalesced"].(uint64)) == int32(i+1) })
+			}
+			close(inner.gate)
+			wg.Wait()
+
+			if got := inner.calls.Load(); got != tt.wantCalls {
+				t.Fatalf("upstream calls = %d, want %d", got, tt.wantCalls)
+			}
+			if want := int32(len(tt.texts)) - tt.wantCalls; joined.Load() != want {
+				t.Fatalf("joined = %d, want %d", joined.Load(), want)
+			}
+			for i, text := range tt.texts {
+				if tt.err != nil {
+					if !errors.Is(errs[i], tt.err) {
+						t.Fatalf("request %d err = %v, want %v", i, errs[i], tt.err)
+					}
+					continue
+				}
+				if errs[i] != nil {
+					t.Fatalf("request %d: %v", i, errs[i])
+				}
+				// 合并的请求读到发起者的音频
+				want := text
+				if tt.wantCalls == 1 {
+					want = tt.texts[0]
+				}
+				if got := string(results[i].AudioContent); got != want {
+					t.Fatalf("request %d audio = %q, want %q", i, got, want)
+				}
+			}
+			if inFlight := s.Stats()["in_flight"]; inFlight != 0 {
+				t.Fatalf("in_flight = %v after completion", inFlight)
+			}
+		})
+	}
+}
+
+func TestCoalesceCancelledSubscriber(t *testing.T) {
+	inner := &gatedService{gate: make(chan struct{})}
+	s := NewService(inner)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.SynthesizeSpeech(ctx, models.TTSRequest{Text: "a"})
+		done <- err
+	}()
+	waitFor(t, func() bool { return inner.calls.Load() == 1 })
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+
+	// 唯一的订阅者离开后上游调用被取消，相同请求重新发起调用
+	waitFor(t, func() bool { return s.Stats()["in_flight"] == 0 })
+	close(inner.gate)
+	resp, err := s.SynthesizeSpeech(context.Background(), models.TTSRequest{Text: "a"})
+	if err != nil || string(resp.AudioContent) != "a" {
+		t.Fatalf("resp = %v, err = %v", resp, err)
+	}
+	if got := inner.calls.Load(); got != 2 {
+		t.Fatalf("upstream calls = %d, want 2", got)
+	}
+}
+
+// TestCoalesceUpstreamCall 检查上游调用方式跟随发起者：非流式请求走 SynthesizeSpeech，
+// 让故障转移层看到读取音频中途的失败
+func TestCoalesceUpstreamCall(t *testing.T) {
+	tests := []struct {
+		name        string
+		leader      bool // 发起者是否为流式请求
+		joiner      bool // 加入者是否为流式请求
+		voice       string
+		wantStreams int32
+	}{
+		{"speech leads", false, false, "v", 0},
+		{"stream joins speech", false, true, "v", 0},
+		{"stream leads", true, true, "v", 1},
+		{"speech joins stream", true, false, "v", 1},
+		{"fallback from speech", false, true, "fallback", 0},
+	}
+
+	synthesize := func(s *Service, stream bool, req models.TTSRequest) (string, bool, error) {
+		if !stream {
+			resp, err := s.SynthesizeSpeech(context.Background(), req)
+			if err != nil {
+				return "", false, err
+			}
+			return string(resp.AudioContent), resp.Fallback, nil
+		}
+		body, _, err := s.SynthesizeStream(context.Background(), req)
+		if err != nil {
+			return "", false, err
+		}
+		defer body.Close()
+		audio, err := io.ReadAll(body)
+		return string(audio), tts.IsFallback(body), err
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &gatedService{gate: make(chan struct{})}
+			s := NewService(inner)
+			req := models.TTSRequest{Text: "a", Voice: tt.voice}
+
+			var wg sync.WaitGroup
+			audio := make([]string, 2)
+			fallback := make([]bool, 2)
+			for i, stream := range []bool{tt.leader, tt.joiner} {
+				wg.Add(1)
+				go func(i int, stream bool) {
+					defer wg.Done()
+					var err error
+					if audio[i], fallback[i], err = synthesize(s, stream, req); err != nil {
+						t.Error(err)
+					}
+				}(i, stream)
+				waitFor(t, func() bool { return inner.calls.Load()+int32(s.Stats()["coalesced"].(uint64)) == int32(i+1) })
+			}
+			close(inner.gate)
+			wg.Wait()
+
+			if got := inner.streams.Load(); got != tt.wantStreams {
+				t.Fatalf("stream calls = %d, want %d", got, tt.wantStreams)
+			}
+			if got := inner.calls.Load(); got != 1 {
+				t.Fatalf("upstream calls = %d, want 1", got)
+			}
+			for i := range audio {
+				if audio[i] != "a" {
+					t.Fatalf("request %d audio = %q", i, audio[i])
+				}
+				if want := tt.voice == "fallback"; fallback[i] != want {
+					t.Fatalf("request %d fallback = %v, want %v", i, fallback[i], want)
+				}
+			}
+		})
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal("condition not met")
+}
@@ -12,10 +12,30 @@ import (
 
 // Config 包含应用程序的所有配置
 type Config struct {
-	Server ServerConfig `mapstructure:"server"`
-	TTS    TTSConfig    `mapstructure:"tts"`
-	SSML   SSMLConfig   `mapstructure:"ssml"`
-	CORS   CORSConfig   `mapstructure:"cors"`
+	Server    ServerConfig     `mapstructure:"server"`
+	TTS       TTSConfig        `mapstructure:"tts"`
+	SSML      SSMLConfig       `mapstructure:"ssml"`
+	CORS      CORSConfig       `mapstructure:"cors"`
+	Providers []ProviderConfig `mapstructure:"providers"`
+
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	Cache          CacheConfig          `mapstructure:"cache"`
+}
+
+// CacheConfig 包含合成音频缓存的配置
+type CacheConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	TTL              int    `mapstructure:"ttl"`                // 缓存有效期（秒），0 表示不过期
+	MemoryMaxMB      int    `mapstructure:"memory_max_mb"`      // 内存层最大容量
+	MemoryMaxEntries int    `mapstructure:"memory_max_entries"` // 内存层最大条目数
+	DiskDir          string `mapstructure:"disk_dir"`           // 磁盘层目录，为空时不启用
+	DiskMaxMB        int    `mapstructure:"disk_max_mb"`        // 磁盘层最大容量
+}
+
+// CircuitBreakerConfig 包含故障转移链中熔断器的配置
+type CircuitBreakerConfig struct {
+	FailureThreshold int `mapstructure:"failure_threshold"` // 连续失败多少次后熔断
+	OpenTimeout      int `mapstructure:"open_timeout"`      // 熔断后多少秒允许一次半开探测
 }
 
 // ServerConfig 包含HTTP服务器配置
@@ -38,19 +58,133 @@ type CORSConfig struct {
 
 // TTSConfig 包含Microsoft TTS API配置
 type TTSConfig struct {
-	ApiKey            string            `mapstructure:"api_key"`
-	Region            string            `mapstructure:"region"`
-	DefaultVoice      string            `mapstructure:"default_voice"`
-	DefaultRate       string            `mapstructure:"default_rate"`
-	DefaultPitch      string            `mapstructure:"default_pitch"`
-	DefaultFormat     string            `mapstructure:"default_format"`
-	MaxTextLength     int               `mapstructure:"max_text_length"`
-	RequestTimeout    int               `mapstructure:"request_timeout"`
-	MaxConcurrent     int               `mapstructure:"max_concurrent"`
-	SegmentThreshold  int               `mapstructure:"segment_threshold"`
-	MinSentenceLength int               `mapstructure:"min_sentence_length"`
-	MaxSentenceLength int               `mapstructure:"max_sentence_length"`
-	VoiceMapping      map[string]string `mapstructure:"voice_mapping"`
+	ApiKey            string               `mapstructure:"api_key"`
+	Region            string               `mapstructure:"region"`
+	DefaultVoice      string               `mapstructure:"default_voice"`
+	DefaultRate       string               `mapstructure:"default_rate"`
+	DefaultPitch      string               `mapstructure:"default_pitch"`
+	DefaultFormat     string               `mapstructure:"default_format"`
+	MaxTextLength     int                  `mapstructure:"max_text_length"`
+	RequestTimeout    int                  `mapstructure:"request_timeout"`
+	MaxConcurrent     int                  `mapstructure:"max_concurrent"`
+	SegmentThreshold  int                  `mapstructure:"segment_threshold"`
+	MinSentenceLength int                  `mapstructure:"min_sentence_length"`
+	MaxSentenceLength int                  `mapstructure:"max_sentence_length"`
+	VoiceMapping      map[string]string    `mapstructure:"voice_mapping"`
+	AuthMode          string               `mapstructure:"auth_mode"`           // 认证方式: endpoint(默认) 或 azure
+	SubscriptionKey   string               `mapstructure:"subscription_key"`    // Azure 语音服务订阅密钥，auth_mode 为 azure 时使用
+	StateFile         string               `mapstructure:"state_file"`          // 持久化语音列表和认证令牌的状态文件，为空时不持久化
+	TokenPoolSize     int                  `mapstructure:"token_pool_size"`     // 令牌池大小，每个令牌独立获取，默认 1
+	TokenPoolStrategy string               `mapstructure:"token_pool_strategy"` // 令牌选择策略：round_robin(默认) 或 lru
+	Regions           []string             `mapstructure:"regions"`             // 候选区域，为空时使用令牌返回的区域；只配一个即固定区域
+	Retry             RetryConfig          `mapstructure:"retry"`               // 合成请求的重试策略
+	Hedge             HedgeConfig          `mapstructure:"hedge"`               // 分段请求的对冲
+	SegmentFailure    SegmentFailureConfig `mapstructure:"segment_failure"`     // 分段请求中单段失败时的处理方式
+	SegmentStreaming  bool                 `mapstructure:"segment_streaming"`   // 分段请求按顺序边合成边输出，请求参数 stream 可覆盖
+	Merge             MergeConfig          `mapstructure:"merge"`               // 分段音频合并时的停顿和后处理
+	Metadata          MetadataConfig       `mapstructure:"metadata"`            // MP3 输出的 ID3 元数据
+
+	// 上游地址，为空时使用 Microsoft 官方地址；%s 会替换为区域
+	EndpointURL  string `mapstructure:"endpoint_url"`
+	VoicesURL    string `mapstructure:"voices_url"`
+	SynthesisURL string `mapstructure:"synthesis_url"`
+	WebSocketURL string `mapstructure:"websocket_url"`
+}
+
+const (
+	// AuthModeEndpoint 通过翻译器端点获取临时令牌
+	AuthModeEndpoint = "endpoint"
+	// AuthModeAzure 使用 Azure 语音服务订阅密钥和区域
+	AuthModeAzure = "azure"
+)
+
+const (
+	// TokenStrategyRoundRobin 按顺序轮流使用令牌池中的令牌
+	TokenStrategyRoundRobin = "round_robin"
+	// TokenStrategyLRU 优先使用最久未使用的令牌
+	TokenStrategyLRU = "lru"
+)
+
+// RetryConfig 包含合成请求的重试策略，字段为零值时使用默认值
+type RetryConfig struct {
+	MaxAttempts    int      `mapstructure:"max_attempts"`    // 最多尝试次数（含首次），默认 3
+	InitialBackoff int      `mapstructure:"initial_backoff"` // 首次退避时间（毫秒），之后每次翻倍，默认 200
+	MaxBackoff     int      `mapstructure:"max_backoff"`     // 最长退避时间（毫秒），默认 5000
+	RetryOn        []string `mapstructure:"retry_on"`        // 可重试的类别：auth、429、5xx、network，默认全部
+}
+
+// HedgeConfig 包含分段请求对冲的配置，字段为零值时使用默认值
+type HedgeConfig struct {
+	Enabled    bool    `mapstructure:"enabled"`
+	Percentile float64 `mapstructure:"percentile"`  // 超过近期延迟的该分位数仍未返回时发出对冲请求，默认 95
+	MinDelay   int     `mapstructure:"min_delay"`   // 对冲等待时间下限（毫秒），默认 100
+	Budget     float64 `mapstructure:"budget"`      // 对冲请求占分段请求的最大比例，默认 0.1
+	MinSamples int     `mapstructure:"min_samples"` // 样本数达到该值后才开始对冲，默认 20
+}
+
+const (
+	// SegmentFailureFail 任一段最终失败时整个请求失败
+	SegmentFailureFail = "fail"
+	// SegmentFailureSilence 以估算时长的静音代替最终失败的段
+	SegmentFailureSilence = "silence"
+)
+
+// SegmentFailureConfig 包含分段请求中单段失败时的重试和降级配置
+type SegmentFailureConfig struct {
+	Retries      int    `mapstructure:"retries"`       // 单段失败后的额外重试次数，0 表示不重试
+	RetryBackoff int    `mapstructure:"retry_backoff"` // 首次重试前的等待时间（毫秒），之后每次翻倍，默认 500
+	Mode         string `mapstructure:"mode"`          // fail(默认) 或 silence
+}
+
+// MergeConfig 包含分段音频合并时的停顿和后处理配置，字段为零值时使用默认值
+type MergeConfig struct {
+	SentencePause    int     `mapstructure:"sentence_pause"`    // 同一段落内相邻分段之间插入的静音（毫秒），0 表示不插入
+	ParagraphPause   int     `mapstructure:"paragraph_pause"`   // 段落之间插入的静音（毫秒），0 表示不插入
+	TrimSilence      bool    `mapstructure:"trim_silence"`      // 裁剪每段首尾的静音
+	SilenceThreshold float64 `mapstructure:"silence_threshold"` // 静音判定阈值（dBFS），默认 -50
+	Normalize        bool    `mapstructure:"normalize"`         // 把每段的响度调整到 target_loudness
+	TargetLoudness   float64 `mapstructure:"target_loudness"`   // 目标响度（RMS dBFS），默认 -20
+}
+
+// MetadataConfig 包含写入 MP3 输出的 ID3 元数据配置
+type MetadataConfig struct {
+	Enabled bool   `mapstructure:"enabled"` // 写入标题、艺术家等元数据，分段请求还写入章节；请求参数 metadata 可覆盖
+	Album   string `mapstructure:"album"`   // 默认专辑名，请求中的 album 优先
+	Cover   string `mapstructure:"cover"`   // 默认封面图片文件（JPEG/PNG），请求中的 cover 优先
+}
+
+// ProviderConfig 描述一个命名的TTS后端
+type ProviderConfig struct {
+	Name          string   `mapstructure:"name"`           // 后端名称，请求中通过 provider 字段或 "名称:语音" 前缀引用
+	Type          string   `mapstructure:"type"`           // 后端类型，如 microsoft
+	VoicePrefixes []string `mapstructure:"voice_prefixes"` // 匹配这些前缀的语音路由到该后端
+	Default       bool     `mapstructure:"default"`        // 未匹配到任何后端时使用
+	Fallbacks     []string `mapstructure:"fallbacks"`      // 该后端熔断或失败时依次尝试的后端名称
+
+	// 以下字段为空时沿用 tts 段的配置
+	AuthMode        string      `mapstructure:"auth_mode"`
+	Region          string      `mapstructure:"region"`
+	Regions         []string    `mapstructure:"regions"`
+	SubscriptionKey string      `mapstructure:"subscription_key"`
+	DefaultVoice    string      `mapstructure:"default_voice"`
+	StateFile       string      `mapstructure:"state_file"` // 为空时由 tts.state_file 加上后端名称派生
+	Retry           RetryConfig `mapstructure:"retry"`      // 非零字段覆盖 tts.retry
+
+	// 本地引擎（type 为 local）配置
+	Engine    string `mapstructure:"engine"`     // espeak-ng 或 piper
+	Command   string `mapstructure:"command"`    // 可执行文件路径，为空时使用引擎名
+	VoicesDir string `mapstructure:"voices_dir"` // 语音数据目录
+}
+
+// DefaultProviderName 未配置 providers 时使用的后端名称
+const DefaultProviderName = "microsoft"
+
+// ProviderList 返回配置的后端列表，未配置时返回单个 Microsoft 后端
+func (c *Config) ProviderList() []ProviderConfig {
+	if len(c.Providers) > 0 {
+		return c.Providers
+	}
+	return []ProviderConfig{{Name: DefaultProviderName, Type: "microsoft", Default: true}}
 }
 
 var (
@@ -0,0 +1,268 @@
+// Package hedge 为分段合成请求提供对冲：请求超过近期延迟的某个分位数仍未返回时，再发一个相同请求，取先返回的结果
+package hedge
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tts/internal/coalesce"
+	"tts/internal/config"
+	"tts/internal/models"
+	"tts/internal/tts"
+)
+
+const (
+	defaultPercentile = 95
+	defaultMinDelay   = 100 * time.Millisecond
+	defaultBudget     = 0.1
+	defaultMinSamples = 20
+
+	// 参与计算分位数的最近样本数
+	sampleWindow = 256
+	// 对冲预算的令牌桶上限，允许短时间内集中对冲
+	budgetBurst = 10
+)
+
+// Service 为 tts.Service 的 SynthesizeSpeech 加上请求对冲
+type Service struct {
+	inner tts.Service
+
+	percentile float64
+	minDelay   time.Duration
+	budget     float64
+	minSamples int
+
+	mu      sync.Mutex
+	samples []time.Duration // 最近独立上游调用成功的耗时，环形缓冲
+	next    int
+	tokens  float64 // 对冲预算，每个请求增加 budget，每次对冲消耗 1
+
+	requests  uint64
+	hedged    uint64 // 发出的对冲请求数
+	hedgeWins uint64 // 对冲请求先返回的次数
+	denied    uint64 // 因预算不足未对冲的次数
+}
+
+// NewService 按配置用请求对冲包装一个 tts.Service
+func NewService(inner tts.Service, cfg config.HedgeConfig) *Service {
+	s := &Service{
+		inner:      inner,
+		percentile: cfg.Percentile,
+		minDelay:   time.Duration(cfg.MinDelay) * time.Millisecond,
+		budget:     cfg.Budget,
+		minSamples: cfg.MinSamples,
+		samples:    make([]time.Duration, 0, sampleWindow),
+	}
+	if s.percentile <= 0 || s.percentile >= 100 {
+		s.percentile = defaultPercentile
+	}
+	if s.minDelay <= 0 {
+		s.minDelay = defaultMinDelay
+	}
+	if s.budget <= 0 {
+		s.budget = defaultBudget
+	}
+	if s.minSamples <= 0 {
+		s.minSamples = defaultMinSamples
+	}
+	return s
+}
+
+// segmentKey 标记分段合成请求的上下文
+type segmentKey struct{}
+
+// WithSegment 返回标记为分段合成请求的上下文，只有这类请求会被对冲并计入延迟样本
+func WithSegment(ctx context.Context) context.Context {
+	return context.WithValue(ctx, segmentKey{}, true)
+}
+
+type result struct {
+	resp   *models.TTSResponse
+	err    error
+	hedged bool
+}
+
+// SynthesizeSpeech 分段请求超过近期延迟分位数仍未返回时发出对冲请求，取先成功的结果并取消另一个；
+// 其余请求直接转发
+func (s *Service) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	if ctx.Value(segmentKey{}) == nil {
+		return s.inner.SynthesizeSpeech(ctx, req)
+	}
+	delay, ok := s.admit()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, 2)
+	call := func(ctx context.Context, hedged bool) {
+		// 合并到进行中调用的请求只等待了部分上游耗时，不计入延迟样本
+		var joined atomic.Bool
+		start := time.Now()
+		resp, err := s.inner.SynthesizeSpeech(coalesce.ReportJoin(ctx, &joined), req)
+		if err == nil && !joined.Load() {
+			s.observe(time.Since(start))
+		}
+		results <- result{resp: resp, err: err, hedged: hedged}
+	}
+	go call(ctx, false)
+
+	if !ok {
+		r := <-results
+		return r.resp, r.err
+	}
+
+	timer := time.NewTimer(delay)
+	select {
+	case r := <-results:
+		timer.Stop()
+		return r.resp, r.err
+	case <-timer.C:
+	case <-ctx.Done():
+		timer.Stop()
+		r := <-results
+		return r.resp, r.err
+	}
+
+	if !s.takeBudget() {
+		r := <-results
+		return r.resp, r.err
+	}
+	log.Printf("分段请求超过 %v 未返回，发出对冲请求 (文本长度: %d)", delay.Round(time.Millisecond), len(req.Text))
+	// 对冲请求不能合并到原请求上，否则只是等待同一个上游调用
+	go call(coalesce.WithoutCoalescing(ctx), true)
+
+	first := <-results
+	if first.err != nil {
+		second := <-results
+		if second.err == nil {
+			first = second
+		}
+	}
+	if first.err == nil && first.hedged {
+		s.mu.Lock()
+		s.hedgeWins++
+		s.mu.Unlock()
+	}
+	return first.resp, first.err
+}
+
+// admit 记录一个请求，返回对冲等待时间；样本不足时不对冲
+func (s *Service) admit() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	s.tokens = min(s.tokens+s.budget, budgetBurst)
+	if len(s.samples) < s.minSamples {
+		return 0, false
+	}
+	return max(s.percentileLocked(), s.minDelay), true
+}
+
+// takeBudget 消耗一次对冲预算
+func (s *Service) takeBudget() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens < 1 {
+		s.denied++
+		return false
+	}
+	s.tokens--
+	s.hedged++
+	return true
+}
+
+// observe 记录一次独立上游调用成功的耗时
+func (s *Service) observe(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.samples) < sampleWindow {
+		s.samples = append(s.samples, d)
+		return
+	}
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % sampleWindow
+}
+
+// percentileLocked 计算最近样本的分位数，调用方需持有锁
+func (s *Service) percentileLocked() time.Duration {
+	sorted := make([]time.Duration, len(s.samples))
+	copy(sorted, s.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	index := int(float64(len(sorted)-1) * s.percentile / 100)
+	return sorted[index]
+}
+
+// ListVoices 直接转发
+func (s *Service) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	return s.inner.ListVoices(ctx, locale)
+}
+
+// WarmupVoicesCache 直接转发
+func (s *Service) WarmupVoicesCache(ctx context.Context) error {
+	return s.inner.WarmupVoicesCache(ctx)
+}
+
+// SynthesizeStream 流式响应已开始写给客户端，不做对冲，直接转发
+func (s *Service) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	return s.inner.SynthesizeStream(ctx, req)
+}
+
+// SynthesizeWithEvents 直接转发
+func (s *Service) SynthesizeWithEvents(ctx context.Context, req models.TTSRequest) (*models.TTSEventsResponse, error) {
+	synthesizer, ok := s.inner.(tts.EventSynthesizer)
+	if !ok {
+		return nil, errors.New("当前后端不支持合成事件")
+	}
+	return synthesizer.SynthesizeWithEvents(ctx, req)
+}
+
+// CatalogueStatus 直接转发
+func (s *Service) CatalogueStatus() map[string]interface{} {
+	if reporter, ok := s.inner.(tts.CatalogueReporter); ok {
+		return reporter.CatalogueStatus()
+	}
+	return nil
+}
+
+// Stats 返回对冲统计
+func (s *Service) Stats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"requests":   s.requests,
+		"hedged":     s.hedged,
+		"hedge_wins": s.hedgeWins,
+		"denied":     s.denied,
+		"samples":    len(s.samples),
+	}
+	if len(s.samples) >= s.minSamples {
+		stats["delay_ms"] = max(s.percentileLocked(), s.minDelay).Milliseconds()
+	}
+	return stats
+}
+
+// Health 附加对冲统计
+func (s *Service) Health() map[string]interface{} {
+	health := map[string]interface{}{}
+	if reporter, ok := s.inner.(tts.HealthReporter); ok {
+		health = reporter.Health()
+	}
+	health["hedge"] = s.Stats()
+	return health
+}
+
+// Close 直接转发
+func (s *Service) Close() error {
+	if closer, ok := s.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
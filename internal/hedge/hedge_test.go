@@ -0,0 +1,182 @@
+package hedge
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tts/internal/coalesce"
+	"tts/internal/config"
+	"tts/internal/models"
+)
+
+// delayService 按调用顺序取 delays 中的耗时返回，超出部分立即返回
+type delayService struct {
+	delays []time.Duration
+	calls  atomic.Int32
+	gate   chan struct{} // 不为 nil 时请求等待其关闭
+}
+
+func (s *delayService) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	return nil, nil
+}
+
+func (s *delayService) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	n := int(s.calls.Add(1)) - 1
+	if s.gate != nil {
+		<-s.gate
+	}
+	if n < len(s.delays) {
+		select {
+		case <-time.After(s.delays[n]):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return &models.TTSResponse{AudioContent: []byte{byte(n)}}, nil
+}
+
+func (s *delayService) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	s.calls.Add(1)
+	if s.gate != nil {
+		<-s.gate
+	}
+	return io.NopCloser(bytes.NewReader([]byte(req.Text))), "audio/mpeg", nil
+}
+
+func (s *delayService) WarmupVoicesCache(ctx context.Context) error {
+	return nil
+}
+
+func TestHedge(t *testing.T) {
+	tests := []struct {
+		name       string
+		segment    bool
+		samples    []time.Duration
+		delays     []time.Duration
+		wantCalls  int32
+		wantHedged uint64
+		wantWins   uint64
+	}{
+		{
+			name:      "non-segment requests pass through",
+			samples:   []time.Duration{time.Millisecond, time.Millisecond},
+			delays:    []time.Duration{50 * time.Millisecond},
+			wantCalls: 1,
+		},
+		{
+			name:      "too few samples",
+			segment:   true,
+			samples:   []time.Duration{time.Millisecond},
+			delays:    []time.Duration{50 * time.Millisecond},
+			wantCalls: 1,
+		},
+		{
+			name:      "fast request is not hedged",
+			segment:   true,
+			samples:   []time.Duration{time.Second, time.Second},
+			delays:    []time.Duration{time.Millisecond},
+			wantCalls: 1,
+		},
+		{
+			name:       "slow request is hedged",
+			segment:    true,
+			samples:    []time.Duration{time.Millisecond, time.Millisecond},
+			delays:     []time.Duration{time.Second},
+			wantCalls:  2,
+			wantHedged: 1,
+			wantWins:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &delayService{delays: tt.delays}
+			s := NewService(inner, config.HedgeConfig{Enabled: true, MinDelay: 10, Budget: 1, MinSamples: 2})
+			s.samples = append(s.samples, tt.samples...)
+
+			ctx := context.Background()
+			if tt.segment {
+				ctx = WithSegment(ctx)
+			}
+			if _, err := s.SynthesizeSpeech(ctx, models.TTSRequest{Text: "a"}); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := inner.calls.Load(); got != tt.wantCalls {
+				t.Errorf("upstream calls = %d, want %d", got, tt.wantCalls)
+			}
+			stats := s.Stats()
+			if stats["hedged"] != tt.wantHedged || stats["hedge_wins"] != tt.wantWins {
+				t.Errorf("hedged = %v, wins = %v, want %d, %d", stats["hedged"], stats["hedge_wins"], tt.wantHedged, tt.wantWins)
+			}
+			wantSamples := len(tt.samples)
+			if tt.segment {
+				wantSamples++
+			}
+			if stats["samples"] != wantSamples {
+				t.Errorf("samples = %v, want %d", stats["samples"], wantSamples)
+			}
+		})
+	}
+}
+
+func TestHedgeIgnoresCoalescedLatency(t *testing.T) {
+	inner := &delayService{gate: make(chan struct{})}
+	merged := coalesce.NewService(inner)
+	s := NewService(merged, config.HedgeConfig{Enabled: true, MinSamples: 100})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.SynthesizeSpeech(WithSegment(context.Background()), models.TTSRequest{Text: "a"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for deadline := time.Now().Add(2 * time.Second); merged.Stats()["coalesced"] != uint64(2); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("requests were not coalesced")
+		}
+	}
+	close(inner.gate)
+	wg.Wait()
+
+	if got := s.Stats()["samples"]; got != 1 {
+		t.Fatalf("samples = %v, want 1", got)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	ms := func(values ...int) []time.Duration {
+		out := make([]time.Duration, len(values))
+		for i, v := range values {
+			out[i] = time.Duration(v) * time.Millisecond
+		}
+		return out
+	}
+
+	tests := []struct {
+		percentile float64
+		samples    []time.Duration
+		want       time.Duration
+	}{
+		{50, ms(1), time.Millisecond},
+		{50, ms(5, 1, 3), 3 * time.Millisecond},
+		{95, ms(10, 20, 30, 40, 50, 60, 70, 80, 90, 100), 90 * time.Millisecond},
+		{99, ms(100, 1, 2, 3), 3 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		s := &Service{percentile: tt.percentile, samples: tt.samples}
+		if got := s.percentileLocked(); got != tt.want {
+			t.Errorf("p%v of %v = %v, want %v", tt.percentile, tt.samples, got, tt.want)
+		}
+	}
+}
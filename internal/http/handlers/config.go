@@ -51,6 +51,7 @@ func (h *ConfigHandler) HandleConfig(c *gin.Context) {
 			"locale":   voice.Locale,
 			"gender":   voice.Gender,
 			"styles":   voice.StyleList,
+			"provider": voice.Provider,
 		}
 		voiceList = append(voiceList, voiceInfo)
 
@@ -67,7 +68,7 @@ func (h *ConfigHandler) HandleConfig(c *gin.Context) {
 	}
 
 	// 返回配置信息
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"defaultVoice":  h.config.TTS.DefaultVoice,
 		"defaultRate":   h.config.TTS.DefaultRate,
 		"defaultPitch":  h.config.TTS.DefaultPitch,
@@ -75,7 +76,12 @@ func (h *ConfigHandler) HandleConfig(c *gin.Context) {
 		"basePath":      h.config.Server.BasePath,
 		"voices":        voiceList,
 		"styles":        styles,
-	})
+	}
+	// 语音列表来源及快照时间，上游不可用时可据此判断列表是否陈旧
+	if reporter, ok := h.ttsService.(tts.CatalogueReporter); ok {
+		resp["voicesSnapshot"] = reporter.CatalogueStatus()
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // HandleReload 处理配置热重载请求
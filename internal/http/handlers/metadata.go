@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tts/internal/audio"
+	"tts/internal/models"
+	"tts/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metadataTitleLength 从文本生成标题或章节名时保留的最大字符数
+const metadataTitleLength = 50
+
+var ssmlTagPattern = regexp.MustCompile(`<[^>]+>`)
+
+// id3Metadata 返回应写入 MP3 输出的 ID3 标签（不含章节），输出不是 MP3 或未启用元数据时返回 nil
+func (h *TTSHandler) id3Metadata(c *gin.Context, req models.TTSRequest, output string) (*audio.ID3Tag, error) {
+	f, err := audio.ParseFormat(output)
+	if err != nil || f.Container != audio.ContainerMP3 || !h.metadataEnabled(c, req) {
+		return nil, nil
+	}
+
+	tag := &audio.ID3Tag{
+		Title:  req.Title,
+		Artist: h.voiceDisplayName(c.Request.Context(), req.Voice),
+		Album:  req.Album,
+	}
+	if tag.Title == "" {
+		tag.Title = metadataTitle(ssmlTagPattern.ReplaceAllString(req.Text, ""))
+	}
+	if tag.Album == "" {
+		tag.Album = h.config.TTS.Metadata.Album
+	}
+
+	if req.Cover != "" {
+		cover, err := decodeCover(req.Cover)
+		if err != nil {
+			return nil, err
+		}
+		tag.Cover = cover
+	} else if path := h.config.TTS.Metadata.Cover; path != "" {
+		if cover, err := os.ReadFile(path); err != nil {
+			log.Printf("读取封面图片失败: %v", err)
+		} else {
+			tag.Cover = cover
+		}
+	}
+	if len(tag.Cover) > 0 {
+		tag.CoverMIME = http.DetectContentType(tag.Cover)
+		if tag.CoverMIME != "image/jpeg" && tag.CoverMIME != "image/png" {
+			if req.Cover != "" {
+				return nil, errors.New("封面图片只支持 JPEG 和 PNG")
+			}
+			log.Printf("封面图片格式 %s 不受支持，忽略", tag.CoverMIME)
+			tag.Cover, tag.CoverMIME = nil, ""
+		}
+	}
+	return tag, nil
+}
+
+// metadataEnabled 判断是否写入元数据，请求参数 metadata 优先，其次为请求中的 title、album、cover 和 metadata.enabled 配置
+func (h *TTSHandler) metadataEnabled(c *gin.Context, req models.TTSRequest) bool {
+	if value := c.Query("metadata"); value != "" {
+		if enabled, err := strconv.ParseBool(value); err == nil {
+			return enabled
+		}
+	}
+	return h.config.TTS.Metadata.Enabled || req.Title != "" || req.Album != "" || req.Cover != ""
+}
+
+// voiceDisplayName 返回语音的显示名称，找不到时返回语音名本身
+func (h *TTSHandler) voiceDisplayName(ctx context.Context, voice string) string {
+	voices, err := h.ttsService.ListVoices(ctx, "")
+	if err != nil {
+		log.Printf("获取语音列表失败，艺术家使用语音名: %v", err)
+		return voice
+	}
+	for _, v := range voices {
+		if (v.ShortName == voice || v.Name == voice) && v.DisplayName != "" {
+			return v.DisplayName
+		}
+	}
+	return voice
+}
+
+// decodeCover 解码 base64 封面图片，可带 data:image/...;base64, 前缀
+func decodeCover(cover string) ([]byte, error) {
+	if strings.HasPrefix(cover, "data:") {
+		if i := strings.Index(cover, ","); i >= 0 {
+			cover = cover[i+1:]
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(cover))
+	if err != nil {
+		return nil, errors.New("cover 不是有效的 base64 图片")
+	}
+	return data, nil
+}
+
+// metadataTitle 取文本第一个非空行作为标题，去掉 Markdown 标题符号并截断
+func metadataTitle(text string) string {
+	lines := utils.SplitAndFilterEmptyLines(text)
+	if len(lines) == 0 {
+		return ""
+	}
+	title := strings.TrimSpace(strings.TrimLeft(lines[0], "#"))
+	if utf8.RuneCountInString(title) > metadataTitleLength {
+		title = string([]rune(title)[:metadataTitleLength]) + "…"
+	}
+	return title
+}
+
+// piecesDuration 返回一段音频各部分的总时长
+func piecesDuration(format string, pieces [][]byte) (time.Duration, error) {
+	f, err := audio.ParseFormat(format)
+	if err != nil {
+		return 0, err
+	}
+	var total time.Duration
+	for _, piece := range pieces {
+		d, err := audio.Duration(f, piece)
+		if err != nil {
+			return 0, err
+		}
+		total += d
+	}
+	return total, nil
+}
+
+// segmentChapters 按标题（没有标题时按段落）把各段划分为章节，durations 为各段音频及其后停顿的时长
+func segmentChapters(segments []textSegment, durations []time.Duration) []audio.Chapter {
+	hasHeading := false
+	for _, segment := range segments {
+		hasHeading = hasHeading || segment.heading
+	}
+
+	var chapters []audio.Chapter
+	var offset time.Duration
+	for i, segment := range segments {
+		start := i == 0
+		if hasHeading {
+			start = start || segment.heading
+		} else {
+			start = start || segments[i-1].paragraphEnd
+		}
+		if start {
+			if len(chapters) > 0 {
+				chapters[len(chapters)-1].End = offset
+			}
+			chapters = append(chapters, audio.Chapter{Title: metadataTitle(segment.text), Start: offset})
+		}
+		offset += durations[i]
+	}
+	chapters[len(chapters)-1].End = offset
+
+	// 只有一个章节时不写入
+	if len(chapters) < 2 {
+		return nil
+	}
+	return chapters
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"tts/internal/audio"
+	"tts/internal/config"
+	"tts/internal/hedge"
+	"tts/internal/models"
+	"tts/internal/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// degradedSegmentsHeader 列出以静音代替的分段序号（从 1 开始，逗号分隔）
+const degradedSegmentsHeader = "X-Degraded-Segments"
+
+// defaultSegmentRetryBackoff 未配置 retry_backoff 时首次重试前的等待时间
+const defaultSegmentRetryBackoff = 500 * time.Millisecond
+
+// 未配置时合并后处理使用的默认值（dBFS）
+const (
+	defaultSilenceThreshold = -50
+	defaultTargetLoudness   = -20
+)
+
+// segmentJob 保存一次分段请求中各段的合成结果
+type segmentJob struct {
+	audio   [][]byte
+	results []sentenceSynthesisResult
+	ready   []chan struct{} // 对应的段合成成功后关闭
+	errChan chan error      // 第一个最终失败的段的错误
+	done    chan struct{}   // 所有段结束后关闭
+
+	cacheHits int32
+	queueWait atomic.Int64 // 各段在全局调度器中排队的总时间（纳秒）
+
+	mu       sync.Mutex
+	degraded []int // 以静音代替的分段序号
+}
+
+// startSegments 并发合成每一个句子，上游并发由全局调度器控制；某段最终失败时写入错误并取消其余段
+func (h *TTSHandler) startSegments(ctx context.Context, cancel context.CancelFunc, req models.TTSRequest, segments []textSegment) *segmentJob {
+	segmentCount := len(segments)
+	job := &segmentJob{
+		audio:   make([][]byte, segmentCount),
+		results: make([]sentenceSynthesisResult, segmentCount),
+		ready:   make([]chan struct{}, segmentCount),
+		errChan: make(chan error, 1),
+		done:    make(chan struct{}),
+	}
+	degrade := h.config.TTS.SegmentFailure.Mode == config.SegmentFailureSilence
+	ctx = scheduler.ReportWait(ctx, &job.queueWait)
+
+	var wg sync.WaitGroup
+	for i := 0; i < segmentCount; i++ {
+		job.ready[i] = make(chan struct{})
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+
+			segReq := req
+			segReq.Text = segments[index].text
+
+			startTime := time.Now()
+			// 合成该段音频，失败时按配置重试
+			resp, err := h.synthesizeSegment(ctx, segReq, index)
+			synthDuration := time.Since(startTime)
+
+			// 降级模式下以静音代替最终失败的段，请求被取消时不再降级
+			degraded := false
+			if err != nil && degrade && ctx.Err() == nil {
+				if silence, silenceErr := silentSegment(segReq); silenceErr == nil {
+					log.Printf("句子 %d 合成失败，以静音代替: %v", index+1, err)
+					resp, err, degraded = &models.TTSResponse{AudioContent: silence}, nil, true
+					job.mu.Lock()
+					job.degraded = append(job.degraded, index)
+					job.mu.Unlock()
+				} else {
+					log.Printf("句子 %d 无法生成静音: %v", index+1, silenceErr)
+				}
+			}
+
+			if err != nil {
+				select {
+				case job.errChan <- fmt.Errorf("句子 %d 合成失败: %w", index+1, err):
+				default:
+				}
+				cancel()
+				return
+			}
+
+			if resp.CacheHit {
+				atomic.AddInt32(&job.cacheHits, 1)
+			}
+
+			// 收集合成结果信息，而不是立即打印
+			job.results[index] = sentenceSynthesisResult{
+				index:     index,
+				length:    utf8.RuneCountInString(segments[index].text),
+				audioSize: len(resp.AudioContent),
+				content:   truncateForLog(segments[index].text, 20),
+				duration:  synthDuration,
+				cacheHit:  resp.CacheHit,
+				degraded:  degraded,
+			}
+			job.audio[index] = resp.AudioContent
+			close(job.ready[index])
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(job.done)
+	}()
+	return job
+}
+
+// segmentStreamingEnabled 判断是否流式输出分段音频，请求参数 stream 优先于 segment_streaming 配置
+func (h *TTSHandler) segmentStreamingEnabled(c *gin.Context) bool {
+	if value := c.Query("stream"); value != "" {
+		if enabled, err := strconv.ParseBool(value); err == nil {
+			return enabled
+		}
+	}
+	return h.config.TTS.SegmentStreaming
+}
+
+// segmentJoiner 为输出格式创建流式拼接器
+func segmentJoiner(format string, count int) (*audio.Joiner, error) {
+	f, err := audio.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	return audio.NewJoiner(f, count)
+}
+
+// segmentPauses 返回每段之后插入的停顿：段落结尾使用 paragraph_pause，段落内使用 sentence_pause，最后一段之后不插入
+func (h *TTSHandler) segmentPauses(segments []textSegment) []time.Duration {
+	merge := h.config.TTS.Merge
+	pauses := make([]time.Duration, len(segments))
+	for i, segment := range segments[:len(segments)-1] {
+		pause := merge.SentencePause
+		if segment.paragraphEnd {
+			pause = merge.ParagraphPause
+		}
+		pauses[i] = time.Duration(pause) * time.Millisecond
+	}
+	return pauses
+}
+
+// arrangeSegment 对一段音频做合并前的后处理，并在其后附加停顿静音
+func (h *TTSHandler) arrangeSegment(format string, data []byte, pause time.Duration) [][]byte {
+	f, err := audio.ParseFormat(format)
+	if err != nil {
+		return [][]byte{data}
+	}
+
+	pieces := [][]byte{h.postprocessSegment(f, data)}
+	if pause > 0 {
+		silence, err := audio.Silence(f, pause)
+		if err != nil {
+			log.Printf("生成段间停顿失败: %v", err)
+		} else {
+			pieces = append(pieces, silence)
+		}
+	}
+	return pieces
+}
+
+// Postprocessable 判断按该输出格式合成的分段能否裁剪静音和归一化响度：
+// PCM、G.711 格式，以及由 PCM 转码得到的格式可以处理，上游直接提供的 MP3、Opus 等压缩格式不能
+func Postprocessable(format string) bool {
+	if target, err := transcodeTarget(format); err == nil && target != nil {
+		return true
+	}
+	f, err := audio.ParseFormat(format)
+	return err == nil && audio.Processable(f)
+}
+
+// postprocessSegment 按配置裁剪首尾静音并做响度归一化，只处理 PCM 和 G.711 格式，其它格式原样返回
+func (h *TTSHandler) postprocessSegment(f audio.Format, data []byte) []byte {
+	merge := h.config.TTS.Merge
+	if (!merge.TrimSilence && !merge.Normalize) || !audio.Processable(f) {
+		return data
+	}
+
+	if merge.TrimSilence {
+		threshold := merge.SilenceThreshold
+		if threshold == 0 {
+			threshold = defaultSilenceThreshold
+		}
+		if trimmed, err := audio.TrimSilence(f, data, threshold); err != nil {
+			log.Printf("裁剪静音失败: %v", err)
+		} else {
+			data = trimmed
+		}
+	}
+
+	if merge.Normalize {
+		target := merge.TargetLoudness
+		if target == 0 {
+			target = defaultTargetLoudness
+		}
+		if normalized, err := audio.Normalize(f, data, target); err != nil {
+			log.Printf("响度归一化失败: %v", err)
+		} else {
+			data = normalized
+		}
+	}
+	return data
+}
+
+// synthesizeSegment 合成单个分段，失败时按 segment_failure.retries 重试；只有分段请求参与对冲
+func (h *TTSHandler) synthesizeSegment(ctx context.Context, req models.TTSRequest, index int) (*models.TTSResponse, error) {
+	ctx = hedge.WithSegment(ctx)
+	policy := h.config.TTS.SegmentFailure
+	backoff := time.Duration(policy.RetryBackoff) * time.Millisecond
+	if backoff <= 0 {
+		backoff = defaultSegmentRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := h.ttsService.SynthesizeSpeech(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= policy.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		log.Printf("句子 %d 第 %d 次合成失败，%v 后重试: %v", index+1, attempt+1, backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// silentSegment 按分段文本和语速估算时长，生成同格式的静音以代替最终失败的段
+func silentSegment(req models.TTSRequest) ([]byte, error) {
+	format, err := audio.ParseFormat(req.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	d := audio.EstimateDuration(req.Text)
+	if rate, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(req.Rate), "%")); err == nil {
+		speed := 100 + rate
+		if speed < 10 {
+			speed = 10
+		}
+		d = d * 100 / time.Duration(speed)
+	}
+	return audio.Silence(format, d)
+}
+
+// setDegradedHeader 在响应头中列出以静音代替的分段
+func setDegradedHeader(c *gin.Context, degraded []int) {
+	if len(degraded) == 0 {
+		return
+	}
+	indexes := make([]string, len(degraded))
+	for i, index := range degraded {
+		indexes[i] = strconv.Itoa(index + 1)
+	}
+	c.Header(degradedSegmentsHeader, strings.Join(indexes, ","))
+}
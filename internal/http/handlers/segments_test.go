@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tts/internal/audio"
+	"tts/internal/config"
+	"tts/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testFormat 测试使用的输出格式，合并时直接拼接，便于检查各段位置
+const testFormat = "raw-16khz-16bit-mono-pcm"
+
+// segmentAudio 是 scriptedService 为每段返回的音频：0.1 秒全为 0x11 的采样
+var segmentAudio = bytes.Repeat([]byte{0x11}, 3200)
+
+// scriptedService 按文本前缀决定合成结果：failures 中的前缀先失败指定次数，-1 表示一直失败
+type scriptedService struct {
+	mu       sync.Mutex
+	failures map[string]int
+	attempts map[string]int
+}
+
+func newScriptedService(failures map[string]int) *scriptedService {
+	return &scriptedService{failures: failures, attempts: make(map[string]int)}
+}
+
+// attemptsFor 返回以 prefix 开头的文本被合成的次数
+func (s *scriptedService) attemptsFor(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[prefix]
+}
+
+func (s *scriptedService) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	return nil, nil
+}
+
+func (s *scriptedService) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for prefix, remaining := range s.failures {
+		if !strings.HasPrefix(req.Text, prefix) {
+			continue
+		}
+		s.attempts[prefix]++
+		if remaining != 0 {
+			s.failures[prefix] = remaining - 1
+			return nil, errors.New("upstream failed: " + prefix)
+		}
+	}
+	return &models.TTSResponse{AudioContent: segmentAudio, ContentType: "audio/pcm"}, nil
+}
+
+func (s *scriptedService) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	resp, err := s.SynthesizeSpeech(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	return io.NopCloser(bytes.NewReader(resp.AudioContent)), resp.ContentType, nil
+}
+
+func (s *scriptedService) WarmupVoicesCache(ctx context.Context) error {
+	return nil
+}
+
+// testConfig 返回分段处理测试的配置：每行一段，段间不插入停顿，不做后处理
+func testConfig() *config.Config {
+	return &config.Config{
+		TTS: config.TTSConfig{
+			DefaultVoice:      "zh-CN-XiaoxiaoNeural",
+			DefaultFormat:     testFormat,
+			MaxTextLength:     10000,
+			SegmentThreshold:  100,
+			MinSentenceLength: 1,
+			MaxSentenceLength: 100,
+			SegmentFailure:    config.SegmentFailureConfig{RetryBackoff: 1},
+		},
+	}
+}
+
+// threeParagraphs 返回三行、各自成段的文本，各行分别以 A、B、C 开头
+func threeParagraphs() string {
+	line := strings.Repeat("测", 40) + "。"
+	return "A" + line + "\nB" + line + "\nC" + line
+}
+
+func TestSynthesizeSegmentRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		retries      int
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"success", 0, 2, 1, false},
+		{"no retries", 1, 0, 1, true},
+		{"recovers after a retry", 1, 1, 2, false},
+		{"recovers after two retries", 2, 2, 3, false},
+		{"retries exhausted", 3, 2, 3, true},
+		{"always failing", -1, 2, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newScriptedService(map[string]int{"A": tt.failures})
+			cfg := testConfig()
+			cfg.TTS.SegmentFailure.Retries = tt.retries
+			h := NewTTSHandler(service, cfg)
+
+			_, err := h.synthesizeSegment(context.Background(), models.TTSRequest{Text: "A", Format: testFormat}, 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := service.attemptsFor("A"); got != tt.wantAttempts {
+				t.Fatalf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestSynthesizeSegmentStopsOnCancel(t *testing.T) {
+	service := newScriptedService(map[string]int{"A": -1})
+	cfg := testConfig()
+	cfg.TTS.SegmentFailure.Retries = 5
+	cfg.TTS.SegmentFailure.RetryBackoff = 60000
+	h := NewTTSHandler(service, cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := h.synthesizeSegment(ctx, models.TTSRequest{Text: "A", Format: testFormat}, 0)
+		done <- err
+	}()
+	waitForAttempts(t, service, "A", 1)
+	cancel()
+	if err := <-done; err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := service.attemptsFor("A"); got != 1 {
+		t.Fatalf("attempts = %d, want 1", got)
+	}
+}
+
+func waitForAttempts(t *testing.T, s *scriptedService, prefix string, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); s.attemptsFor(prefix) < n && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if got := s.attemptsFor(prefix); got < n {
+		t.Fatalf("attempts for %s = %d, want %d", prefix, got, n)
+	}
+}
+
+// postTTS 以 JSON 请求调用 HandleTTSPost
+func postTTS(h *TTSHandler, query string, req models.TTSRequest) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/tts", h.HandleTTSPost)
+
+	body, _ := json.Marshal(req)
+	httpReq := httptest.NewRequest(http.MethodPost, "/tts"+query, bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httpReq)
+	return w
+}
+
+func TestSegmentFailureModes(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		retries      int
+		failures     map[string]int
+		wantStatus   int
+		wantDegraded string
+		wantSilence  []bool // 各段是否以静音代替
+		wantAttempts map[string]int
+	}{
+		{
+			name:         "all succeed",
+			mode:         config.SegmentFailureSilence,
+			failures:     map[string]int{},
+			wantStatus:   http.StatusOK,
+			wantSilence:  []bool{false, false, false},
+			wantAttempts: map[string]int{},
+		},
+		{
+			name:         "retry recovers",
+			mode:         config.SegmentFailureSilence,
+			retries:      1,
+			failures:     map[string]int{"B": 1},
+			wantStatus:   http.StatusOK,
+			wantSilence:  []bool{false, false, false},
+			wantAttempts: map[string]int{"B": 2},
+		},
+		{
+			name:         "middle segment degraded",
+			mode:         config.SegmentFailureSilence,
+			retries:      1,
+			failures:     map[string]int{"B": -1},
+			wantStatus:   http.StatusOK,
+			wantDegraded: "2",
+			wantSilence:  []bool{false, true, false},
+			wantAttempts: map[string]int{"B": 2},
+		},
+		{
+			name:         "several segments degraded",
+			mode:         config.SegmentFailureSilence,
+			failures:     map[string]int{"A": -1, "C": -1},
+			wantStatus:   http.StatusOK,
+			wantDegraded: "1,3",
+			wantSilence:  []bool{true, false, true},
+			wantAttempts: map[string]int{"A": 1, "C": 1},
+		},
+		{
+			name:         "all degraded",
+			mode:         config.SegmentFailureSilence,
+			failures:     map[string]int{"A": -1, "B": -1, "C": -1},
+			wantStatus:   http.StatusInternalServerError,
+			wantAttempts: map[string]int{"A": 1, "B": 1, "C": 1},
+		},
+		{
+			name:         "fail mode",
+			mode:         config.SegmentFailureFail,
+			retries:      2,
+			failures:     map[string]int{"B": -1},
+			wantStatus:   http.StatusInternalServerError,
+			wantAttempts: map[string]int{"B": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newScriptedService(tt.failures)
+			cfg := testConfig()
+			cfg.TTS.SegmentFailure.Mode = tt.mode
+			cfg.TTS.SegmentFailure.Retries = tt.retries
+			h := NewTTSHandler(service, cfg)
+
+			text := threeParagraphs()
+			w := postTTS(h, "", models.TTSRequest{Text: text})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			for prefix, want := range tt.wantAttempts {
+				if got := service.attemptsFor(prefix); got != want {
+					t.Errorf("attempts for %s = %d, want %d", prefix, got, want)
+				}
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get(degradedSegmentsHeader); got != tt.wantDegraded {
+				t.Errorf("%s = %q, want %q", degradedSegmentsHeader, got, tt.wantDegraded)
+			}
+
+			// 依次检查各段：成功的段为合成的音频，降级的段为按文本估算时长的静音
+			body := w.Body.Bytes()
+			for i, segment := range splitTextBySentences(text, cfg) {
+				want := segmentAudio
+				if tt.wantSilence[i] {
+					silence, err := silentSegment(models.TTSRequest{Text: segment.text, Format: testFormat})
+					if err != nil {
+						t.Fatal(err)
+					}
+					want = silence
+				}
+				if len(body) < len(want) || !bytes.Equal(body[:len(want)], want) {
+					t.Fatalf("segment %d does not match (silence = %v)", i+1, tt.wantSilence[i])
+				}
+				body = body[len(want):]
+			}
+			if len(body) != 0 {
+				t.Fatalf("%d unexpected trailing bytes", len(body))
+			}
+		})
+	}
+}
+
+func TestSegmentPauses(t *testing.T) {
+	cfg := testConfig()
+	cfg.TTS.Merge.SentencePause = 100
+	cfg.TTS.Merge.ParagraphPause = 400
+	h := NewTTSHandler(newScriptedService(nil), cfg)
+
+	tests := []struct {
+		name     string
+		segments []textSegment
+		want     []time.Duration
+	}{
+		{"single segment", []textSegment{{paragraphEnd: true}}, []time.Duration{0}},
+		{
+			name:     "sentences in one paragraph",
+			segments: []textSegment{{}, {}, {paragraphEnd: true}},
+			want:     []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 0},
+		},
+		{
+			name:     "paragraph boundary",
+			segments: []textSegment{{}, {paragraphEnd: true}, {paragraphEnd: true}},
+			want:     []time.Duration{100 * time.Millisecond, 400 * time.Millisecond, 0},
+		},
+		{
+			name:     "heading",
+			segments: []textSegment{{paragraphEnd: true, heading: true}, {}, {paragraphEnd: true}},
+			want:     []time.Duration{400 * time.Millisecond, 100 * time.Millisecond, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.segmentPauses(tt.segments)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d pauses, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("pause %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	// 按文本分段时，标题和段落结尾之后使用 paragraph_pause
+	sentence := strings.Repeat("测", 30) + "。"
+	text := "# 第一章\n" + sentence + sentence + "\n" + sentence
+	segments := splitTextBySentences(text, cfg)
+	want := []time.Duration{400 * time.Millisecond, 100 * time.Millisecond, 400 * time.Millisecond, 0}
+	got := h.segmentPauses(segments)
+	if len(got) != len(want) {
+		t.Fatalf("got %d segments, want %d: %+v", len(got), len(want), segments)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("pause after %q = %v, want %v", segments[i].text, got[i], want[i])
+		}
+	}
+}
+
+// paddedSegment 返回前后各有 100ms 静音、中间为 100ms 幅度 0.25 方波的 16kHz 16bit 单声道 PCM
+func paddedSegment() []byte {
+	data := make([]byte, 3*3200)
+	for i := 3200; i < 6400; i += 2 {
+		sample := uint16(8192)
+		if i%4 == 2 {
+			sample = uint16(0xFFFF - 8191) // -8192
+		}
+		data[i], data[i+1] = byte(sample), byte(sample>>8)
+	}
+	return data
+}
+
+func TestPostprocessSegment(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		trim      bool
+		normalize bool
+		wantLen   int
+		wantPeak  int // 第一个采样的绝对值，0 表示不检查
+	}{
+		{"disabled", testFormat, false, false, 3 * 3200, 0},
+		{"trim keeps padding", testFormat, true, false, 3200 + 2*640, 8192},
+		{"normalize to default loudness", testFormat, false, true, 3 * 3200, 0},
+		// 归一化在裁剪之后：RMS 为 0.25×√(100/140)，增益约 0.473
+		{"trim and normalize", testFormat, true, true, 3200 + 2*640, 3877},
+		{"unprocessable format", "audio-16khz-32kbitrate-mono-mp3", true, true, 3 * 3200, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.TTS.Merge.TrimSilence = tt.trim
+			cfg.TTS.Merge.Normalize = tt.normalize
+			h := NewTTSHandler(newScriptedService(nil), cfg)
+			f, err := audio.ParseFormat(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			input := paddedSegment()
+			got := h.postprocessSegment(f, input)
+			if len(got) != tt.wantLen {
+				t.Fatalf("%d bytes, want %d", len(got), tt.wantLen)
+			}
+			if !tt.trim && !tt.normalize && !bytes.Equal(got, input) {
+				t.Fatal("audio changed with post-processing disabled")
+			}
+			if tt.wantPeak != 0 {
+				// 裁剪后保留 20ms（640 字节）静音，之后是方波的第一个采样
+				sample := int16(uint16(got[640]) | uint16(got[641])<<8)
+				if diff := int(sample) - tt.wantPeak; diff < -1 || diff > 1 {
+					t.Errorf("first sample = %d, want %d", sample, tt.wantPeak)
+				}
+			}
+		})
+	}
+}
+
+func TestArrangeSegment(t *testing.T) {
+	cfg := testConfig()
+	cfg.TTS.Merge.TrimSilence = true
+	h := NewTTSHandler(newScriptedService(nil), cfg)
+
+	tests := []struct {
+		name       string
+		format     string
+		pause      time.Duration
+		wantPieces []int // 各部分的字节数
+	}{
+		{"no pause", testFormat, 0, []int{3200 + 2*640}},
+		{"pause appended", testFormat, 250 * time.Millisecond, []int{3200 + 2*640, 8000}},
+		{"unknown format", "not-a-format", 250 * time.Millisecond, []int{3 * 3200}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pieces := h.arrangeSegment(tt.format, paddedSegment(), tt.pause)
+			if len(pieces) != len(tt.wantPieces) {
+				t.Fatalf("got %d pieces, want %d", len(pieces), len(tt.wantPieces))
+			}
+			for i, piece := range pieces {
+				if len(piece) != tt.wantPieces[i] {
+					t.Errorf("piece %d = %d bytes, want %d", i, len(piece), tt.wantPieces[i])
+				}
+			}
+			if len(pieces) == 2 && !bytes.Equal(pieces[1], make([]byte, len(pieces[1]))) {
+				t.Error("pause is not silent")
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tts/internal/models"
+	"tts/internal/scheduler"
+	"tts/internal/tts"
+
+	"github.com/gin-gonic/gin"
+)
+
+// speechMarkTypes 合成事件类型到 Polly 语音标记类型的映射
+var speechMarkTypes = map[string]string{
+	models.EventWordBoundary:     "word",
+	models.EventSentenceBoundary: "sentence",
+	models.EventBookmark:         "ssml",
+	models.EventViseme:           "viseme",
+}
+
+// HandleSpeechMarks 返回 Polly 风格的语音标记（每行一个 JSON 对象）
+// 可通过 types 参数（逗号分隔的 word、sentence、ssml、viseme）筛选标记类型
+func (h *TTSHandler) HandleSpeechMarks(c *gin.Context) {
+	startTime := time.Now()
+
+	var req models.TTSRequest
+	var ok bool
+	if c.Request.Method == http.MethodGet {
+		req, ok = bindTTSQuery(c)
+	} else {
+		req, ok = bindTTSBody(c)
+	}
+	if !ok {
+		return
+	}
+
+	synthesizer, ok := h.ttsService.(tts.EventSynthesizer)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotImplemented, gin.H{"error": "当前后端不支持语音标记"})
+		return
+	}
+
+	h.fillDefaultValues(&req)
+	if err := h.validateRatePitch(req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateFormat(req.Format); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if utf8.RuneCountInString(req.Text) > h.config.TTS.MaxTextLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "文本长度超过限制"})
+		return
+	}
+
+	wanted := make(map[string]bool)
+	for _, t := range strings.Split(c.Query("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			wanted[t] = true
+		}
+	}
+
+	ctx := scheduler.WithTicket(c.Request.Context(), schedulerTicket(c))
+	resp, err := synthesizer.SynthesizeWithEvents(ctx, req)
+	if err != nil {
+		log.Printf("语音标记合成失败: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "语音合成失败: " + err.Error()})
+		return
+	}
+
+	marks := toSpeechMarks(req.Text, resp.Events, wanted)
+
+	c.Header("Content-Type", "application/x-json-stream")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	encoder.SetEscapeHTML(false)
+	for _, mark := range marks {
+		if err := encoder.Encode(mark); err != nil {
+			log.Printf("写入语音标记失败: %v", err)
+			return
+		}
+	}
+
+	log.Printf("语音标记请求总耗时: %v, 事件数: %d, 标记数: %d", time.Since(startTime), len(resp.Events), len(marks))
+}
+
+// toSpeechMarks 将合成事件转换为语音标记，并在原文中定位词、句的字节偏移
+func toSpeechMarks(text string, events []models.SynthesisEvent, wanted map[string]bool) []models.SpeechMark {
+	marks := make([]models.SpeechMark, 0, len(events))
+	wordCursor, sentenceCursor := 0, 0
+
+	for _, event := range events {
+		markType, ok := speechMarkTypes[event.Type]
+		if !ok || (len(wanted) > 0 && !wanted[markType]) {
+			continue
+		}
+
+		mark := models.SpeechMark{
+			Time: event.Offset.Milliseconds(),
+			Type: markType,
+		}
+
+		switch event.Type {
+		case models.EventWordBoundary:
+			mark.Value = event.Text
+			mark.Start, mark.End = locateText(text, event.Text, wordCursor)
+			wordCursor = mark.End
+		case models.EventSentenceBoundary:
+			mark.Value = event.Text
+			mark.Start, mark.End = locateText(text, event.Text, sentenceCursor)
+			sentenceCursor = mark.End
+		case models.EventBookmark:
+			mark.Value = event.Bookmark
+		case models.EventViseme:
+			mark.Value = strconv.Itoa(event.VisemeID)
+		}
+
+		marks = append(marks, mark)
+	}
+
+	return marks
+}
+
+// locateText 从 from 开始查找 fragment，找不到时返回空区间
+func locateText(text, fragment string, from int) (int, int) {
+	if fragment == "" || from > len(text) {
+		return from, from
+	}
+	idx := strings.Index(text[from:], fragment)
+	if idx < 0 {
+		return from, from
+	}
+	start := from + idx
+	return start, start + len(fragment)
+}
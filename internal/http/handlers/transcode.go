@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"tts/internal/audio"
+	"tts/internal/models"
+	"tts/internal/transcode"
+	"tts/internal/tts/microsoft"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transcodeTarget 检查输出格式，上游直接提供的格式返回 nil，否则返回需要转码的目标格式
+func transcodeTarget(format string) (*audio.Format, error) {
+	if format == "" {
+		return nil, nil
+	}
+	if _, ok := microsoft.FormatContentTypeMap[format]; ok {
+		return nil, nil
+	}
+	f, err := audio.ParseFormat(format)
+	if err != nil {
+		return nil, fmt.Errorf("不支持的输出格式: %s", format)
+	}
+	if err := transcode.Validate(f); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// transcodeAudio 把按转码源格式合成的音频转码为目标格式
+func transcodeAudio(ctx context.Context, data []byte, source string, target audio.Format) ([]byte, error) {
+	from, err := audio.ParseFormat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	out, err := transcode.Transcode(ctx, data, from, target)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("转码为 %s 完成，耗时: %v, 大小: %s -> %s",
+		target.Name, time.Since(start), formatFileSize(len(data)), formatFileSize(len(out)))
+	return out, nil
+}
+
+// handleTranscodedTTS 合成转码源格式的完整音频，转码后一次性写出，tag 不为 nil 时写入 ID3 元数据
+func (h *TTSHandler) handleTranscodedTTS(c *gin.Context, req models.TTSRequest, target audio.Format, tag *audio.ID3Tag, startTime time.Time, parseTime time.Duration, requestType string) {
+	synthStart := time.Now()
+	resp, err := h.ttsService.SynthesizeSpeech(c.Request.Context(), req)
+	if err != nil {
+		log.Printf("TTS合成失败: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "语音合成失败: " + err.Error()})
+		return
+	}
+	synthTime := time.Since(synthStart)
+
+	transcodeStart := time.Now()
+	data, err := transcodeAudio(c.Request.Context(), resp.AudioContent, req.Format, target)
+	if err != nil {
+		log.Printf("转码失败: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "音频转码失败: " + err.Error()})
+		return
+	}
+	transcodeTime := time.Since(transcodeStart)
+	if tag != nil {
+		data = audio.TagMP3(data, *tag)
+	}
+
+	c.Header("Content-Type", transcode.ContentType(target))
+	h.setCacheHeader(c, resp.CacheHit)
+	if _, err := c.Writer.Write(data); err != nil {
+		log.Printf("写入响应失败: %v", err)
+		return
+	}
+
+	log.Printf("%s请求总耗时: %v (解析: %v, 合成: %v, 转码: %v), 音频大小: %s",
+		requestType, time.Since(startTime), parseTime, synthTime, transcodeTime, formatFileSize(len(data)))
+}
@@ -2,24 +2,30 @@ package handlers
 
 import (
 	"context"
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
+	"tts/internal/audio"
+	"tts/internal/cache"
 	"tts/internal/config"
+	"tts/internal/http/middleware"
 	"tts/internal/models"
+	"tts/internal/scheduler"
+	"tts/internal/transcode"
 	"tts/internal/tts"
 	"tts/internal/tts/microsoft"
 	"tts/internal/utils"
-	"runtime"
 	"unicode"
 	"unicode/utf8"
 
@@ -54,20 +60,29 @@ func audioMergeWithFormat(audioSegments [][]byte, format string) ([]byte, error)
 	}
 
 	if isMp3Format(format) {
-		var buf bytes.Buffer
-		for _, seg := range audioSegments {
-			if len(seg) == 0 {
-				continue
-			}
-			if _, err := buf.Write(seg); err != nil {
-				return nil, err
-			}
+		merged, err := audio.MergeMP3(audioSegments)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("使用内存合并完成，总大小: %s", formatFileSize(len(merged)))
+		return merged, nil
+	}
+
+	// WAV、PCM 和 Ogg Opus 直接在内存中拼接，无需 ffmpeg
+	f, err := audio.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	if audio.Joinable(f) {
+		merged, err := audio.Merge(f, audioSegments)
+		if err != nil {
+			return nil, err
 		}
-		merged := buf.Bytes()
 		log.Printf("使用内存合并完成，总大小: %s", formatFileSize(len(merged)))
 		return merged, nil
 	}
 
+	// 其它容器（如 WebM）使用 ffmpeg 合并
 	if _, err := exec.LookPath("ffmpeg"); err != nil {
 		return nil, fmt.Errorf("未找到 ffmpeg，请确认已安装并在 PATH 中: %w", err)
 	}
@@ -86,7 +101,7 @@ func audioMergeWithFormat(audioSegments [][]byte, format string) ([]byte, error)
 	}
 
 	for i, seg := range audioSegments {
-		segFile := filepath.Join(tempDir, fmt.Sprintf("seg_%d.mp3", i))
+		segFile := filepath.Join(tempDir, fmt.Sprintf("seg_%d.%s", i, f.Container))
 		if err := os.WriteFile(segFile, seg, 0644); err != nil {
 			return nil, err
 		}
@@ -96,7 +111,7 @@ func audioMergeWithFormat(audioSegments [][]byte, format string) ([]byte, error)
 	}
 	lf.Close()
 
-	outputFile := filepath.Join(tempDir, "output.mp3")
+	outputFile := filepath.Join(tempDir, "output."+f.Container)
 
 	cmd := exec.Command("ffmpeg", "-y", "-f", "concat", "-safe", "0", "-i", listFile, "-c", "copy", outputFile)
 	if output, err := cmd.CombinedOutput(); err != nil {
@@ -132,10 +147,14 @@ func isMp3Format(format string) bool {
 	return strings.Contains(strings.ToLower(format), "mp3")
 }
 
+// contentTypeFromFormat 返回输出格式的 MIME 类型，需要转码的格式按转码后的格式确定
 func contentTypeFromFormat(format string) string {
 	if ct, ok := microsoft.FormatContentTypeMap[format]; ok {
 		return ct
 	}
+	if f, err := audio.ParseFormat(format); err == nil {
+		return transcode.ContentType(f)
+	}
 	return "audio/mpeg"
 }
 
@@ -195,6 +214,21 @@ func (h *TTSHandler) processTTSRequest(c *gin.Context, req models.TTSRequest, st
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// 上游不提供的输出格式改为合成转码源格式，合成及合并后再转码
+	target, err := transcodeTarget(req.Format)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// MP3 输出按需写入 ID3 元数据
+	tag, err := h.id3Metadata(c, req, req.Format)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if target != nil {
+		req.Format = transcode.SourceFormat(*target)
+	}
 
 	// 检查文本长度
 	reqTextLength := utf8.RuneCountInString(req.Text)
@@ -203,6 +237,9 @@ func (h *TTSHandler) processTTSRequest(c *gin.Context, req models.TTSRequest, st
 		return
 	}
 
+	// 附加调度信息，上游调用按优先级、API Key 和请求公平排队
+	c.Request = c.Request.WithContext(scheduler.WithTicket(c.Request.Context(), schedulerTicket(c)))
+
 	// 检查是否包含SSML标签
 	containsSSML := h.containsSSMLTags(req.Text)
 	if containsSSML {
@@ -213,34 +250,96 @@ func (h *TTSHandler) processTTSRequest(c *gin.Context, req models.TTSRequest, st
 	segmentThreshold := h.config.TTS.SegmentThreshold
 	if reqTextLength > segmentThreshold && reqTextLength <= h.config.TTS.MaxTextLength && !containsSSML {
 		log.Printf("文本长度 %d 超过阈值 %d，使用分段处理", reqTextLength, segmentThreshold)
-		h.handleSegmentedTTS(c, req)
+		h.handleSegmentedTTS(c, req, target, tag)
+		return
+	}
+	if target != nil {
+		h.handleTranscodedTTS(c, req, *target, tag, startTime, parseTime, requestType)
 		return
 	}
 
 	synthStart := time.Now()
-	resp, err := h.ttsService.SynthesizeSpeech(c.Request.Context(), req)
-	synthTime := time.Since(synthStart)
-	log.Printf("TTS合成耗时: %v, 文本长度: %d", synthTime, reqTextLength)
-
+	body, contentType, err := h.ttsService.SynthesizeStream(c.Request.Context(), req)
 	if err != nil {
 		log.Printf("TTS合成失败: %v", err)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "语音合成失败: " + err.Error()})
 		return
 	}
+	defer body.Close()
+	firstByteTime := time.Since(synthStart)
 
-	// 设置响应
-	c.Header("Content-Type", resp.ContentType)
-	writeStart := time.Now()
-	if _, err := c.Writer.Write(resp.AudioContent); err != nil {
-		log.Printf("写入响应失败: %v", err)
+	// 设置响应，边接收边以分块传输写给客户端
+	c.Header("Content-Type", contentType)
+	h.setCacheHeader(c, cache.IsHit(body))
+	c.Status(http.StatusOK)
+	if tag != nil {
+		if _, err := c.Writer.Write(tag.Bytes()); err != nil {
+			log.Printf("写入音频流失败: %v", err)
+			return
+		}
+	}
+	written, err := streamAudio(c, body)
+	synthTime := time.Since(synthStart)
+	if err != nil {
+		log.Printf("写入音频流失败: %v", err)
 		return
 	}
-	writeTime := time.Since(writeStart)
 
 	// 记录总耗时
 	totalTime := time.Since(startTime)
-	log.Printf("%s请求总耗时: %v (解析: %v, 合成: %v, 写入: %v), 音频大小: %s",
-		requestType, totalTime, parseTime, synthTime, writeTime, formatFileSize(len(resp.AudioContent)))
+	log.Printf("%s请求总耗时: %v (解析: %v, 首字节: %v, 合成及写入: %v), 文本长度: %d, 音频大小: %s",
+		requestType, totalTime, parseTime, firstByteTime, synthTime, reqTextLength, formatFileSize(int(written)))
+}
+
+// schedulerTicket 根据 API Key（没有时使用客户端 IP）和 priority 参数生成调度信息
+func schedulerTicket(c *gin.Context) scheduler.Ticket {
+	key, _ := middleware.ExtractAPIKey(c)
+	if key == "" {
+		key = c.ClientIP()
+	}
+	priority := c.Query("priority")
+	if priority == "" {
+		priority = c.GetHeader("X-Priority")
+	}
+	return scheduler.Ticket{
+		Key:      key,
+		Request:  uuid.New().String(),
+		Priority: scheduler.ParsePriority(priority),
+	}
+}
+
+// setCacheHeader 启用缓存时通过 X-Cache 标明是否命中，分段请求须所有分段均命中
+func (h *TTSHandler) setCacheHeader(c *gin.Context, hit bool) {
+	if !h.config.Cache.Enabled {
+		return
+	}
+	if hit {
+		c.Header("X-Cache", "HIT")
+	} else {
+		c.Header("X-Cache", "MISS")
+	}
+}
+
+// streamAudio 将音频流写给客户端，每读到一块就立即刷新
+func streamAudio(c *gin.Context, body io.Reader) (int64, error) {
+	buf := make([]byte, 32*1024)
+	var written int64
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if _, err := c.Writer.Write(buf[:n]); err != nil {
+				return written, err
+			}
+			c.Writer.Flush()
+			written += int64(n)
+		}
+		if readErr == io.EOF {
+			return written, nil
+		}
+		if readErr != nil {
+			return written, readErr
+		}
+	}
 }
 
 func (h *TTSHandler) validateRatePitch(req models.TTSRequest) error {
@@ -285,6 +384,20 @@ func (h *TTSHandler) fillDefaultValues(req *models.TTSRequest) {
 	if req.Pitch == "" {
 		req.Pitch = h.config.TTS.DefaultPitch
 	}
+	if req.Format == "" {
+		req.Format = h.config.TTS.DefaultFormat
+	}
+}
+
+// validateFormat 检查输出格式是否受支持
+func validateFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	if _, ok := microsoft.FormatContentTypeMap[format]; !ok {
+		return fmt.Errorf("不支持的输出格式: %s", format)
+	}
+	return nil
 }
 
 // HandleTTS 处理TTS请求
@@ -303,59 +416,83 @@ func (h *TTSHandler) HandleTTS(c *gin.Context) {
 func (h *TTSHandler) HandleTTSGet(c *gin.Context) {
 	startTime := time.Now()
 
+	req, ok := bindTTSQuery(c)
+	if !ok {
+		return
+	}
+
+	parseTime := time.Since(startTime)
+	h.processTTSRequest(c, req, startTime, parseTime, "TTS GET")
+}
+
+// HandleTTSPost 处理POST方式的TTS请求
+func (h *TTSHandler) HandleTTSPost(c *gin.Context) {
+	startTime := time.Now()
+
+	req, ok := bindTTSBody(c)
+	if !ok {
+		return
+	}
+
+	parseTime := time.Since(startTime)
+	h.processTTSRequest(c, req, startTime, parseTime, "TTS POST")
+}
+
+// bindTTSQuery 从查询参数解析TTS请求，支持完整参数名和简短参数名，失败时已写入错误响应
+func bindTTSQuery(c *gin.Context) (models.TTSRequest, bool) {
 	var req models.TTSRequest
 
 	if c.Query("t") != "" {
 		req = models.TTSRequest{
-			Text:  c.Query("t"),
-			Voice: c.Query("v"),
-			Rate:  c.Query("r"),
-			Pitch: c.Query("p"),
-			Style: c.Query("s"),
+			Text:     c.Query("t"),
+			Voice:    c.Query("v"),
+			Rate:     c.Query("r"),
+			Pitch:    c.Query("p"),
+			Style:    c.Query("s"),
+			Provider: c.Query("provider"),
+			Format:   c.Query("f"),
+			Title:    c.Query("title"),
+			Album:    c.Query("album"),
 		}
 	} else if c.Query("text") != "" {
 		req = models.TTSRequest{
-			Text:  c.Query("text"),
-			Voice: c.Query("voice"),
-			Rate:  c.Query("rate"),
-			Pitch: c.Query("pitch"),
-			Style: c.Query("style"),
+			Text:     c.Query("text"),
+			Voice:    c.Query("voice"),
+			Rate:     c.Query("rate"),
+			Pitch:    c.Query("pitch"),
+			Style:    c.Query("style"),
+			Provider: c.Query("provider"),
+			Format:   c.Query("format"),
+			Title:    c.Query("title"),
+			Album:    c.Query("album"),
 		}
 	} else {
 		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "必须提供文本参数"})
-		return
+		return req, false
 	}
 
-	parseTime := time.Since(startTime)
-	h.processTTSRequest(c, req, startTime, parseTime, "TTS GET")
+	return req, true
 }
 
-// HandleTTSPost 处理POST方式的TTS请求
-func (h *TTSHandler) HandleTTSPost(c *gin.Context) {
-	startTime := time.Now()
-
-	// 从POST JSON体或表单数据获取
+// bindTTSBody 从POST JSON体或表单数据解析TTS请求，失败时已写入错误响应
+func bindTTSBody(c *gin.Context) (models.TTSRequest, bool) {
 	var req models.TTSRequest
-	var err error
 
 	if c.ContentType() == "application/json" {
-		err = c.ShouldBindJSON(&req)
-		if err != nil {
+		if err := c.ShouldBindJSON(&req); err != nil {
 			log.Printf("JSON解析错误: %v", err)
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "无效的JSON请求"})
-			return
+			return req, false
 		}
 	} else {
-		err = c.ShouldBind(&req)
-		if err != nil {
+		if err := c.ShouldBind(&req); err != nil {
 			log.Printf("表单解析错误: %v", err)
 			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "无法解析表单数据"})
-			return
+			return req, false
 		}
 	}
 
-	parseTime := time.Since(startTime)
-	h.processTTSRequest(c, req, startTime, parseTime, "TTS POST")
+	return req, true
 }
 
 // HandleOpenAITTS 处理OpenAI兼容的TTS请求
@@ -384,7 +521,11 @@ func (h *TTSHandler) HandleOpenAITTS(c *gin.Context) {
 	}
 
 	// 创建内部TTS请求
-	req := h.convertOpenAIRequest(openaiReq)
+	req, err := h.convertOpenAIRequest(openaiReq)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	log.Printf("OpenAI TTS请求: model=%s, voice=%s → %s, speed=%.2f → %s, 文本长度=%d",
 		openaiReq.Model, openaiReq.Voice, req.Voice, openaiReq.Speed, req.Rate, utf8.RuneCountInString(req.Text))
@@ -392,8 +533,17 @@ func (h *TTSHandler) HandleOpenAITTS(c *gin.Context) {
 	h.processTTSRequest(c, req, startTime, parseTime, "OpenAI TTS")
 }
 
+// openAIResponseFormats OpenAI response_format 到 Microsoft 输出格式的映射
+var openAIResponseFormats = map[string]string{
+	"opus": "ogg-24khz-16bit-mono-opus",
+	"wav":  "riff-24khz-16bit-mono-pcm",
+	"pcm":  "raw-24khz-16bit-mono-pcm", // OpenAI 的 pcm 为 24kHz 16bit 小端
+	"flac": "flac-24khz-16bit-mono-flac",
+	"aac":  "aac-24khz-64kbitrate-mono-aac",
+}
+
 // convertOpenAIRequest 将OpenAI请求转换为内部请求格式
-func (h *TTSHandler) convertOpenAIRequest(openaiReq models.OpenAIRequest) models.TTSRequest {
+func (h *TTSHandler) convertOpenAIRequest(openaiReq models.OpenAIRequest) (models.TTSRequest, error) {
 	// 映射OpenAI声音到Microsoft声音
 	msVoice := openaiReq.Voice
 	if openaiReq.Voice != "" && h.config.TTS.VoiceMapping[openaiReq.Voice] != "" {
@@ -411,13 +561,31 @@ func (h *TTSHandler) convertOpenAIRequest(openaiReq models.OpenAIRequest) models
 		}
 	}
 
-	return models.TTSRequest{
-		Text:  openaiReq.Input,
-		Voice: msVoice,
-		Rate:  msRate,
-		Pitch: h.config.TTS.DefaultPitch,
-		Style: openaiReq.Model,
+	// 转换输出格式，也接受 Microsoft 格式名和需要转码的格式名
+	format := ""
+	switch responseFormat := strings.ToLower(openaiReq.ResponseFormat); {
+	case responseFormat == "":
+	case responseFormat == "mp3":
+		if !isMp3Format(h.config.TTS.DefaultFormat) {
+			format = "audio-24khz-48kbitrate-mono-mp3"
+		}
+	case openAIResponseFormats[responseFormat] != "":
+		format = openAIResponseFormats[responseFormat]
+	default:
+		if _, err := transcodeTarget(openaiReq.ResponseFormat); err != nil {
+			return models.TTSRequest{}, fmt.Errorf("不支持的 response_format: %s", openaiReq.ResponseFormat)
+		}
+		format = openaiReq.ResponseFormat
 	}
+
+	return models.TTSRequest{
+		Text:   openaiReq.Input,
+		Voice:  msVoice,
+		Rate:   msRate,
+		Pitch:  h.config.TTS.DefaultPitch,
+		Style:  openaiReq.Model,
+		Format: format,
+	}, nil
 }
 
 // Add this struct to store synthesis results
@@ -427,10 +595,13 @@ type sentenceSynthesisResult struct {
 	audioSize int
 	content   string
 	duration  time.Duration
+	cacheHit  bool
+	degraded  bool
 }
 
 // Modify the handleSegmentedTTS function to collect and display results in a table
-func (h *TTSHandler) handleSegmentedTTS(c *gin.Context, req models.TTSRequest) {
+// target 不为 nil 时合并后转码为该格式，tag 不为 nil 时写入 ID3 元数据和章节
+func (h *TTSHandler) handleSegmentedTTS(c *gin.Context, req models.TTSRequest, target *audio.Format, tag *audio.ID3Tag) {
 	segmentStart := time.Now()
 	text := req.Text
 
@@ -439,106 +610,38 @@ func (h *TTSHandler) handleSegmentedTTS(c *gin.Context, req models.TTSRequest) {
 
 	// 开始计时：分割文本
 	splitStart := time.Now()
-	sentences := splitTextBySentences(text, h.config)
-	segmentCount := len(sentences)
+	segments := splitTextBySentences(text, h.config)
+	segmentCount := len(segments)
+	pauses := h.segmentPauses(segments)
 	splitTime := time.Since(splitStart)
 
 	log.Printf("分割文本耗时: %v, 文本总长度: %d, 分段数: %d, 平均句子长度: %.2f",
 		splitTime, utf8.RuneCountInString(text), segmentCount, float64(utf8.RuneCountInString(text))/float64(segmentCount))
 
-	// 创建用于存储每段音频的切片
-	results := make([][]byte, segmentCount)
-	// 创建用于收集合成结果信息的切片
-	synthResults := make([]sentenceSynthesisResult, segmentCount)
-
-	errChan := make(chan error, 1)
-	var wg sync.WaitGroup
-	var synthMutex sync.Mutex
-
-	// 限制并发数量（自适应上限）
-	maxConcurrent := h.config.TTS.MaxConcurrent
-	if maxConcurrent <= 0 {
-		maxConcurrent = 1
-	}
-	cpu := runtime.GOMAXPROCS(0)
-	if cpu > 0 && maxConcurrent > cpu*4 {
-		maxConcurrent = cpu * 4
-	}
-	if maxConcurrent > segmentCount {
-		maxConcurrent = segmentCount
+	// 流式模式下每段在其之前的段都完成后立即写出，不支持流式拼接的格式和需要转码的格式仍合并后输出
+	if h.segmentStreamingEnabled(c) && target == nil {
+		pieceCount := segmentCount
+		for _, pause := range pauses {
+			if pause > 0 {
+				pieceCount++
+			}
+		}
+		joiner, err := segmentJoiner(req.Format, pieceCount)
+		if err == nil {
+			h.streamSegmentedTTS(c, ctx, cancel, req, segments, pauses, joiner, tag, segmentStart, splitTime)
+			return
+		}
+		log.Printf("格式 %s 不支持流式分段输出，合并后输出: %v", req.Format, err)
 	}
-	semaphore := make(chan struct{}, maxConcurrent)
 
 	// 合成阶段开始时间
 	synthesisStart := time.Now()
-
-	// 并发处理每一个句子
-	for i := 0; i < segmentCount; i++ {
-		wg.Add(1)
-		go func(index int) {
-			defer wg.Done()
-
-			select {
-			case semaphore <- struct{}{}: // 获取信号量
-				defer func() { <-semaphore }() // 释放信号量
-			case <-ctx.Done():
-				select {
-				case errChan <- ctx.Err():
-				default:
-				}
-				return
-			}
-
-			// 创建该句的请求
-			segReq := models.TTSRequest{
-				Text:  sentences[index],
-				Voice: req.Voice,
-				Rate:  req.Rate,
-				Pitch: req.Pitch,
-				Style: req.Style,
-			}
-
-			startTime := time.Now()
-			// 合成该段音频
-			resp, err := h.ttsService.SynthesizeSpeech(ctx, segReq)
-			synthDuration := time.Since(startTime)
-
-			if err != nil {
-				select {
-				case errChan <- fmt.Errorf("句子 %d 合成失败: %w", index+1, err):
-				default:
-				}
-				cancel()
-				return
-			}
-
-			// 收集合成结果信息，而不是立即打印
-			result := sentenceSynthesisResult{
-				index:     index,
-				length:    utf8.RuneCountInString(sentences[index]),
-				audioSize: len(resp.AudioContent),
-				content:   truncateForLog(sentences[index], 20),
-				duration:  synthDuration,
-			}
-
-			synthMutex.Lock()
-			synthResults[index] = result
-			results[index] = resp.AudioContent
-			synthMutex.Unlock()
-		}(i)
-	}
-
-	// 等待所有goroutine完成或出错
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
+	job := h.startSegments(ctx, cancel, req, segments)
 
 	select {
-	case <-done:
+	case <-job.done:
 		// 所有goroutine正常完成
-	case err := <-errChan:
+	case err := <-job.errChan:
 		// 发生错误
 		cancel()
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -550,36 +653,58 @@ func (h *TTSHandler) handleSegmentedTTS(c *gin.Context, req models.TTSRequest) {
 		return
 	}
 
-	// 打印结构化日志，便于聚合分析
-	for i := 0; i < segmentCount; i++ {
-		result := synthResults[i]
-		log.Printf("segment_result index=%d length=%d audio_size=%s duration_ms=%d content=%q",
-			i+1,
-			result.length,
-			formatFileSize(result.audioSize),
-			result.duration.Milliseconds(),
-			result.content)
+	if len(job.degraded) == segmentCount {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "所有句子均合成失败"})
+		return
 	}
+	sort.Ints(job.degraded)
 
 	// 记录合成总耗时
 	synthesisTime := time.Since(synthesisStart)
-	log.Printf("segment_summary segments=%d total_ms=%d avg_ms=%d concurrency=%d",
-		segmentCount,
-		synthesisTime.Milliseconds(),
-		(synthesisTime/time.Duration(segmentCount)).Milliseconds(),
-		maxConcurrent)
+	h.logSegmentResults(job, synthesisTime)
 
-	// 合并音频
+	// 合并音频，各段经过后处理并在段间插入停顿
 	writeStart := time.Now()
-	audioData, err := audioMergeWithFormat(results, h.config.TTS.DefaultFormat)
+	var pieces [][]byte
+	durations := make([]time.Duration, segmentCount)
+	var durationErr error
+	for i := range segments {
+		arranged := h.arrangeSegment(req.Format, job.audio[i], pauses[i])
+		if tag != nil && durationErr == nil {
+			durations[i], durationErr = piecesDuration(req.Format, arranged)
+		}
+		pieces = append(pieces, arranged...)
+	}
+	audioData, err := audioMergeWithFormat(pieces, req.Format)
 	if err != nil {
 		log.Printf("合并音频失败: %v", err)
 		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "音频合并失败: " + err.Error()})
 		return
 	}
 
+	contentType := contentTypeFromFormat(req.Format)
+	if target != nil {
+		if audioData, err = transcodeAudio(ctx, audioData, req.Format, *target); err != nil {
+			log.Printf("转码失败: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "音频转码失败: " + err.Error()})
+			return
+		}
+		contentType = transcode.ContentType(*target)
+	}
+	if tag != nil {
+		// 无法计算某段时长时不写入章节
+		if durationErr != nil {
+			log.Printf("计算分段时长失败，不写入章节: %v", durationErr)
+		} else {
+			tag.Chapters = segmentChapters(segments, durations)
+		}
+		audioData = audio.TagMP3(audioData, *tag)
+	}
+
 	// 设置响应内容类型并写入数据
-	c.Header("Content-Type", contentTypeFromFormat(h.config.TTS.DefaultFormat))
+	c.Header("Content-Type", contentType)
+	h.setCacheHeader(c, int(job.cacheHits) == segmentCount)
+	setDegradedHeader(c, job.degraded)
 	if _, err := c.Writer.Write(audioData); err != nil {
 		log.Printf("写入响应失败: %v", err)
 		return
@@ -592,6 +717,109 @@ func (h *TTSHandler) handleSegmentedTTS(c *gin.Context, req models.TTSRequest) {
 		totalTime, splitTime, synthesisTime, writeTime, formatFileSize(len(audioData)))
 }
 
+// streamSegmentedTTS 按顺序以分块传输写出各段，某段及其之前的段都完成后立即写出
+// 缓存和降级信息在写出音频前无法确定，通过 trailer 返回；章节时间同样未知，ID3 标签中不含章节
+func (h *TTSHandler) streamSegmentedTTS(c *gin.Context, ctx context.Context, cancel context.CancelFunc, req models.TTSRequest, segments []textSegment, pauses []time.Duration, joiner *audio.Joiner, tag *audio.ID3Tag, segmentStart time.Time, splitTime time.Duration) {
+	synthesisStart := time.Now()
+	job := h.startSegments(ctx, cancel, req, segments)
+
+	var written int
+	var started bool
+	var firstByteTime time.Duration
+	for i := range segments {
+		select {
+		case <-job.ready[i]:
+		case err := <-job.errChan:
+			h.abortSegmentStream(c, started, i, err)
+			return
+		case <-ctx.Done():
+			// 失败的段会先写入错误再取消，优先报告该错误
+			select {
+			case err := <-job.errChan:
+				h.abortSegmentStream(c, started, i, err)
+			default:
+				h.abortSegmentStream(c, started, i, errors.New("请求被取消"))
+			}
+			return
+		}
+
+		pieces := h.arrangeSegment(req.Format, job.audio[i], pauses[i])
+		job.audio[i] = nil
+		for _, piece := range pieces {
+			data, err := joiner.Next(piece)
+			if err != nil {
+				h.abortSegmentStream(c, started, i, err)
+				return
+			}
+
+			if !started {
+				c.Header("Content-Type", contentTypeFromFormat(req.Format))
+				c.Header("Trailer", "X-Cache, "+degradedSegmentsHeader)
+				c.Status(http.StatusOK)
+				firstByteTime = time.Since(synthesisStart)
+				started = true
+				if tag != nil {
+					data = append(tag.Bytes(), data...)
+				}
+			}
+			if _, err := c.Writer.Write(data); err != nil {
+				log.Printf("写入音频流失败: %v", err)
+				return
+			}
+			c.Writer.Flush()
+			written += len(data)
+		}
+	}
+
+	job.mu.Lock()
+	degraded := append([]int(nil), job.degraded...)
+	job.mu.Unlock()
+	sort.Ints(degraded)
+
+	synthesisTime := time.Since(synthesisStart)
+	h.logSegmentResults(job, synthesisTime)
+
+	// 响应头已发送，以下头部作为 trailer 写出
+	h.setCacheHeader(c, int(atomic.LoadInt32(&job.cacheHits)) == len(segments))
+	setDegradedHeader(c, degraded)
+
+	totalTime := time.Since(segmentStart)
+	log.Printf("分段TTS流式请求总耗时: %v (分割: %v, 首字节: %v, 合成及写入: %v), 总音频大小: %s",
+		totalTime, splitTime, firstByteTime, synthesisTime, formatFileSize(written))
+}
+
+// abortSegmentStream 处理流式分段输出中的错误：尚未写出音频时返回错误响应，
+// 否则中断连接，让客户端从不完整的分块传输得知音频被截断
+func (h *TTSHandler) abortSegmentStream(c *gin.Context, started bool, index int, err error) {
+	if !started {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("流式输出第 %d 段前出错，中断响应: %v", index+1, err)
+	panic(http.ErrAbortHandler)
+}
+
+// logSegmentResults 打印各段和汇总的结构化日志，便于聚合分析
+func (h *TTSHandler) logSegmentResults(job *segmentJob, synthesisTime time.Duration) {
+	segmentCount := len(job.results)
+	for i := 0; i < segmentCount; i++ {
+		result := job.results[i]
+		log.Printf("segment_result index=%d length=%d audio_size=%s duration_ms=%d content=%q",
+			i+1,
+			result.length,
+			formatFileSize(result.audioSize),
+			result.duration.Milliseconds(),
+			result.content)
+	}
+
+	log.Printf("segment_summary segments=%d degraded=%d total_ms=%d avg_ms=%d queue_wait_ms=%d",
+		segmentCount,
+		len(job.degraded),
+		synthesisTime.Milliseconds(),
+		(synthesisTime/time.Duration(segmentCount)).Milliseconds(),
+		time.Duration(job.queueWait.Load()).Milliseconds())
+}
+
 // HandleReader 返回 reader 可导入的格式
 func (h *TTSHandler) HandleReader(context *gin.Context) {
 	// 从URL参数获取 - 支持完整参数名和简短参数名
@@ -617,11 +845,12 @@ func (h *TTSHandler) HandleReader(context *gin.Context) {
 	}
 
 	req := models.TTSRequest{
-		Text:  text,
-		Voice: voice,
-		Rate:  rate,
-		Pitch: pitch,
-		Style: style,
+		Text:     text,
+		Voice:    voice,
+		Rate:     rate,
+		Pitch:    pitch,
+		Style:    style,
+		Provider: context.Query("provider"),
 	}
 	displayName := context.Query("n")
 	api_key := context.Query("api_key")
@@ -649,6 +878,14 @@ func (h *TTSHandler) HandleReader(context *gin.Context) {
 		urlParams = append(urlParams, fmt.Sprintf("s=%s", req.Style))
 	}
 
+	if req.Provider != "" {
+		urlParams = append(urlParams, fmt.Sprintf("provider=%s", req.Provider))
+	}
+
+	if format := context.Query("format"); format != "" {
+		urlParams = append(urlParams, fmt.Sprintf("f=%s", format))
+	}
+
 	// 只有配置了API密钥且请求提供了api_key参数时才添加
 	if h.config.TTS.ApiKey != "" && api_key != "" {
 		urlParams = append(urlParams, fmt.Sprintf("api_key=%s", api_key))
@@ -687,10 +924,11 @@ func (h *TTSHandler) HandleIFreeTime(context *gin.Context) {
 	}
 
 	req := models.TTSRequest{
-		Voice: voice,
-		Rate:  rate,
-		Pitch: pitch,
-		Style: style,
+		Voice:    voice,
+		Rate:     rate,
+		Pitch:    pitch,
+		Style:    style,
+		Provider: context.Query("provider"),
 	}
 	displayName := context.Query("n")
 	api_key := context.Query("api_key")
@@ -721,6 +959,14 @@ func (h *TTSHandler) HandleIFreeTime(context *gin.Context) {
 		"s": req.Style,
 	}
 
+	if req.Provider != "" {
+		params["provider"] = req.Provider
+	}
+
+	if format := context.Query("format"); format != "" {
+		params["f"] = format
+	}
+
 	// 只有配置了API密钥且请求提供了api_key参数时才添加
 	if h.config.TTS.ApiKey != "" && api_key != "" {
 		params["api_key"] = api_key
@@ -765,11 +1011,21 @@ func (h *TTSHandler) HandleIFreeTime(context *gin.Context) {
 	context.JSON(http.StatusOK, response)
 }
 
-// splitTextBySentences 将文本按句子分割
-func splitTextBySentences(text string, cfg *config.Config) []string {
+// textSegment 是分段合成中的一段文本
+type textSegment struct {
+	text         string
+	paragraphEnd bool // 该段是所在段落的最后一段
+	heading      bool // 该段是单独成行的标题，见 isHeadingLine
+}
+
+// headingMaxLength 单独成行、不以标点结尾且不超过该长度的行视为标题
+const headingMaxLength = 30
+
+// splitTextBySentences 将文本按句子分割，短句只在段落内合并，保留段落边界
+func splitTextBySentences(text string, cfg *config.Config) []textSegment {
 	// 如果文本过短，直接作为一个句子返回
 	if utf8.RuneCountInString(text) < 100 {
-		return []string{text}
+		return []textSegment{{text: text, paragraphEnd: true}}
 	}
 
 	maxLen := cfg.TTS.MaxSentenceLength
@@ -791,8 +1047,11 @@ func splitTextBySentences(text string, cfg *config.Config) []string {
 	}
 
 	// 按标点符号分割每个段落，保留分隔符
-	var sentences []string
+	var paragraphs [][]string
+	var headings []bool
+	sentenceCount := 0
 	for _, line := range lines {
+		var sentences []string
 		var current strings.Builder
 		runes := []rune(line)
 		for i, r := range runes {
@@ -817,30 +1076,61 @@ func splitTextBySentences(text string, cfg *config.Config) []string {
 		if remaining != "" {
 			sentences = append(sentences, remaining)
 		}
+		if len(sentences) > 0 {
+			paragraphs = append(paragraphs, sentences)
+			headings = append(headings, len(sentences) == 1 && isHeadingLine(sentences[0]))
+			sentenceCount += len(sentences)
+		}
 	}
 
 	// 如果没有找到标点符号或分割后只有一段，按长度强制分割
-	if len(sentences) <= 1 {
-		sentences = splitLongTextByLength(text, maxLen)
-	}
-
-	// 合并过短的句子，但确保不超过 maxLen
-	mergedSentences := utils.MergeStringsWithLimit(sentences, minLen, maxLen)
+	if sentenceCount <= 1 {
+		paragraphs = [][]string{splitLongTextByLength(text, maxLen)}
+		headings = []bool{false}
+	}
+	// 标题之后必须还有正文
+	headings[len(headings)-1] = false
+
+	var finalSegments []textSegment
+	for p, sentences := range paragraphs {
+		// 合并过短的句子，但确保不超过 maxLen
+		mergedSentences := utils.MergeStringsWithLimit(sentences, minLen, maxLen)
+
+		// 最终检查：确保没有超过 maxLen 的句子
+		var parts []string
+		for _, sentence := range mergedSentences {
+			sentenceLen := utf8.RuneCountInString(sentence)
+			if sentenceLen <= maxLen {
+				parts = append(parts, sentence)
+			} else {
+				// 如果句子过长，强制分割
+				parts = append(parts, splitLongTextByLength(sentence, maxLen)...)
+			}
+		}
 
-	// 最终检查：确保没有超过 maxLen 的句子
-	var finalSentences []string
-	for _, sentence := range mergedSentences {
-		sentenceLen := utf8.RuneCountInString(sentence)
-		if sentenceLen <= maxLen {
-			finalSentences = append(finalSentences, sentence)
-		} else {
-			// 如果句子过长，强制分割
-			finalSentences = append(finalSentences, splitLongTextByLength(sentence, maxLen)...)
+		for i, part := range parts {
+			finalSegments = append(finalSegments, textSegment{
+				text:         part,
+				paragraphEnd: i == len(parts)-1,
+				heading:      headings[p] && len(parts) == 1,
+			})
 		}
 	}
 
-	log.Printf("分割后的句子数: %d → %d → %d", len(lines), len(sentences), len(finalSentences))
-	return finalSentences
+	log.Printf("分割后的句子数: %d → %d → %d", len(lines), sentenceCount, len(finalSegments))
+	return finalSegments
+}
+
+// isHeadingLine 判断一行是否为标题：以 # 开头（Markdown），或较短且不以标点结尾
+func isHeadingLine(line string) bool {
+	if strings.HasPrefix(line, "#") {
+		return true
+	}
+	if utf8.RuneCountInString(line) > headingMaxLength {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(line)
+	return !unicode.IsPunct(last)
 }
 
 func shouldSkipDotSplit(runes []rune, index int) bool {
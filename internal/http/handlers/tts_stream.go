@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"tts/internal/audio"
+	"tts/internal/models"
+	"tts/internal/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleTTSStream 分段合成文本，按顺序以 SSE 推送每段的音频（segment 事件），
+// 最后推送汇总（done 事件）；出错时推送 error 事件并结束
+func (h *TTSHandler) HandleTTSStream(c *gin.Context) {
+	startTime := time.Now()
+
+	var req models.TTSRequest
+	var ok bool
+	if c.Request.Method == http.MethodGet {
+		req, ok = bindTTSQuery(c)
+	} else {
+		req, ok = bindTTSBody(c)
+	}
+	if !ok {
+		return
+	}
+	if req.Text == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "必须提供文本参数"})
+		return
+	}
+
+	h.fillDefaultValues(&req)
+	if err := h.validateRatePitch(req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateFormat(req.Format); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if utf8.RuneCountInString(req.Text) > h.config.TTS.MaxTextLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "文本长度超过限制"})
+		return
+	}
+
+	// 包含 SSML 标签的文本不分段
+	segments := []textSegment{{text: req.Text, paragraphEnd: true}}
+	if !h.containsSSMLTags(req.Text) {
+		segments = splitTextBySentences(req.Text, h.config)
+	}
+	pauses := h.segmentPauses(segments)
+	// 无法解析的格式只影响时长计算
+	format, _ := audio.ParseFormat(req.Format)
+	contentType := contentTypeFromFormat(req.Format)
+
+	ctx, cancel := context.WithCancel(scheduler.WithTicket(c.Request.Context(), schedulerTicket(c)))
+	defer cancel()
+	job := h.startSegments(ctx, cancel, req, segments)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	summary := models.SegmentSummary{
+		Segments: len(segments),
+		Degraded: []int{},
+	}
+	var offset time.Duration
+	for i, segment := range segments {
+		select {
+		case <-job.ready[i]:
+		case err := <-job.errChan:
+			sendStreamError(c, err)
+			return
+		case <-ctx.Done():
+			// 失败的段会先写入错误再取消，客户端断开时不再推送
+			select {
+			case err := <-job.errChan:
+				sendStreamError(c, err)
+			default:
+			}
+			return
+		}
+
+		data := h.postprocessSegment(format, job.audio[i])
+		job.audio[i] = nil
+		duration, err := audio.Duration(format, data)
+		if err != nil {
+			duration = 0
+		}
+
+		result := job.results[i]
+		c.SSEvent("segment", models.SegmentEvent{
+			Index:       i + 1,
+			Text:        segment.text,
+			Audio:       base64.StdEncoding.EncodeToString(data),
+			ContentType: contentType,
+			Offset:      offset.Milliseconds(),
+			Duration:    duration.Milliseconds(),
+			Pause:       pauses[i].Milliseconds(),
+			CacheHit:    result.cacheHit,
+			Degraded:    result.degraded,
+		})
+		c.Writer.Flush()
+
+		offset += duration + pauses[i]
+		summary.AudioSize += len(data)
+		if result.degraded {
+			summary.Degraded = append(summary.Degraded, i+1)
+		}
+	}
+
+	summary.Duration = offset.Milliseconds()
+	summary.Elapsed = time.Since(startTime).Milliseconds()
+	c.SSEvent("done", summary)
+	c.Writer.Flush()
+
+	log.Printf("SSE分段请求总耗时: %v, 分段数: %d, 降级: %d, 音频大小: %s",
+		time.Since(startTime), len(segments), len(summary.Degraded), formatFileSize(summary.AudioSize))
+}
+
+// sendStreamError 推送 error 事件
+func sendStreamError(c *gin.Context, err error) {
+	log.Printf("SSE分段合成失败: %v", err)
+	c.SSEvent("error", gin.H{"error": err.Error()})
+	c.Writer.Flush()
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tts/internal/config"
+	"tts/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseEvent 是从响应中解析出的一个 SSE 事件
+type sseEvent struct {
+	name string
+	data string
+}
+
+// streamTTS 通过真实的 HTTP 连接请求 HandleTTSStream，返回按到达顺序解析的事件
+func streamTTS(t *testing.T, h *TTSHandler, req models.TTSRequest) []sseEvent {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/tts/stream", h.HandleTTSStream)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	body, _ := json.Marshal(req)
+	resp, err := http.Post(server.URL+"/tts/stream", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/event-stream") {
+		t.Fatalf("content type = %s, want text/event-stream", got)
+	}
+
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current.name != "" {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		case strings.HasPrefix(line, "event:"):
+			current.name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			current.data += strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestStreamEvents(t *testing.T) {
+	service := newScriptedService(map[string]int{"B": -1})
+	cfg := testConfig()
+	cfg.TTS.SegmentFailure.Mode = config.SegmentFailureSilence
+	h := NewTTSHandler(service, cfg)
+
+	events := streamTTS(t, h, models.TTSRequest{Text: threeParagraphs()})
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 3 segments and done: %v", len(events), events)
+	}
+
+	// segment 事件按序号顺序推送，偏移量为之前各段时长与停顿之和
+	var offset int64
+	var audioSize int
+	for i, event := range events[:3] {
+		if event.name != "segment" {
+			t.Fatalf("event %d = %s, want segment", i, event.name)
+		}
+		var segment models.SegmentEvent
+		if err := json.Unmarshal([]byte(event.data), &segment); err != nil {
+			t.Fatal(err)
+		}
+		if segment.Index != i+1 {
+			t.Errorf("event %d index = %d, want %d", i, segment.Index, i+1)
+		}
+		if want := "ABC"[i : i+1]; !strings.HasPrefix(segment.Text, want) {
+			t.Errorf("segment %d text = %q, want prefix %q", segment.Index, segment.Text, want)
+		}
+		if segment.Offset != offset {
+			t.Errorf("segment %d offset = %d, want %d", segment.Index, segment.Offset, offset)
+		}
+		if segment.ContentType != "audio/pcm" {
+			t.Errorf("segment %d content type = %s", segment.Index, segment.ContentType)
+		}
+		if segment.Degraded != (i == 1) {
+			t.Errorf("segment %d degraded = %v", segment.Index, segment.Degraded)
+		}
+		data, err := base64.StdEncoding.DecodeString(segment.Audio)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !segment.Degraded && !bytes.Equal(data, segmentAudio) {
+			t.Errorf("segment %d audio does not match", segment.Index)
+		}
+		// 16kHz 16bit 单声道每毫秒 32 字节
+		if want := int64(len(data) / 32); segment.Duration != want {
+			t.Errorf("segment %d duration = %d, want %d", segment.Index, segment.Duration, want)
+		}
+		offset += segment.Duration + segment.Pause
+		audioSize += len(data)
+	}
+
+	done := events[3]
+	if done.name != "done" {
+		t.Fatalf("last event = %s, want done", done.name)
+	}
+	var summary models.SegmentSummary
+	if err := json.Unmarshal([]byte(done.data), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Segments != 3 {
+		t.Errorf("segments = %d, want 3", summary.Segments)
+	}
+	if len(summary.Degraded) != 1 || summary.Degraded[0] != 2 {
+		t.Errorf("degraded = %v, want [2]", summary.Degraded)
+	}
+	if summary.Duration != offset {
+		t.Errorf("duration = %d, want %d", summary.Duration, offset)
+	}
+	if summary.AudioSize != audioSize {
+		t.Errorf("audio size = %d, want %d", summary.AudioSize, audioSize)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	service := newScriptedService(map[string]int{"C": -1})
+	cfg := testConfig()
+	cfg.TTS.SegmentFailure.Mode = config.SegmentFailureFail
+	h := NewTTSHandler(service, cfg)
+
+	events := streamTTS(t, h, models.TTSRequest{Text: threeParagraphs()})
+	if len(events) == 0 {
+		t.Fatal("no events")
+	}
+
+	// 失败前已就绪的段可能先推送，但必须按顺序，并以唯一的 error 事件结束，不推送 done
+	last := events[len(events)-1]
+	if last.name != "error" {
+		t.Fatalf("last event = %s, want error", last.name)
+	}
+	var payload struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(last.data), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(payload.Error, "upstream failed: C") {
+		t.Errorf("error = %q", payload.Error)
+	}
+	for i, event := range events[:len(events)-1] {
+		if event.name != "segment" {
+			t.Fatalf("event %d = %s, want segment", i, event.name)
+		}
+		var segment models.SegmentEvent
+		if err := json.Unmarshal([]byte(event.data), &segment); err != nil {
+			t.Fatal(err)
+		}
+		if segment.Index != i+1 {
+			t.Fatalf("event %d index = %d, want %d", i, segment.Index, i+1)
+		}
+	}
+	if len(events) > 3 {
+		t.Fatalf("got %d events, want at most 2 segments and error", len(events))
+	}
+}
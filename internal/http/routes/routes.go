@@ -2,14 +2,20 @@ package routes
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
+	"tts/internal/cache"
+	"tts/internal/coalesce"
 	"tts/internal/config"
+	"tts/internal/hedge"
 	"tts/internal/http/handlers"
 	"tts/internal/http/middleware"
+	"tts/internal/scheduler"
 	"tts/internal/tts"
+	"tts/internal/tts/local"
 	"tts/internal/tts/microsoft"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +23,16 @@ import (
 
 // SetupRoutes 配置所有API路由
 func SetupRoutes(cfg *config.Config, ttsService tts.Service, app interface{}) (*gin.Engine, error) {
+	switch cfg.TTS.SegmentFailure.Mode {
+	case "", config.SegmentFailureFail, config.SegmentFailureSilence:
+	default:
+		return nil, fmt.Errorf("未知的 segment_failure.mode: %s", cfg.TTS.SegmentFailure.Mode)
+	}
+	if merge := cfg.TTS.Merge; (merge.TrimSilence || merge.Normalize) && !handlers.Postprocessable(cfg.TTS.DefaultFormat) {
+		log.Printf("警告: 默认格式 %s 是压缩格式，merge.trim_silence 和 merge.normalize 对其不生效，"+
+			"只处理 PCM、G.711 及需要转码的输出格式", cfg.TTS.DefaultFormat)
+	}
+
 	// 创建Gin路由
 	router := gin.New()
 
@@ -51,6 +67,10 @@ func SetupRoutes(cfg *config.Config, ttsService tts.Service, app interface{}) (*
 	// 设置TTS API路由 - 添加认证中间件
 	apiV1.POST("/tts", authHandler, ttsHandler.HandleTTS)
 	apiV1.GET("/tts", authHandler, ttsHandler.HandleTTS)
+	apiV1.POST("/tts/marks", authHandler, ttsHandler.HandleSpeechMarks)
+	apiV1.GET("/tts/marks", authHandler, ttsHandler.HandleSpeechMarks)
+	apiV1.POST("/tts/stream", authHandler, ttsHandler.HandleTTSStream)
+	apiV1.GET("/tts/stream", authHandler, ttsHandler.HandleTTSStream)
 
 	// 设置语音列表API路由
 	apiV1.GET("/voices", voicesHandler.HandleVoices)
@@ -73,11 +93,15 @@ func SetupRoutes(cfg *config.Config, ttsService tts.Service, app interface{}) (*
 
 	// 健康检查接口
 	apiV1.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
+		health := gin.H{
 			"status":  "ok",
 			"service": "tts-api",
 			"version": "v1",
-		})
+		}
+		if reporter, ok := ttsService.(tts.HealthReporter); ok {
+			health["tts"] = reporter.Health()
+		}
+		c.JSON(200, health)
 	})
 
 	// 静态文件服务 - 服务前端应用
@@ -103,22 +127,51 @@ func SetupRoutes(cfg *config.Config, ttsService tts.Service, app interface{}) (*
 	return router, nil
 }
 
-// InitializeServices 初始化所有服务
-func InitializeServices(cfg *config.Config) (tts.Service, error) {
-	// 创建Microsoft TTS客户端
-	ttsClient := microsoft.NewClient(cfg)
+// NewRegistry 返回注册了所有内置后端类型的注册表
+func NewRegistry() *tts.Registry {
+	registry := tts.NewRegistry()
+	registry.Register("microsoft", microsoft.NewProvider)
+	registry.Register("local", local.NewProvider)
+	return registry
+}
+
+// InitializeServices 初始化所有服务；sched 为进程级的全局上游并发调度器，配置重载时沿用同一个
+func InitializeServices(cfg *config.Config, sched *scheduler.Scheduler) (tts.Service, error) {
+	// 按配置创建所有后端
+	ttsRouter, err := NewRegistry().Build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("已加载TTS后端: %v", ttsRouter.Providers())
 
 	// 预热声音列表缓存
 	log.Println("正在初始化声音列表缓存...")
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := ttsClient.WarmupVoicesCache(ctx); err != nil {
+	if err := ttsRouter.WarmupVoicesCache(ctx); err != nil {
 		log.Printf("预热声音列表缓存失败，但不影响服务启动: %v", err)
 		// 注意：预热失败不应该阻止服务启动，因为用户请求时还可以重新尝试
 	} else {
 		log.Println("声音列表缓存预热完成")
 	}
 
-	return ttsClient, nil
+	// 全局上游并发调度，再合并同时进行的相同请求，合并后的请求只占一个名额
+	var service tts.Service = scheduler.NewService(ttsRouter, sched)
+	service = coalesce.NewService(service)
+	if cfg.TTS.Hedge.Enabled {
+		// 对冲请求绕过请求合并，需包在其外层
+		service = hedge.NewService(service, cfg.TTS.Hedge)
+	}
+	if !cfg.Cache.Enabled {
+		return service, nil
+	}
+
+	// 包装合成音频缓存，未命中的请求再经过请求合并
+	audioCache, err := cache.New(cfg.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("初始化音频缓存失败: %w", err)
+	}
+	log.Printf("已启用音频缓存 (磁盘目录: %q)", cfg.Cache.DiskDir)
+	return cache.NewService(service, audioCache), nil
 }
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -10,25 +11,46 @@ import (
 	"time"
 	"tts/internal/config"
 	"tts/internal/http/routes"
+	"tts/internal/scheduler"
+	"tts/internal/tts"
+	"tts/internal/tts/microsoft/mock"
 )
 
+// Options 包含命令行传入的启动选项
+type Options struct {
+	MockUpstream bool // 使用内置的上游替身代替 Microsoft 服务
+}
+
 // App 表示整个TTS应用程序
 type App struct {
 	server     *Server
 	cfg        *config.Config
 	configPath string
+	mock       *mock.Server
+	ttsService tts.Service
+	scheduler  *scheduler.Scheduler // 全局上游并发调度器，重载配置时只调整上限
 }
 
 // NewApp 创建一个新的应用程序实例
-func NewApp(configPath string) (*App, error) {
+func NewApp(configPath string, opts Options) (*App, error) {
 	// 加载配置
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("加载配置失败: %w", err)
 	}
 
+	var mockServer *mock.Server
+	if opts.MockUpstream {
+		mockServer, err = mock.Start("")
+		if err != nil {
+			return nil, err
+		}
+		mockServer.Apply(&cfg.TTS)
+	}
+
 	// 初始化服务
-	ttsService, err := routes.InitializeServices(cfg)
+	sched := scheduler.New(cfg.TTS.MaxConcurrent)
+	ttsService, err := routes.InitializeServices(cfg, sched)
 	if err != nil {
 		return nil, fmt.Errorf("初始化服务失败: %w", err)
 	}
@@ -36,6 +58,9 @@ func NewApp(configPath string) (*App, error) {
 	app := &App{
 		cfg:        cfg,
 		configPath: configPath,
+		mock:       mockServer,
+		ttsService: ttsService,
+		scheduler:  sched,
 	}
 
 	// 设置Gin路由
@@ -90,6 +115,11 @@ func (a *App) Start() error {
 				return fmt.Errorf("服务器关闭出错: %w", err)
 			}
 
+			closeService(a.ttsService)
+			if a.mock != nil {
+				a.mock.Close()
+			}
+
 			log.Println("服务器已优雅关闭")
 			return nil
 		}
@@ -105,22 +135,41 @@ func (a *App) reloadConfig() error {
 	if err != nil {
 		return err
 	}
+	if a.mock != nil {
+		a.mock.Apply(&cfg.TTS)
+	}
 
-	ttsService, err := routes.InitializeServices(cfg)
+	// 新旧服务共用调度器，旧服务中尚未结束的调用继续占用名额
+	ttsService, err := routes.InitializeServices(cfg, a.scheduler)
 	if err != nil {
 		return fmt.Errorf("初始化服务失败: %w", err)
 	}
 
 	router, err := routes.SetupRoutes(cfg, ttsService, a)
 	if err != nil {
+		closeService(ttsService)
 		return fmt.Errorf("设置路由失败: %w", err)
 	}
 
 	a.server.UpdateRouter(router)
 	a.cfg = cfg
+	a.scheduler.SetLimit(cfg.TTS.MaxConcurrent)
+
+	// 切换后关闭旧服务的后台任务，正在处理的请求不受影响
+	closeService(a.ttsService)
+	a.ttsService = ttsService
 	return nil
 }
 
+// closeService 关闭实现了 io.Closer 的服务
+func closeService(service tts.Service) {
+	if closer, ok := service.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("关闭TTS服务失败: %v", err)
+		}
+	}
+}
+
 // Reload 提供给HTTP热重载接口使用
 func (a *App) Reload() error {
 	return a.reloadConfig()
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // TTSRequest 表示一个语音合成请求
 type TTSRequest struct {
 	Text  string `json:"text"`  // 要转换的文本
@@ -7,6 +9,14 @@ type TTSRequest struct {
 	Rate  string `json:"rate"`  // 语速 (-100% 到 +100%)
 	Pitch string `json:"pitch"` // 语调 (-100% 到 +100%)
 	Style string `json:"style"` // 说话风格
+
+	Provider string `json:"provider"` // 指定后端名称，为空时按语音路由
+	Format   string `json:"format"`   // 输出格式，如 audio-24khz-48kbitrate-mono-mp3，为空时使用默认格式
+
+	// MP3 输出的 ID3 元数据，提供任一项即写入元数据
+	Title string `json:"title"` // 标题，为空时使用文本第一行
+	Album string `json:"album"` // 专辑，为空时使用 tts.metadata.album
+	Cover string `json:"cover"` // base64 编码的封面图片（JPEG/PNG），可带 data URI 前缀，为空时使用 tts.metadata.cover
 }
 
 // TTSResponse 表示一个语音合成响应
@@ -14,14 +24,71 @@ type TTSResponse struct {
 	AudioContent []byte `json:"audio_content"` // 音频数据
 	ContentType  string `json:"content_type"`  // MIME类型
 	CacheHit     bool   `json:"cache_hit"`     // 是否命中缓存
+	Fallback     bool   `json:"fallback"`      // 是否由故障转移的备用后端合成，此类音频不写入缓存
+}
+
+// 合成事件类型
+const (
+	EventWordBoundary     = "WordBoundary"
+	EventSentenceBoundary = "SentenceBoundary"
+	EventBookmark         = "Bookmark"
+	EventViseme           = "Viseme"
+)
+
+// SynthesisEvent 表示合成过程中的边界、书签或口型事件
+type SynthesisEvent struct {
+	Type     string        `json:"type"`                // 事件类型
+	Offset   time.Duration `json:"offset"`              // 相对音频开头的偏移
+	Duration time.Duration `json:"duration,omitempty"`  // 边界对应音频的时长
+	Text     string        `json:"text,omitempty"`      // 边界对应的文本
+	Bookmark string        `json:"bookmark,omitempty"`  // 书签名称
+	VisemeID int           `json:"viseme_id,omitempty"` // 口型ID
+}
+
+// TTSEventsResponse 表示带合成事件的语音合成响应
+type TTSEventsResponse struct {
+	TTSResponse
+	Events []SynthesisEvent `json:"events"`
+}
+
+// SpeechMark 表示 Polly 风格的语音标记
+type SpeechMark struct {
+	Time  int64  `json:"time"`  // 相对音频开头的毫秒数
+	Type  string `json:"type"`  // word, sentence, ssml, viseme
+	Start int    `json:"start"` // 文本中的起始字节偏移
+	End   int    `json:"end"`   // 文本中的结束字节偏移
+	Value string `json:"value"`
+}
+
+// SegmentEvent 表示分段合成 SSE 接口中一段的合成结果
+type SegmentEvent struct {
+	Index       int    `json:"index"`              // 分段序号，从 1 开始
+	Text        string `json:"text"`               // 该段的原文
+	Audio       string `json:"audio"`              // base64 编码的音频
+	ContentType string `json:"content_type"`       // 音频的 MIME 类型
+	Offset      int64  `json:"offset_ms"`          // 该段在完整音频中的起始毫秒数
+	Duration    int64  `json:"duration_ms"`        // 该段音频的毫秒数，无法计算时为 0
+	Pause       int64  `json:"pause_ms,omitempty"` // 播放完该段后应停顿的毫秒数
+	CacheHit    bool   `json:"cache_hit"`          // 是否命中缓存
+	Degraded    bool   `json:"degraded,omitempty"` // 合成失败，以静音代替
+}
+
+// SegmentSummary 表示分段合成 SSE 接口的汇总事件
+type SegmentSummary struct {
+	Segments  int   `json:"segments"`    // 分段数
+	Degraded  []int `json:"degraded"`    // 以静音代替的分段序号
+	Duration  int64 `json:"duration_ms"` // 全部音频和停顿的毫秒数
+	AudioSize int   `json:"audio_size"`  // 全部音频的字节数
+	Elapsed   int64 `json:"elapsed_ms"`  // 请求耗时
 }
 
 // OpenAIRequest OpenAI TTS请求结构体
 type OpenAIRequest struct {
-	Model string  `json:"model"`
-	Input string  `json:"input"`
-	Voice string  `json:"voice"`
-	Speed float64 `json:"speed"`
+	Model          string  `json:"model"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice"`
+	Speed          float64 `json:"speed"`
+	ResponseFormat string  `json:"response_format"`
 }
 
 // ReaderResponse reader 响应结构体
@@ -11,4 +11,5 @@ type Voice struct {
 	LocaleName      string   `json:"locale_name"`          // 语言区域显示名称，如 中文(中国)
 	StyleList       []string `json:"style_list,omitempty"` // 支持的说话风格列表
 	SampleRateHertz string   `json:"sample_rate_hertz"`    // 采样率
+	Provider        string   `json:"provider,omitempty"`   // 提供该语音的后端名称
 }
@@ -0,0 +1,350 @@
+// Package scheduler 提供进程级的合成并发调度：全局上游并发上限，按优先级、API Key 和请求公平分配
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Priority 是调度优先级
+type Priority int
+
+const (
+	// Interactive 交互式请求，优先调度
+	Interactive Priority = iota
+	// Batch 批量任务，在交互式请求之后调度
+	Batch
+
+	numPriorities = 2
+)
+
+// interactiveWeight 连续调度多少个交互式请求后让一个批量请求通过，避免批量任务饿死
+const interactiveWeight = 4
+
+// ParsePriority 解析优先级名称，未知值按交互式处理
+func ParsePriority(name string) Priority {
+	if name == "batch" {
+		return Batch
+	}
+	return Interactive
+}
+
+// String 返回优先级名称
+func (p Priority) String() string {
+	if p == Batch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+// Ticket 标识一个等待调度的调用属于哪个 API Key 和哪个请求
+type Ticket struct {
+	Key      string
+	Request  string
+	Priority Priority
+}
+
+// waiter 是一个等待中的调用
+type waiter struct {
+	ready    chan struct{}
+	enqueued time.Time
+	granted  bool
+}
+
+// requestQueue 是一个请求的等待队列，先进先出
+type requestQueue struct {
+	id      string
+	waiters []*waiter
+}
+
+// keyQueue 是一个 API Key 下各请求的队列，请求之间轮转
+type keyQueue struct {
+	key      string
+	requests []*requestQueue
+	next     int
+}
+
+// class 是一个优先级下各 API Key 的队列，Key 之间轮转
+type class struct {
+	keys    []*keyQueue
+	next    int
+	waiting int
+}
+
+// Scheduler 限制全局上游并发数，并在等待者之间公平分配
+type Scheduler struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	classes [numPriorities]class
+	streak  int // 连续调度的交互式请求数
+
+	// 统计
+	granted   [numPriorities]uint64
+	waitTotal [numPriorities]time.Duration
+	waitMax   [numPriorities]time.Duration
+}
+
+// New 创建调度器，limit 为全局上游并发上限
+func New(limit int) *Scheduler {
+	if limit <= 0 {
+		limit = 1
+	}
+	return &Scheduler{limit: limit}
+}
+
+// SetLimit 调整并发上限，用于配置重载；调高时立即唤醒等待者，
+// 调低时已取得名额的调用继续运行，释放到新上限以下后才分配新名额
+func (s *Scheduler) SetLimit(limit int) {
+	if limit <= 0 {
+		limit = 1
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit = limit
+	s.dispatchLocked()
+}
+
+// Acquire 等待一个上游并发名额，返回的 release 必须调用且只能调用一次
+func (s *Scheduler) Acquire(ctx context.Context, t Ticket) (func(), error) {
+	s.mu.Lock()
+	if s.running < s.limit && s.queuedLocked() == 0 {
+		s.running++
+		s.recordLocked(t.Priority, 0)
+		s.mu.Unlock()
+		return s.releaseFunc(), nil
+	}
+	w := &waiter{ready: make(chan struct{}), enqueued: time.Now()}
+	s.enqueueLocked(t, w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return s.releaseFunc(), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if w.granted {
+			// 取消与分配同时发生，把名额交给下一个等待者
+			s.running--
+			s.dispatchLocked()
+		} else {
+			s.removeLocked(t, w)
+		}
+		s.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// releaseFunc 返回释放名额的函数
+func (s *Scheduler) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.running--
+			s.dispatchLocked()
+			s.mu.Unlock()
+		})
+	}
+}
+
+// queuedLocked 返回等待中的调用总数
+func (s *Scheduler) queuedLocked() int {
+	total := 0
+	for i := range s.classes {
+		total += s.classes[i].waiting
+	}
+	return total
+}
+
+// enqueueLocked 把等待者加入对应优先级、Key 和请求的队列
+func (s *Scheduler) enqueueLocked(t Ticket, w *waiter) {
+	c := &s.classes[t.Priority]
+	c.waiting++
+
+	var kq *keyQueue
+	for _, k := range c.keys {
+		if k.key == t.Key {
+			kq = k
+			break
+		}
+	}
+	if kq == nil {
+		kq = &keyQueue{key: t.Key}
+		c.keys = append(c.keys, kq)
+	}
+
+	var rq *requestQueue
+	for _, r := range kq.requests {
+		if r.id == t.Request {
+			rq = r
+			break
+		}
+	}
+	if rq == nil {
+		rq = &requestQueue{id: t.Request}
+		kq.requests = append(kq.requests, rq)
+	}
+	rq.waiters = append(rq.waiters, w)
+}
+
+// removeLocked 移除取消等待的调用
+func (s *Scheduler) removeLocked(t Ticket, w *waiter) {
+	c := &s.classes[t.Priority]
+	for ki, kq := range c.keys {
+		if kq.key != t.Key {
+			continue
+		}
+		for ri, rq := range kq.requests {
+			if rq.id != t.Request {
+				continue
+			}
+			for wi, candidate := range rq.waiters {
+				if candidate == w {
+					rq.waiters = append(rq.waiters[:wi], rq.waiters[wi+1:]...)
+					c.waiting--
+					break
+				}
+			}
+			if len(rq.waiters) == 0 {
+				kq.removeRequest(ri)
+			}
+			break
+		}
+		if len(kq.requests) == 0 {
+			c.removeKey(ki)
+		}
+		return
+	}
+}
+
+// dispatchLocked 在有空闲名额时按优先级和轮转顺序唤醒等待者
+func (s *Scheduler) dispatchLocked() {
+	for s.running < s.limit {
+		p, ok := s.pickClassLocked()
+		if !ok {
+			return
+		}
+		w := s.classes[p].pop()
+		w.granted = true
+		s.running++
+		s.recordLocked(p, time.Since(w.enqueued))
+		close(w.ready)
+	}
+}
+
+// pickClassLocked 交互式优先，但连续调度 interactiveWeight 个后让批量任务通过一个
+func (s *Scheduler) pickClassLocked() (Priority, bool) {
+	interactive := s.classes[Interactive].waiting > 0
+	batch := s.classes[Batch].waiting > 0
+	switch {
+	case interactive && (!batch || s.streak < interactiveWeight):
+		s.streak++
+		return Interactive, true
+	case batch:
+		s.streak = 0
+		return Batch, true
+	default:
+		return 0, false
+	}
+}
+
+// pop 在 Key 之间轮转，再在该 Key 的请求之间轮转，取出队首等待者
+func (c *class) pop() *waiter {
+	ki := c.next % len(c.keys)
+	kq := c.keys[ki]
+
+	ri := kq.next % len(kq.requests)
+	rq := kq.requests[ri]
+	w := rq.waiters[0]
+	rq.waiters = rq.waiters[1:]
+	c.waiting--
+
+	// 下一次从下一个请求、下一个 Key 开始
+	kq.next = ri + 1
+	if len(rq.waiters) == 0 {
+		kq.next = ri
+		kq.removeRequest(ri)
+	}
+	c.next = ki + 1
+	if len(kq.requests) == 0 {
+		c.next = ki
+		c.removeKey(ki)
+	}
+	return w
+}
+
+// removeRequest 移除一个请求队列，并保持轮转位置
+func (k *keyQueue) removeRequest(i int) {
+	k.requests = append(k.requests[:i], k.requests[i+1:]...)
+	if k.next > i {
+		k.next--
+	}
+	if len(k.requests) > 0 {
+		k.next %= len(k.requests)
+	} else {
+		k.next = 0
+	}
+}
+
+// removeKey 移除一个 Key 队列，并保持轮转位置
+func (c *class) removeKey(i int) {
+	c.keys = append(c.keys[:i], c.keys[i+1:]...)
+	if c.next > i {
+		c.next--
+	}
+	if len(c.keys) > 0 {
+		c.next %= len(c.keys)
+	} else {
+		c.next = 0
+	}
+}
+
+// recordLocked 记录一次分配的等待时间
+func (s *Scheduler) recordLocked(p Priority, wait time.Duration) {
+	s.granted[p]++
+	s.waitTotal[p] += wait
+	if wait > s.waitMax[p] {
+		s.waitMax[p] = wait
+	}
+}
+
+// Stats 返回并发数、各优先级的队列深度和等待时间
+func (s *Scheduler) Stats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	classes := make(map[string]interface{}, numPriorities)
+	for p := Priority(0); p < numPriorities; p++ {
+		c := &s.classes[p]
+		stats := map[string]interface{}{
+			"queued":      c.waiting,
+			"keys":        len(c.keys),
+			"granted":     s.granted[p],
+			"max_wait_ms": s.waitMax[p].Milliseconds(),
+		}
+		if s.granted[p] > 0 {
+			stats["avg_wait_ms"] = (s.waitTotal[p] / time.Duration(s.granted[p])).Milliseconds()
+		}
+		if len(c.keys) > 0 {
+			oldest := time.Duration(0)
+			for _, kq := range c.keys {
+				for _, rq := range kq.requests {
+					if len(rq.waiters) > 0 {
+						oldest = max(oldest, time.Since(rq.waiters[0].enqueued))
+					}
+				}
+			}
+			stats["oldest_wait_ms"] = oldest.Milliseconds()
+		}
+		classes[p.String()] = stats
+	}
+
+	return map[string]interface{}{
+		"limit":   s.limit,
+		"running": s.running,
+		"queued":  s.queuedLocked(),
+		"classes": classes,
+	}
+}
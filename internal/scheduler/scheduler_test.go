@@ -0,0 +1,256 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tts/internal/models"
+)
+
+// grantOrder 在名额占满时按顺序排入 tickets，释放名额后返回各等待者得到名额的顺序
+func grantOrder(t *testing.T, tickets []Ticket) []int {
+	t.Helper()
+	s := New(1)
+	release, err := s.Acquire(context.Background(), Ticket{Key: "holder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i, ticket := range tickets {
+		wg.Add(1)
+		go func(i int, ticket Ticket) {
+			defer wg.Done()
+			release, err := s.Acquire(context.Background(), ticket)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			release()
+		}(i, ticket)
+		waitQueued(t, s, i+1)
+	}
+
+	release()
+	wg.Wait()
+	return order
+}
+
+func waitQueued(t *testing.T, s *Scheduler, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); s.Stats()["queued"] != n; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("queued = %v, want %d", s.Stats()["queued"], n)
+		}
+	}
+}
+
+func TestSchedulerFairness(t *testing.T) {
+	interactive := func(key, request string) Ticket {
+		return Ticket{Key: key, Request: request, Priority: Interactive}
+	}
+	batch := func(key, request string) Ticket {
+		return Ticket{Key: key, Request: request, Priority: Batch}
+	}
+
+	tests := []struct {
+		name    string
+		tickets []Ticket
+		want    []int
+	}{
+		{
+			name: "fifo within a request",
+			tickets: []Ticket{
+				interactive("a", "1"), interactive("a", "1"), interactive("a", "1"),
+			},
+			want: []int{0, 1, 2},
+		},
+		{
+			name: "round robin between keys",
+			tickets: []Ticket{
+				interactive("a", "1"), interactive("a", "1"), interactive("a", "1"), interactive("b", "2"),
+			},
+			want: []int{0, 3, 1, 2},
+		},
+		{
+			name: "round robin between requests of a key",
+			tickets: []Ticket{
+				interactive("a", "1"), interactive("a", "1"), interactive("a", "2"), interactive("a", "2"),
+			},
+			want: []int{0, 2, 1, 3},
+		},
+		{
+			name: "keys before requests",
+			tickets: []Ticket{
+				interactive("a", "1"), interactive("a", "2"), interactive("a", "3"), interactive("b", "4"), interactive("b", "4"),
+			},
+			want: []int{0, 3, 1, 4, 2},
+		},
+		{
+			name: "interactive first but batch is not starved",
+			tickets: []Ticket{
+				batch("a", "1"), batch("a", "1"),
+				interactive("b", "2"), interactive("b", "2"), interactive("b", "2"),
+				interactive("b", "2"), interactive("b", "2"), interactive("b", "2"),
+			},
+			want: []int{2, 3, 4, 5, 0, 6, 7, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := grantOrder(t, tt.tickets); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("grant order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := New(1)
+	release, err := s.Acquire(context.Background(), Ticket{Key: "holder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Acquire(ctx, Ticket{Key: "a", Request: "1"})
+		done <- err
+	}()
+	waitQueued(t, s, 1)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	waitQueued(t, s, 0)
+
+	// 取消的等待者不占用名额
+	release()
+	release, err = s.Acquire(context.Background(), Ticket{Key: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if got := s.Stats()["running"]; got != 0 {
+		t.Fatalf("running = %v, want 0", got)
+	}
+}
+
+func TestSchedulerSetLimit(t *testing.T) {
+	s := New(1)
+	holder, err := s.Acquire(context.Background(), Ticket{Key: "holder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan func(), 3)
+	acquire := func(key string) {
+		go func() {
+			release, err := s.Acquire(context.Background(), Ticket{Key: key})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			acquired <- release
+		}()
+	}
+	acquire("a")
+	acquire("b")
+	waitQueued(t, s, 2)
+
+	// 调高上限立即唤醒等待者
+	s.SetLimit(3)
+	releases := []func(){<-acquired, <-acquired}
+	if got := s.Stats()["running"]; got != 3 {
+		t.Fatalf("running = %v, want 3", got)
+	}
+
+	// 调低上限后已运行的调用不受影响，释放到新上限以下才分配新名额
+	s.SetLimit(2)
+	acquire("c")
+	waitQueued(t, s, 1)
+	holder()
+	waitQueued(t, s, 1)
+	releases[0]()
+	releases = append(releases, <-acquired)
+	if got := s.Stats()["running"]; got != 2 {
+		t.Fatalf("running = %v, want 2", got)
+	}
+	releases[1]()
+	releases[2]()
+}
+
+func TestServiceReportsWait(t *testing.T) {
+	s := New(1)
+	holder, err := s.Acquire(context.Background(), Ticket{Key: "holder"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewService(nopService{}, s)
+
+	var total atomic.Int64
+	done := make(chan error, 1)
+	go func() {
+		_, err := service.SynthesizeSpeech(ReportWait(context.Background(), &total), models.TTSRequest{})
+		done <- err
+	}()
+	waitQueued(t, s, 1)
+	time.Sleep(20 * time.Millisecond)
+	holder()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if wait := time.Duration(total.Load()); wait < 20*time.Millisecond {
+		t.Fatalf("reported wait = %v, want at least 20ms", wait)
+	}
+}
+
+// nopService 立即返回空结果
+type nopService struct{}
+
+func (nopService) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	return nil, nil
+}
+
+func (nopService) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	return &models.TTSResponse{}, nil
+}
+
+func (nopService) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	return io.NopCloser(strings.NewReader("")), "", nil
+}
+
+func (nopService) WarmupVoicesCache(ctx context.Context) error {
+	return nil
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		name string
+		want Priority
+	}{
+		{"batch", Batch},
+		{"interactive", Interactive},
+		{"", Interactive},
+		{"unknown", Interactive},
+	}
+
+	for _, tt := range tests {
+		if got := ParsePriority(tt.name); got != tt.want {
+			t.Errorf("ParsePriority(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
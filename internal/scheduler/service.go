@@ -0,0 +1,150 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"tts/internal/models"
+	"tts/internal/tts"
+)
+
+type ticketKey struct{}
+
+// WithTicket 把调度信息放入上下文，供 Service 在请求上游前排队
+func WithTicket(ctx context.Context, t Ticket) context.Context {
+	return context.WithValue(ctx, ticketKey{}, t)
+}
+
+// ticketFrom 取出上下文中的调度信息，没有时视为匿名的交互式请求
+func ticketFrom(ctx context.Context) Ticket {
+	if t, ok := ctx.Value(ticketKey{}).(Ticket); ok {
+		return t
+	}
+	return Ticket{}
+}
+
+// waitReportKey 保存累加排队时间的计数器
+type waitReportKey struct{}
+
+// ReportWait 返回的上下文用于合成请求时，排队等待名额的时间（纳秒）会累加到 total
+func ReportWait(ctx context.Context, total *atomic.Int64) context.Context {
+	return context.WithValue(ctx, waitReportKey{}, total)
+}
+
+// Service 在调用上游前向调度器申请并发名额
+type Service struct {
+	inner     tts.Service
+	scheduler *Scheduler
+}
+
+// NewService 用调度器包装一个 tts.Service
+func NewService(inner tts.Service, scheduler *Scheduler) *Service {
+	return &Service{
+		inner:     inner,
+		scheduler: scheduler,
+	}
+}
+
+// ListVoices 直接转发
+func (s *Service) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	return s.inner.ListVoices(ctx, locale)
+}
+
+// WarmupVoicesCache 直接转发
+func (s *Service) WarmupVoicesCache(ctx context.Context) error {
+	return s.inner.WarmupVoicesCache(ctx)
+}
+
+// acquire 按上下文中的调度信息申请名额，并累加排队时间
+func (s *Service) acquire(ctx context.Context) (func(), error) {
+	start := time.Now()
+	release, err := s.scheduler.Acquire(ctx, ticketFrom(ctx))
+	if total, ok := ctx.Value(waitReportKey{}).(*atomic.Int64); ok {
+		total.Add(int64(time.Since(start)))
+	}
+	return release, err
+}
+
+// SynthesizeSpeech 排队取得名额后调用上游
+func (s *Service) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	release, err := s.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return s.inner.SynthesizeSpeech(ctx, req)
+}
+
+// SynthesizeStream 排队取得名额后调用上游，名额在音频流关闭时释放
+func (s *Service) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	release, err := s.acquire(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	body, contentType, err := s.inner.SynthesizeStream(ctx, req)
+	if err != nil {
+		release()
+		return nil, "", err
+	}
+	return &releaseReader{ReadCloser: body, release: release}, contentType, nil
+}
+
+// SynthesizeWithEvents 排队取得名额后调用上游
+func (s *Service) SynthesizeWithEvents(ctx context.Context, req models.TTSRequest) (*models.TTSEventsResponse, error) {
+	synthesizer, ok := s.inner.(tts.EventSynthesizer)
+	if !ok {
+		return nil, errors.New("当前后端不支持合成事件")
+	}
+	release, err := s.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return synthesizer.SynthesizeWithEvents(ctx, req)
+}
+
+// CatalogueStatus 直接转发
+func (s *Service) CatalogueStatus() map[string]interface{} {
+	if reporter, ok := s.inner.(tts.CatalogueReporter); ok {
+		return reporter.CatalogueStatus()
+	}
+	return nil
+}
+
+// Health 附加调度器状态
+func (s *Service) Health() map[string]interface{} {
+	health := map[string]interface{}{}
+	if reporter, ok := s.inner.(tts.HealthReporter); ok {
+		health = reporter.Health()
+	}
+	health["scheduler"] = s.scheduler.Stats()
+	return health
+}
+
+// Close 直接转发
+func (s *Service) Close() error {
+	if closer, ok := s.inner.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// releaseReader 在音频流关闭时释放名额
+type releaseReader struct {
+	io.ReadCloser
+	release func()
+}
+
+// Fallback 转发被包装音频流的 tts.FallbackReporter
+func (r *releaseReader) Fallback() bool {
+	return tts.IsFallback(r.ReadCloser)
+}
+
+func (r *releaseReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
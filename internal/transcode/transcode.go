@@ -0,0 +1,156 @@
+// Package transcode 把上游合成的音频转码为上游不提供的输出格式，如 FLAC、AAC/M4A、
+// 更高采样率的 WAV 或立体声；PCM、WAV 和 FLAC 在 Go 中直接转换，其它编码调用 ffmpeg
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"tts/internal/audio"
+)
+
+// 作为转码源的上游格式，目标采样率不高于 16kHz 时使用较低的采样率
+const (
+	sourceFormat16k = "raw-16khz-16bit-mono-pcm"
+	sourceFormat24k = "raw-24khz-16bit-mono-pcm"
+)
+
+// ffmpegEncoders 各编码对应的 ffmpeg 编码器
+var ffmpegEncoders = map[string]string{
+	audio.CodecAAC:  "aac",
+	audio.CodecMP3:  "libmp3lame",
+	audio.CodecOpus: "libopus",
+}
+
+// ffmpegMuxers 各容器对应的 ffmpeg 封装格式及允许的编码
+var ffmpegMuxers = map[string]struct {
+	muxer  string
+	codecs []string
+}{
+	audio.ContainerM4A:  {"ipod", []string{audio.CodecAAC}},
+	audio.ContainerAAC:  {"adts", []string{audio.CodecAAC}},
+	audio.ContainerMP3:  {"mp3", []string{audio.CodecMP3}},
+	audio.ContainerOgg:  {"ogg", []string{audio.CodecOpus}},
+	audio.ContainerWebM: {"webm", []string{audio.CodecOpus}},
+}
+
+// Validate 检查能否转码为目标格式，需要 ffmpeg 而未安装时返回错误
+func Validate(target audio.Format) error {
+	if audio.Convertible(target) {
+		return nil
+	}
+	if !ffmpegSupported(target) {
+		return fmt.Errorf("不支持的输出格式: %s", target.Name)
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("输出格式 %s 需要 ffmpeg 转码，但未找到 ffmpeg", target.Name)
+	}
+	return nil
+}
+
+// ffmpegSupported 判断 ffmpeg 能否编码目标格式
+func ffmpegSupported(target audio.Format) bool {
+	muxer, ok := ffmpegMuxers[target.Container]
+	if !ok || ffmpegEncoders[target.Codec] == "" || target.Channels < 1 || target.Channels > 2 {
+		return false
+	}
+	for _, codec := range muxer.codecs {
+		if codec == target.Codec {
+			return true
+		}
+	}
+	return false
+}
+
+// SourceFormat 返回转码为目标格式时应向上游请求的格式
+func SourceFormat(target audio.Format) string {
+	if target.SampleRate <= 16000 {
+		return sourceFormat16k
+	}
+	return sourceFormat24k
+}
+
+// ContentType 返回目标格式的 MIME 类型
+func ContentType(f audio.Format) string {
+	switch f.Container {
+	case audio.ContainerFLAC:
+		return "audio/flac"
+	case audio.ContainerM4A:
+		return "audio/mp4"
+	case audio.ContainerAAC:
+		return "audio/aac"
+	case audio.ContainerRIFF:
+		switch f.Codec {
+		case audio.CodecMulaw:
+			return "audio/mulaw"
+		case audio.CodecAlaw:
+			return "audio/alaw"
+		}
+		return "audio/wav"
+	case audio.ContainerRaw:
+		switch f.Codec {
+		case audio.CodecMulaw:
+			return "audio/basic"
+		case audio.CodecAlaw:
+			return "audio/alaw"
+		}
+		return "audio/pcm"
+	case audio.ContainerOgg:
+		return "audio/ogg"
+	case audio.ContainerWebM:
+		return "audio/webm"
+	}
+	return "audio/mpeg"
+}
+
+// Transcode 把 PCM / G.711 音频转码为目标格式
+func Transcode(ctx context.Context, data []byte, from, to audio.Format) ([]byte, error) {
+	if audio.Convertible(to) {
+		return audio.Convert(data, from, to)
+	}
+	if !ffmpegSupported(to) {
+		return nil, fmt.Errorf("不支持的输出格式: %s", to.Name)
+	}
+	return ffmpegTranscode(ctx, data, from, to)
+}
+
+// ffmpegTranscode 以 WAV 作为输入调用 ffmpeg 转码，M4A 等封装需要可定位的输出，因此使用临时文件
+func ffmpegTranscode(ctx context.Context, data []byte, from, to audio.Format) ([]byte, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, fmt.Errorf("未找到 ffmpeg，请确认已安装并在 PATH 中: %w", err)
+	}
+	if from.Container == audio.ContainerRaw {
+		data = audio.WAV(from, data)
+	}
+
+	tempDir, err := os.MkdirTemp("", "audio_transcode_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	inputFile := filepath.Join(tempDir, "input.wav")
+	if err := os.WriteFile(inputFile, data, 0644); err != nil {
+		return nil, err
+	}
+	outputFile := filepath.Join(tempDir, "output."+to.Container)
+
+	args := []string{"-y", "-hide_banner", "-loglevel", "error", "-i", inputFile,
+		"-ac", strconv.Itoa(to.Channels), "-ar", strconv.Itoa(to.SampleRate),
+		"-c:a", ffmpegEncoders[to.Codec]}
+	if to.Bitrate > 0 {
+		args = append(args, "-b:a", strconv.Itoa(to.Bitrate)+"k")
+	}
+	args = append(args, "-f", ffmpegMuxers[to.Container].muxer, outputFile)
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("ffmpeg 转码失败: %w, output: %s", err, strings.TrimSpace(string(output)))
+	}
+	return os.ReadFile(outputFile)
+}
@@ -0,0 +1,157 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+// CircuitBreaker 按连续失败次数熔断，超时后放行一次半开探测
+type CircuitBreaker struct {
+	failureThreshold int
+	openTimeout      time.Duration
+
+	mu        sync.Mutex
+	state     string
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	lastError string
+}
+
+// NewCircuitBreaker 创建熔断器，参数非正时使用默认值
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = defaultFailureThreshold
+	}
+	if openTimeout <= 0 {
+		openTimeout = defaultOpenTimeout
+	}
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		state:            BreakerClosed,
+	}
+}
+
+// Allow 判断是否放行请求；熔断超时后仅放行一个探测请求
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	default:
+		// 半开状态下同一时间只允许一个探测
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+}
+
+// Success 记录一次成功，关闭熔断器
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure 记录一次失败，达到阈值或探测失败时打开熔断器
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	if b.state == BreakerHalfOpen || b.failures >= b.failureThreshold {
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+	b.probing = false
+}
+
+// Release 放弃一次已放行但未得出结果的请求（如调用方取消）
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+	}
+}
+
+// Snapshot 返回熔断器当前状态，供健康检查展示
+func (b *CircuitBreaker) Snapshot() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	snapshot := map[string]interface{}{
+		"state":    b.state,
+		"failures": b.failures,
+	}
+	if !b.openedAt.IsZero() {
+		snapshot["opened_at"] = b.openedAt.Format(time.RFC3339)
+	}
+	if b.lastError != "" {
+		snapshot["last_error"] = b.lastError
+	}
+	return snapshot
+}
+
+// UnavailableError 标记后端不可用导致的失败，如上游返回 5xx 或 429、本地引擎执行失败
+type UnavailableError struct {
+	Err error
+}
+
+// Unavailable 把错误标记为后端不可用，err 为 nil 时返回 nil
+func Unavailable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &UnavailableError{Err: err}
+}
+
+func (e *UnavailableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnavailable 判断失败是否由后端不可用引起：标记为 UnavailableError 的错误、网络错误和超时。
+// 只有这类失败计入熔断并转移到备用后端，请求本身的错误（如 4xx）换后端也无法成功
+func IsUnavailable(err error) bool {
+	var unavailable *UnavailableError
+	var netErr net.Error
+	return errors.As(err, &unavailable) || errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
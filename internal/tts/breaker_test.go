@@ -0,0 +1,149 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	errUpstream := errors.New("upstream")
+
+	tests := []struct {
+		name      string
+		threshold int
+		timeout   time.Duration
+		steps     func(b *CircuitBreaker)
+		want      string
+		allow     bool
+	}{
+		{
+			name:      "below threshold stays closed",
+			threshold: 3,
+			timeout:   time.Hour,
+			steps: func(b *CircuitBreaker) {
+				b.Failure(errUpstream)
+				b.Failure(errUpstream)
+			},
+			want:  BreakerClosed,
+			allow: true,
+		},
+		{
+			name:      "threshold opens",
+			threshold: 3,
+			timeout:   time.Hour,
+			steps: func(b *CircuitBreaker) {
+				for i := 0; i < 3; i++ {
+					b.Failure(errUpstream)
+				}
+			},
+			want:  BreakerOpen,
+			allow: false,
+		},
+		{
+			name:      "success resets the count",
+			threshold: 2,
+			timeout:   time.Hour,
+			steps: func(b *CircuitBreaker) {
+				b.Failure(errUpstream)
+				b.Success()
+				b.Failure(errUpstream)
+			},
+			want:  BreakerClosed,
+			allow: true,
+		},
+		{
+			name:      "open timeout lets one probe through",
+			threshold: 1,
+			timeout:   time.Millisecond,
+			steps: func(b *CircuitBreaker) {
+				b.Failure(errUpstream)
+				time.Sleep(5 * time.Millisecond)
+				if !b.Allow() {
+					t.Fatal("probe not allowed after open timeout")
+				}
+			},
+			want:  BreakerHalfOpen,
+			allow: false,
+		},
+		{
+			name:      "failed probe reopens",
+			threshold: 1,
+			timeout:   time.Hour,
+			steps: func(b *CircuitBreaker) {
+				b.Failure(errUpstream)
+				b.openedAt = time.Now().Add(-2 * time.Hour)
+				b.Allow()
+				b.Failure(errUpstream)
+			},
+			want:  BreakerOpen,
+			allow: false,
+		},
+		{
+			name:      "successful probe closes",
+			threshold: 1,
+			timeout:   time.Hour,
+			steps: func(b *CircuitBreaker) {
+				b.Failure(errUpstream)
+				b.openedAt = time.Now().Add(-2 * time.Hour)
+				b.Allow()
+				b.Success()
+			},
+			want:  BreakerClosed,
+			allow: true,
+		},
+		{
+			name:      "released probe allows another",
+			threshold: 1,
+			timeout:   time.Hour,
+			steps: func(b *CircuitBreaker) {
+				b.Failure(errUpstream)
+				b.openedAt = time.Now().Add(-2 * time.Hour)
+				b.Allow()
+				b.Release()
+			},
+			want:  BreakerHalfOpen,
+			allow: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(tt.threshold, tt.timeout)
+			tt.steps(b)
+			if got := b.Snapshot()["state"]; got != tt.want {
+				t.Fatalf("state = %v, want %v", got, tt.want)
+			}
+			if got := b.Allow(); got != tt.allow {
+				t.Fatalf("Allow() = %v, want %v", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"marked", Unavailable(errors.New("503")), true},
+		{"wrapped mark", fmt.Errorf("backend: %w", Unavailable(errors.New("429"))), true},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{"deadline", fmt.Errorf("request: %w", context.DeadlineExceeded), true},
+		{"client error", errors.New("TTS API错误: 状态码: 400"), false},
+		{"canceled", context.Canceled, false},
+		{"nil mark", Unavailable(nil), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUnavailable(tt.err); got != tt.want {
+				t.Fatalf("IsUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"tts/internal/models"
+)
+
+// HealthReporter 是可选接口，用于在健康检查中报告后端状态
+type HealthReporter interface {
+	Health() map[string]interface{}
+}
+
+// FallbackReporter 是可选接口，由音频流实现，报告音频是否由故障转移链中的备用后端合成
+type FallbackReporter interface {
+	Fallback() bool
+}
+
+// IsFallback 判断音频流是否来自备用后端，备用后端的音频不应写入缓存
+func IsFallback(body io.Reader) bool {
+	reporter, ok := body.(FallbackReporter)
+	return ok && reporter.Fallback()
+}
+
+// memberReader 包装成员返回的音频流：读取中途的失败计入该成员的熔断器，
+// 并报告音频是否来自备用后端
+type memberReader struct {
+	io.ReadCloser
+	ctx      context.Context
+	member   *failoverMember
+	fallback bool
+	failed   bool
+}
+
+func (r *memberReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && !r.failed && r.ctx.Err() == nil {
+		// 响应头之后的失败（如连接中断）已无法转移，只计入熔断
+		r.failed = true
+		r.member.breaker.Failure(err)
+		log.Printf("后端 %s 音频流读取失败: %v", r.member.name, err)
+	}
+	return n, err
+}
+
+func (r *memberReader) Fallback() bool {
+	return r.fallback
+}
+
+// failoverMember 故障转移链中的一个后端
+type failoverMember struct {
+	name         string
+	service      Service
+	breaker      *CircuitBreaker
+	defaultVoice string // 作为备用后端时使用的语音，为空时沿用请求的语音
+}
+
+// request 返回发往该成员的请求，备用后端改用自己的默认语音
+func (m *failoverMember) request(req models.TTSRequest, primary bool) models.TTSRequest {
+	if !primary && m.defaultVoice != "" {
+		req.Voice = m.defaultVoice
+	}
+	return req
+}
+
+// failed 记录一次失败并返回是否应尝试下一个后端：
+// 调用方取消和请求本身的错误（如 4xx）不计入熔断，直接返回给调用方
+func (m *failoverMember) failed(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || !IsUnavailable(err) {
+		m.breaker.Release()
+		return false
+	}
+	m.breaker.Failure(err)
+	log.Printf("后端 %s 合成失败，尝试下一个后端: %v", m.name, err)
+	return true
+}
+
+// Failover 按顺序尝试多个后端，连续失败的后端会被熔断并跳过
+type Failover struct {
+	members []*failoverMember
+}
+
+// NewFailover 创建故障转移链，names、services、voices 与 breakers 一一对应，第一个为主后端；
+// voices 为各后端的默认语音，请求转移到备用后端时使用；breakers 为各后端的熔断器，
+// 同一后端出现在多条链中时共用一个熔断器
+func NewFailover(names []string, services []Service, voices []string, breakers []*CircuitBreaker) *Failover {
+	f := &Failover{}
+	for i, service := range services {
+		f.members = append(f.members, &failoverMember{
+			name:         names[i],
+			service:      service,
+			breaker:      breakers[i],
+			defaultVoice: voices[i],
+		})
+	}
+	return f
+}
+
+// SynthesizeSpeech 依次尝试未熔断的后端，直到合成成功；备用后端合成的响应设置 Fallback
+func (f *Failover) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	var lastErr error
+	for i, m := range f.members {
+		if !m.breaker.Allow() {
+			continue
+		}
+
+		resp, err := m.service.SynthesizeSpeech(ctx, m.request(req, i == 0))
+		if err == nil {
+			m.breaker.Success()
+			resp.Fallback = i > 0
+			return resp, nil
+		}
+
+		if !m.failed(ctx, err) {
+			return nil, err
+		}
+		lastErr = fmt.Errorf("%s: %w", m.name, err)
+	}
+
+	if lastErr == nil {
+		return nil, errors.New("所有后端均已熔断")
+	}
+	return nil, lastErr
+}
+
+// SynthesizeStream 依次尝试未熔断的后端，直到成功建立音频流；流开始后的错误不再转移，
+// 只计入熔断，返回的音频流实现 FallbackReporter
+func (f *Failover) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	var lastErr error
+	for i, m := range f.members {
+		if !m.breaker.Allow() {
+			continue
+		}
+
+		body, contentType, err := m.service.SynthesizeStream(ctx, m.request(req, i == 0))
+		if err == nil {
+			m.breaker.Success()
+			return &memberReader{ReadCloser: body, ctx: ctx, member: m, fallback: i > 0}, contentType, nil
+		}
+
+		if !m.failed(ctx, err) {
+			return nil, "", err
+		}
+		lastErr = fmt.Errorf("%s: %w", m.name, err)
+	}
+
+	if lastErr == nil {
+		return nil, "", errors.New("所有后端均已熔断")
+	}
+	return nil, "", lastErr
+}
+
+// SynthesizeWithEvents 依次尝试支持合成事件且未熔断的后端
+func (f *Failover) SynthesizeWithEvents(ctx context.Context, req models.TTSRequest) (*models.TTSEventsResponse, error) {
+	var lastErr error
+	for i, m := range f.members {
+		synthesizer, ok := m.service.(EventSynthesizer)
+		if !ok || !m.breaker.Allow() {
+			continue
+		}
+
+		resp, err := synthesizer.SynthesizeWithEvents(ctx, m.request(req, i == 0))
+		if err == nil {
+			m.breaker.Success()
+			return resp, nil
+		}
+
+		if !m.failed(ctx, err) {
+			return nil, err
+		}
+		lastErr = fmt.Errorf("%s: %w", m.name, err)
+	}
+
+	if lastErr == nil {
+		return nil, errors.New("没有可用的支持合成事件的后端")
+	}
+	return nil, lastErr
+}
+
+// ListVoices 返回第一个可用后端的语音列表
+func (f *Failover) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	var lastErr error
+	for _, m := range f.members {
+		voices, err := m.service.ListVoices(ctx, locale)
+		if err == nil {
+			return voices, nil
+		}
+		lastErr = fmt.Errorf("%s: %w", m.name, err)
+	}
+	return nil, lastErr
+}
+
+// WarmupVoicesCache 预热所有成员的语音列表，全部失败时返回错误
+func (f *Failover) WarmupVoicesCache(ctx context.Context) error {
+	var errs []error
+	for _, m := range f.members {
+		if err := m.service.WarmupVoicesCache(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+		}
+	}
+	if len(errs) == len(f.members) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// CatalogueStatus 返回首个成员的语音列表状态，与 ListVoices 的优先顺序一致
+func (f *Failover) CatalogueStatus() map[string]interface{} {
+	for _, m := range f.members {
+		if reporter, ok := m.service.(CatalogueReporter); ok {
+			return reporter.CatalogueStatus()
+		}
+	}
+	return nil
+}
+
+// Health 返回各成员的熔断器状态及成员自身报告的状态；只有一个成员时直接返回其自身状态
+func (f *Failover) Health() map[string]interface{} {
+	if len(f.members) == 1 {
+		if reporter, ok := f.members[0].service.(HealthReporter); ok {
+			return reporter.Health()
+		}
+		return map[string]interface{}{}
+	}
+
+	members := make([]map[string]interface{}, 0, len(f.members))
+	for _, m := range f.members {
+		snapshot := m.breaker.Snapshot()
+		snapshot["name"] = m.name
+		if reporter, ok := m.service.(HealthReporter); ok {
+			snapshot["health"] = reporter.Health()
+		}
+		members = append(members, snapshot)
+	}
+	return map[string]interface{}{
+		"failover": members,
+	}
+}
+
+// Close 关闭实现了 io.Closer 的成员，成员的 Close 需可重复调用
+func (f *Failover) Close() error {
+	var errs []error
+	for _, m := range f.members {
+		if closer, ok := m.service.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", m.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,205 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"tts/internal/models"
+)
+
+// stubService 按预设的错误返回合成结果，并记录收到的请求
+type stubService struct {
+	err     error
+	readErr error // 不为 nil 时音频流读完数据后返回该错误
+	calls  int
+	voices []string
+	health map[string]interface{}
+}
+
+func (s *stubService) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	return nil, nil
+}
+
+func (s *stubService) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	s.calls++
+	s.voices = append(s.voices, req.Voice)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.TTSResponse{AudioContent: []byte(req.Voice)}, nil
+}
+
+func (s *stubService) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	resp, err := s.SynthesizeSpeech(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	var body io.Reader = bytes.NewReader(resp.AudioContent)
+	if s.readErr != nil {
+		body = io.MultiReader(body, iotest.ErrReader(s.readErr))
+	}
+	return io.NopCloser(body), "audio/mpeg", nil
+}
+
+func (s *stubService) WarmupVoicesCache(ctx context.Context) error {
+	return nil
+}
+
+func (s *stubService) Health() map[string]interface{} {
+	return s.health
+}
+
+func newTestFailover(primary, backup *stubService) *Failover {
+	return NewFailover(
+		[]string{"primary", "backup"},
+		[]Service{primary, backup},
+		[]string{"primary-voice", "backup-voice"},
+		[]*CircuitBreaker{NewCircuitBreaker(1, time.Hour), NewCircuitBreaker(1, time.Hour)},
+	)
+}
+
+func TestFailoverSynthesizeSpeech(t *testing.T) {
+	tests := []struct {
+		name         string
+		primaryErr   error
+		backupErr    error
+		wantErr      bool
+		wantFallback bool
+		wantVoice    string
+		backupCalls  int
+		primaryState string
+	}{
+		{
+			name:         "primary succeeds",
+			wantVoice:    "requested",
+			primaryState: BreakerClosed,
+		},
+		{
+			name:         "unavailable primary falls back with backup voice",
+			primaryErr:   Unavailable(errors.New("503")),
+			wantFallback: true,
+			wantVoice:    "backup-voice",
+			backupCalls:  1,
+			primaryState: BreakerOpen,
+		},
+		{
+			name:         "client error passes through",
+			primaryErr:   errors.New("400"),
+			wantErr:      true,
+			primaryState: BreakerClosed,
+		},
+		{
+			name:         "all unavailable",
+			primaryErr:   Unavailable(errors.New("503")),
+			backupErr:    Unavailable(errors.New("503")),
+			wantErr:      true,
+			backupCalls:  1,
+			primaryState: BreakerOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primary := &stubService{err: tt.primaryErr}
+			backup := &stubService{err: tt.backupErr}
+			f := newTestFailover(primary, backup)
+
+			resp, err := f.SynthesizeSpeech(context.Background(), models.TTSRequest{Voice: "requested"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if resp.Fallback != tt.wantFallback {
+					t.Errorf("Fallback = %v, want %v", resp.Fallback, tt.wantFallback)
+				}
+				if got := string(resp.AudioContent); got != tt.wantVoice {
+					t.Errorf("voice = %q, want %q", got, tt.wantVoice)
+				}
+			}
+			if backup.calls != tt.backupCalls {
+				t.Errorf("backup calls = %d, want %d", backup.calls, tt.backupCalls)
+			}
+			if got := f.members[0].breaker.Snapshot()["state"]; got != tt.primaryState {
+				t.Errorf("primary state = %v, want %v", got, tt.primaryState)
+			}
+		})
+	}
+}
+
+func TestFailoverStreamMarksFallback(t *testing.T) {
+	primary := &stubService{err: Unavailable(errors.New("503"))}
+	f := newTestFailover(primary, &stubService{})
+
+	body, _, err := f.SynthesizeStream(context.Background(), models.TTSRequest{Voice: "requested"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if !IsFallback(body) {
+		t.Fatal("fallback stream not marked")
+	}
+
+	// 主后端熔断后仍由备用后端合成
+	body, _, err = f.SynthesizeStream(context.Background(), models.TTSRequest{Voice: "requested"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer body.Close()
+	if !IsFallback(body) || primary.calls != 1 {
+		t.Fatalf("IsFallback = %v, primary calls = %d", IsFallback(body), primary.calls)
+	}
+}
+
+func TestFailoverStreamReadError(t *testing.T) {
+	tests := []struct {
+		name      string
+		cancel    bool
+		wantState string
+	}{
+		{"read failure opens the breaker", false, BreakerOpen},
+		{"cancelled caller is not counted", true, BreakerClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readErr := io.ErrUnexpectedEOF
+			f := newTestFailover(&stubService{readErr: readErr}, &stubService{})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			body, _, err := f.SynthesizeStream(ctx, models.TTSRequest{Voice: "requested"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.cancel {
+				cancel()
+			}
+			if _, err := io.ReadAll(body); !errors.Is(err, readErr) {
+				t.Fatalf("read err = %v, want %v", err, readErr)
+			}
+			body.Close()
+			if got := f.members[0].breaker.Snapshot()["state"]; got != tt.wantState {
+				t.Fatalf("primary state = %v, want %v", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestFailoverHealthIncludesMembers(t *testing.T) {
+	primary := &stubService{health: map[string]interface{}{"region": "eastasia"}}
+	f := newTestFailover(primary, &stubService{})
+
+	members := f.Health()["failover"].([]map[string]interface{})
+	if len(members) != 2 {
+		t.Fatalf("members = %d, want 2", len(members))
+	}
+	health, ok := members[0]["health"].(map[string]interface{})
+	if !ok || health["region"] != "eastasia" {
+		t.Fatalf("primary health = %v", members[0]["health"])
+	}
+}
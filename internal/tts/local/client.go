@@ -0,0 +1,322 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"tts/internal/audio"
+	"tts/internal/config"
+	"tts/internal/models"
+	"tts/internal/tts"
+)
+
+const (
+	// EngineEspeak espeak-ng 引擎
+	EngineEspeak = "espeak-ng"
+	// EnginePiper piper 引擎
+	EnginePiper = "piper"
+
+	espeakDefaultSpeed = 175 // espeak-ng 默认语速（词/分钟）
+	espeakDefaultPitch = 50  // espeak-ng 默认音调（0-99）
+)
+
+// Client 是调用本地命令行引擎的TTS实现
+type Client struct {
+	engine        string
+	command       string
+	voicesDir     string
+	defaultVoice  string
+	defaultRate   string
+	defaultPitch  string
+	defaultFormat string
+	maxTextLength int
+	timeout       time.Duration
+
+	voicesCache   []localVoice
+	voicesByName  map[string]localVoice
+	voicesCacheMu sync.RWMutex
+}
+
+// NewProvider 按后端配置创建本地引擎客户端，供 tts.Registry 使用
+func NewProvider(cfg *config.Config, provider config.ProviderConfig) (tts.Service, error) {
+	engine := provider.Engine
+	if engine == "" {
+		engine = EngineEspeak
+	}
+
+	voicesDir := provider.VoicesDir
+	switch engine {
+	case EngineEspeak:
+		if voicesDir == "" {
+			voicesDir = defaultEspeakDataDir()
+		}
+	case EnginePiper:
+		if voicesDir == "" {
+			return nil, errors.New("piper 引擎必须配置 voices_dir")
+		}
+	default:
+		return nil, fmt.Errorf("未知的本地引擎: %s", engine)
+	}
+
+	command := provider.Command
+	if command == "" {
+		command = engine
+	}
+	if _, err := exec.LookPath(command); err != nil {
+		log.Printf("未找到本地引擎 %s，合成请求将失败: %v", command, err)
+	}
+
+	return &Client{
+		engine:        engine,
+		command:       command,
+		voicesDir:     voicesDir,
+		defaultVoice:  provider.DefaultVoice,
+		defaultRate:   cfg.TTS.DefaultRate,
+		defaultPitch:  cfg.TTS.DefaultPitch,
+		defaultFormat: cfg.TTS.DefaultFormat,
+		maxTextLength: cfg.TTS.MaxTextLength,
+		timeout:       time.Duration(cfg.TTS.RequestTimeout) * time.Second,
+		voicesByName:  make(map[string]localVoice),
+	}, nil
+}
+
+// loadVoices 扫描语音数据目录并缓存结果
+func (c *Client) loadVoices() ([]localVoice, error) {
+	c.voicesCacheMu.RLock()
+	if c.voicesCache != nil {
+		voices := c.voicesCache
+		c.voicesCacheMu.RUnlock()
+		return voices, nil
+	}
+	c.voicesCacheMu.RUnlock()
+
+	if c.voicesDir == "" {
+		return nil, fmt.Errorf("未找到 %s 语音数据目录", c.engine)
+	}
+
+	var voices []localVoice
+	var err error
+	if c.engine == EnginePiper {
+		voices, err = scanPiperVoices(c.voicesDir)
+	} else {
+		voices, err = scanEspeakVoices(c.voicesDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("扫描语音数据失败: %w", err)
+	}
+	if voices == nil {
+		voices = []localVoice{}
+	}
+
+	byName := make(map[string]localVoice, len(voices))
+	for _, v := range voices {
+		byName[v.voice.ShortName] = v
+	}
+
+	c.voicesCacheMu.Lock()
+	c.voicesCache = voices
+	c.voicesByName = byName
+	c.voicesCacheMu.Unlock()
+
+	return voices, nil
+}
+
+// ListVoices 获取本地已安装的语音列表
+func (c *Client) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	voices, err := c.loadVoices()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Voice, 0, len(voices))
+	for _, v := range voices {
+		// 精确匹配或前缀匹配
+		if locale == "" || v.voice.Locale == locale || strings.HasPrefix(v.voice.Locale, locale+"-") {
+			result = append(result, v.voice)
+		}
+	}
+	return result, nil
+}
+
+// WarmupVoicesCache 预热声音列表缓存
+func (c *Client) WarmupVoicesCache(ctx context.Context) error {
+	voices, err := c.loadVoices()
+	if err != nil {
+		return err
+	}
+	log.Printf("本地引擎 %s 发现 %d 个语音", c.engine, len(voices))
+	return nil
+}
+
+// resolveVoice 查找请求的语音，未安装时回退到默认语音
+func (c *Client) resolveVoice(name string) (localVoice, error) {
+	if _, err := c.loadVoices(); err != nil {
+		return localVoice{}, err
+	}
+
+	c.voicesCacheMu.RLock()
+	defer c.voicesCacheMu.RUnlock()
+
+	if v, ok := c.voicesByName[name]; ok {
+		return v, nil
+	}
+	if v, ok := c.voicesByName[c.defaultVoice]; ok {
+		return v, nil
+	}
+	if c.engine == EngineEspeak && len(c.voicesCache) == 0 {
+		// 无法扫描语音数据时直接交给 espeak-ng 处理
+		return localVoice{voice: models.Voice{ShortName: name}, sampleRate: 22050}, nil
+	}
+	return localVoice{}, fmt.Errorf("未安装的语音: %s", name)
+}
+
+// SynthesizeSpeech 调用本地引擎将文本转换为语音
+func (c *Client) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	if req.Text == "" {
+		return nil, errors.New("文本不能为空")
+	}
+
+	textLen := utf8.RuneCountInString(req.Text)
+	if textLen > c.maxTextLength {
+		return nil, fmt.Errorf("文本长度超过限制 (%d > %d)", textLen, c.maxTextLength)
+	}
+
+	voice, err := c.resolveVoice(req.Voice)
+	if err != nil {
+		return nil, err
+	}
+
+	rate := req.Rate
+	if rate == "" {
+		rate = c.defaultRate
+	}
+	pitch := req.Pitch
+	if pitch == "" {
+		pitch = c.defaultPitch
+	}
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	var args []string
+	switch c.engine {
+	case EnginePiper:
+		args = []string{
+			"--model", voice.path,
+			"--output-raw",
+			"--length_scale", strconv.FormatFloat(piperLengthScale(parsePercent(rate)), 'f', 3, 64),
+		}
+	default:
+		args = []string{
+			"--stdout", "--stdin",
+			"-v", voice.voice.ShortName,
+			"-s", strconv.Itoa(espeakSpeed(parsePercent(rate))),
+			"-p", strconv.Itoa(espeakPitch(parsePercent(pitch))),
+		}
+		if strings.Contains(req.Text, "<speak") {
+			args = append(args, "-m")
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, c.command, args...)
+	cmd.Stdin = strings.NewReader(req.Text)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, tts.Unavailable(fmt.Errorf("%s 合成失败: %w, output: %s", c.engine, err, strings.TrimSpace(stderr.String())))
+	}
+
+	data := stdout.Bytes()
+	if c.engine == EnginePiper {
+		data = audio.WAV(audio.Format{
+			Codec:         audio.CodecPCM,
+			SampleRate:    voice.sampleRate,
+			BitsPerSample: 16,
+			Channels:      1,
+		}, data)
+	}
+
+	// 引擎输出 WAV，转换为请求的格式
+	format := req.Format
+	if format == "" {
+		format = c.defaultFormat
+	}
+	data, contentType, err := convertOutput(ctx, data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TTSResponse{
+		AudioContent: data,
+		ContentType:  contentType,
+		CacheHit:     false,
+	}, nil
+}
+
+// SynthesizeStream 本地引擎需先完成转换，合成结束后一次性返回
+func (c *Client) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	resp, err := c.SynthesizeSpeech(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	return io.NopCloser(bytes.NewReader(resp.AudioContent)), resp.ContentType, nil
+}
+
+// parsePercent 解析 "+10"、"-20"、"15%" 形式的百分比，非法值视为 0
+func parsePercent(value string) int {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "%")
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	if n < -100 {
+		return -100
+	}
+	if n > 100 {
+		return 100
+	}
+	return n
+}
+
+// espeakSpeed 将百分比语速映射为 espeak-ng 的 -s 参数
+func espeakSpeed(rate int) int {
+	speed := espeakDefaultSpeed * (100 + rate) / 100
+	if speed < 80 {
+		speed = 80
+	}
+	return speed
+}
+
+// espeakPitch 将百分比音调映射为 espeak-ng 的 -p 参数
+func espeakPitch(pitch int) int {
+	p := espeakDefaultPitch + pitch/2
+	if p < 0 {
+		p = 0
+	}
+	if p > 99 {
+		p = 99
+	}
+	return p
+}
+
+// piperLengthScale 将百分比语速映射为 piper 的 --length_scale，数值越大越慢
+func piperLengthScale(rate int) float64 {
+	if rate < -90 {
+		rate = -90
+	}
+	return 100.0 / float64(100+rate)
+}
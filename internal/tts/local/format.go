@@ -0,0 +1,32 @@
+package local
+
+import (
+	"context"
+	"fmt"
+
+	"tts/internal/audio"
+	"tts/internal/transcode"
+)
+
+// convertOutput 把引擎输出的 WAV 转换为目标格式，返回音频及其 MIME 类型：
+// PCM / G.711 / FLAC 在 Go 中重采样和编码，其它编码调用 ffmpeg；
+// 无法转换时返回错误，不返回与格式名不符的音频
+func convertOutput(ctx context.Context, wav []byte, format string) ([]byte, string, error) {
+	target, err := audio.ParseFormat(format)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := transcode.Validate(target); err != nil {
+		return nil, "", err
+	}
+	from, _, err := audio.ParseWAV(wav)
+	if err != nil {
+		return nil, "", fmt.Errorf("无法解析引擎输出的 WAV: %w", err)
+	}
+
+	data, err := transcode.Transcode(ctx, wav, from, target)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, transcode.ContentType(target), nil
+}
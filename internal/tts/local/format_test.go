@@ -0,0 +1,80 @@
+package local
+
+import (
+	"context"
+	"testing"
+
+	"tts/internal/audio"
+)
+
+func TestConvertOutput(t *testing.T) {
+	// piper 输出 22050Hz 的单声道 WAV
+	source := audio.Format{Codec: audio.CodecPCM, SampleRate: 22050, BitsPerSample: 16, Channels: 1}
+	wav := audio.WAV(source, make([]byte, 2*22050))
+
+	tests := []struct {
+		format          string
+		wantRate        int
+		wantChannels    int
+		wantContentType string
+	}{
+		{"riff-22khz-16bit-mono-pcm", 22050, 1, "audio/wav"},
+		{"riff-44khz-16bit-stereo-pcm", 44100, 2, "audio/wav"},
+		{"riff-8khz-8bit-mono-mulaw", 8000, 1, "audio/mulaw"},
+		{"raw-16khz-16bit-mono-pcm", 16000, 1, "audio/pcm"},
+		{"flac-48khz-16bit-stereo-flac", 48000, 2, "audio/flac"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data, contentType, err := convertOutput(context.Background(), wav, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contentType != tt.wantContentType {
+				t.Errorf("content type = %s, want %s", contentType, tt.wantContentType)
+			}
+
+			target, err := audio.ParseFormat(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if target.SampleRate != tt.wantRate || target.Channels != tt.wantChannels {
+				t.Fatalf("target = %d Hz / %d ch, want %d Hz / %d ch", target.SampleRate, target.Channels, tt.wantRate, tt.wantChannels)
+			}
+			if target.Container == audio.ContainerRIFF {
+				got, _, err := audio.ParseWAV(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.SampleRate != tt.wantRate || got.Channels != tt.wantChannels {
+					t.Errorf("output = %d Hz / %d ch, want %d Hz / %d ch", got.SampleRate, got.Channels, tt.wantRate, tt.wantChannels)
+				}
+			}
+			if d, err := audio.Duration(target, data); err == nil && d.Seconds() != 1 {
+				t.Errorf("duration = %v, want 1s", d)
+			}
+		})
+	}
+}
+
+func TestConvertOutputErrors(t *testing.T) {
+	wav := audio.WAV(audio.Format{Codec: audio.CodecPCM, SampleRate: 16000, BitsPerSample: 16, Channels: 1}, make([]byte, 320))
+
+	tests := []struct {
+		name   string
+		data   []byte
+		format string
+	}{
+		{"unknown format", wav, "not-a-format"},
+		{"invalid engine output", []byte("not a wav file"), "riff-16khz-16bit-mono-pcm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := convertOutput(context.Background(), tt.data, tt.format); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
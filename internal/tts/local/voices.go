@@ -0,0 +1,196 @@
+package local
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"tts/internal/models"
+)
+
+// localVoice 本地引擎的一个语音及其数据文件
+type localVoice struct {
+	voice      models.Voice
+	path       string // piper 模型路径
+	sampleRate int
+}
+
+// espeakDataDirs espeak-ng 语音数据的常见安装位置
+var espeakDataDirs = []string{
+	"/usr/share/espeak-ng-data",
+	"/usr/lib/x86_64-linux-gnu/espeak-ng-data",
+	"/usr/lib/aarch64-linux-gnu/espeak-ng-data",
+	"/usr/local/share/espeak-ng-data",
+}
+
+// defaultEspeakDataDir 返回第一个存在的 espeak-ng 数据目录
+func defaultEspeakDataDir() string {
+	for _, dir := range espeakDataDirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+// scanEspeakVoices 扫描 espeak-ng-data/lang 下的语言文件
+func scanEspeakVoices(dataDir string) ([]localVoice, error) {
+	langDir := filepath.Join(dataDir, "lang")
+	var voices []localVoice
+	seen := make(map[string]bool)
+
+	err := filepath.WalkDir(langDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		name, language, gender, err := parseEspeakVoiceFile(path)
+		if err != nil || language == "" || seen[language] {
+			return nil
+		}
+		seen[language] = true
+
+		if name == "" {
+			name = d.Name()
+		}
+		voices = append(voices, localVoice{
+			voice: models.Voice{
+				Name:            "espeak-ng " + language,
+				DisplayName:     name,
+				LocalName:       name,
+				ShortName:       language,
+				Gender:          gender,
+				Locale:          normalizeLocale(language),
+				LocaleName:      name,
+				SampleRateHertz: "22050",
+			},
+			sampleRate: 22050,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return voices, nil
+}
+
+// parseEspeakVoiceFile 读取语言文件中的 name、language 和 gender
+func parseEspeakVoiceFile(path string) (name, language, gender string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", "", "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "name":
+			if name == "" {
+				name = strings.Join(fields[1:], " ")
+			}
+		case "language":
+			if language == "" {
+				language = fields[1]
+			}
+		case "gender":
+			if gender == "" {
+				gender = strings.ToUpper(fields[1][:1]) + fields[1][1:]
+			}
+		}
+	}
+	return name, language, gender, scanner.Err()
+}
+
+// piperModelConfig piper 模型旁 .onnx.json 文件中用到的字段
+type piperModelConfig struct {
+	Dataset  string `json:"dataset"`
+	Language struct {
+		Code           string `json:"code"`
+		NameNative     string `json:"name_native"`
+		NameEnglish    string `json:"name_english"`
+		CountryEnglish string `json:"country_english"`
+	} `json:"language"`
+	Audio struct {
+		SampleRate int `json:"sample_rate"`
+	} `json:"audio"`
+}
+
+// scanPiperVoices 扫描目录下的 *.onnx 模型及其配置
+func scanPiperVoices(dir string) ([]localVoice, error) {
+	var voices []localVoice
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".onnx") {
+			return nil
+		}
+
+		raw, err := os.ReadFile(path + ".json")
+		if err != nil {
+			return nil
+		}
+		var mc piperModelConfig
+		if err := json.Unmarshal(raw, &mc); err != nil {
+			return nil
+		}
+
+		shortName := strings.TrimSuffix(d.Name(), ".onnx")
+		sampleRate := mc.Audio.SampleRate
+		if sampleRate == 0 {
+			sampleRate = 22050
+		}
+		displayName := mc.Dataset
+		if displayName == "" {
+			displayName = shortName
+		}
+		localeName := mc.Language.NameEnglish
+		if mc.Language.CountryEnglish != "" {
+			localeName += " (" + mc.Language.CountryEnglish + ")"
+		}
+
+		voices = append(voices, localVoice{
+			voice: models.Voice{
+				Name:            "piper " + shortName,
+				DisplayName:     displayName,
+				LocalName:       displayName,
+				ShortName:       shortName,
+				Locale:          normalizeLocale(mc.Language.Code),
+				LocaleName:      localeName,
+				SampleRateHertz: strconv.Itoa(sampleRate),
+			},
+			path:       path,
+			sampleRate: sampleRate,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return voices, nil
+}
+
+// normalizeLocale 将 en_us / en-us 统一为 en-US
+func normalizeLocale(code string) string {
+	parts := strings.Split(strings.ReplaceAll(code, "_", "-"), "-")
+	parts[0] = strings.ToLower(parts[0])
+	if len(parts) > 1 && len(parts[1]) == 2 {
+		parts[1] = strings.ToUpper(parts[1])
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,42 @@
+package microsoft
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const azureTokenEndpoint = "https://%s.api.cognitive.microsoft.com/sts/v1.0/issueToken"
+
+// issueAzureToken 使用订阅密钥换取访问令牌，返回与 utils.GetEndpoint 相同结构的端点信息
+func (c *Client) issueAzureToken(ctx context.Context) (map[string]interface{}, error) {
+	url := fmt.Sprintf(azureTokenEndpoint, c.region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", c.subscriptionKey)
+	req.Header.Set("Content-Length", "0")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("获取Azure访问令牌失败: %s, 状态码: %d", strings.TrimSpace(string(body)), resp.StatusCode)
+	}
+
+	token := strings.TrimSpace(string(body))
+	return map[string]interface{}{
+		"r": c.region,
+		"t": "Bearer " + token,
+	}, nil
+}
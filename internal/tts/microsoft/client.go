@@ -17,13 +17,14 @@ import (
 
 	"tts/internal/config"
 	"tts/internal/models"
-	"tts/internal/utils"
+	"tts/internal/tts"
 )
 
 const (
 	userAgent      = "okhttp/4.5.0"
 	voicesEndpoint = "https://%s.tts.speech.microsoft.com/cognitiveservices/voices/list"
 	ttsEndpoint    = "https://%s.tts.speech.microsoft.com/cognitiveservices/v1"
+	voicesCacheTTL = 8 * time.Hour
 	ssmlTemplate   = `<speak version='1.0' xmlns='http://www.w3.org/2001/10/synthesis' xmlns:mstts="http://www.w3.org/2001/mstts" xml:lang='%s'>
     <voice name='%s'>
         <mstts:express-as style="%s" styledegree="1.0" role="default">
@@ -47,6 +48,16 @@ type Client struct {
 	voicesCacheMu     sync.RWMutex
 	voicesCacheExpiry time.Time
 	localeCache       map[string]cachedVoices
+	voicesSource      string    // 当前语音列表来源：live、snapshot 或 embedded
+	voicesUpdatedAt   time.Time // 当前语音列表从上游获取的时间
+
+	// 最近一次从上游获取的语音列表，上游不可用时作为回退
+	snapshotVoices []models.Voice
+	snapshotAt     time.Time
+
+	// 状态文件，为空时不持久化
+	stateFile string
+	stateMu   sync.Mutex
 
 	voicesCacheHit   uint64
 	voicesCacheMiss  uint64
@@ -54,10 +65,32 @@ type Client struct {
 	localeCacheMiss  uint64
 
 	// 端点和认证信息
-	endpoint       map[string]interface{}
-	endpointMu     sync.RWMutex
-	endpointExpiry time.Time
-	ssmProcessor   *config.SSMLProcessor
+	tokens        []*tokenSlot // 令牌池，由 endpointMu 保护
+	tokenStrategy string       // 令牌选择策略：round_robin 或 lru
+	tokenNext     uint64
+	tokensRetired uint64
+	endpointMu    sync.RWMutex
+	stop          chan struct{}
+	closeOnce     sync.Once
+	ssmProcessor  *config.SSMLProcessor
+
+	// 候选区域，为 nil 时使用令牌返回的区域
+	regions *regionSelector
+
+	// 合成请求的重试策略和统计
+	retry      *retryPolicy
+	retryStats *retryStats
+
+	// 上游地址
+	endpointURL  string
+	voicesURL    string
+	synthesisURL string
+	websocketURL string
+
+	// Azure 订阅密钥认证
+	authMode        string
+	region          string
+	subscriptionKey string
 }
 
 type cachedVoices struct {
@@ -72,6 +105,10 @@ func NewClient(cfg *config.Config) *Client {
 	if err != nil {
 		log.Fatalf("创建SSML处理器失败: %v", err)
 	}
+	retry, err := newRetryPolicy(cfg.TTS.Retry)
+	if err != nil {
+		log.Fatalf("创建重试策略失败: %v", err)
+	}
 	transport := &http.Transport{
 		Proxy:               http.ProxyFromEnvironment,
 		MaxIdleConns:        100,
@@ -80,6 +117,25 @@ func NewClient(cfg *config.Config) *Client {
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
+	voicesURL := cfg.TTS.VoicesURL
+	if voicesURL == "" {
+		voicesURL = voicesEndpoint
+	}
+	synthesisURL := cfg.TTS.SynthesisURL
+	if synthesisURL == "" {
+		synthesisURL = ttsEndpoint
+	}
+
+	websocketURL := cfg.TTS.WebSocketURL
+	if websocketURL == "" {
+		websocketURL = websocketEndpoint
+	}
+
+	tokenStrategy := cfg.TTS.TokenPoolStrategy
+	if tokenStrategy == "" {
+		tokenStrategy = config.TokenStrategyRoundRobin
+	}
+
 	client := &Client{
 		defaultVoice:  cfg.TTS.DefaultVoice,
 		defaultRate:   cfg.TTS.DefaultRate,
@@ -92,61 +148,33 @@ func NewClient(cfg *config.Config) *Client {
 		},
 		voicesCacheExpiry: time.Time{}, // 初始时缓存为空
 		localeCache:       make(map[string]cachedVoices),
-		endpointExpiry:    time.Time{}, // 初始时端点为空
+		tokens:            newTokenSlots(cfg.TTS.TokenPoolSize),
+		tokenStrategy:     tokenStrategy,
 		ssmProcessor:      ssmProcessor,
+		endpointURL:       cfg.TTS.EndpointURL,
+		voicesURL:         voicesURL,
+		synthesisURL:      synthesisURL,
+		websocketURL:      websocketURL,
+		authMode:          cfg.TTS.AuthMode,
+		region:            cfg.TTS.Region,
+		subscriptionKey:   cfg.TTS.SubscriptionKey,
+		stateFile:         cfg.TTS.StateFile,
+		stop:              make(chan struct{}),
+		regions:           newRegionSelector(cfg.TTS.Regions),
+		retry:             retry,
+		retryStats:        newRetryStats(),
+	}
+	client.loadState()
+	for _, slot := range client.tokens {
+		go client.refreshLoop(slot)
+	}
+	if client.regions != nil {
+		go client.probeLoop()
 	}
 
 	return client
 }
 
-// getEndpoint 获取或刷新认证端点
-func (c *Client) getEndpoint(ctx context.Context) (map[string]interface{}, error) {
-	c.endpointMu.RLock()
-	if !c.endpointExpiry.IsZero() && time.Now().Before(c.endpointExpiry) && c.endpoint != nil {
-		endpoint := c.endpoint
-		c.endpointMu.RUnlock()
-		return endpoint, nil
-	}
-	c.endpointMu.RUnlock()
-
-	// 获取新的端点信息
-	endpoint, err := utils.GetEndpoint()
-	if err != nil {
-		log.Printf("获取认证信息失败: %v\n", err)
-		return nil, err
-	}
-	if region, ok := endpoint["r"]; ok {
-		log.Printf("获取认证信息成功，region: %v\n", region)
-	} else {
-		log.Printf("获取认证信息成功\n")
-	}
-
-	// 从 jwt 中解析出到期时间 exp
-	jwt := endpoint["t"].(string)
-	exp := utils.GetExp(jwt)
-	if exp == 0 {
-		return nil, errors.New("jwt 中缺少 exp 字段")
-	}
-	expTime := time.Unix(exp, 0)
-	log.Println("jwt  距到期时间:", expTime.Sub(time.Now()))
-
-	// 更新缓存
-	c.endpointMu.Lock()
-	c.endpoint = endpoint
-	c.endpointExpiry = expTime.Add(-5 * time.Minute) // 提前5分钟过期
-	c.endpointMu.Unlock()
-
-	return endpoint, nil
-}
-
-// invalidateEndpoint 清空端点缓存，触发下次请求刷新
-func (c *Client) invalidateEndpoint() {
-	c.endpointMu.Lock()
-	c.endpoint = nil
-	c.endpointExpiry = time.Time{}
-	c.endpointMu.Unlock()
-}
-
 // ListVoices 获取可用的语音列表
 func (c *Client) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
 	// locale 级缓存命中（仅在有 locale 时）
@@ -169,42 +197,82 @@ func (c *Client) ListVoices(ctx context.Context, locale string) ([]models.Voice,
 		voices := c.voicesCache
 		c.voicesCacheMu.RUnlock()
 		atomic.AddUint64(&c.voicesCacheHit, 1)
-
-		// 如果指定了locale，则过滤结果
-		if locale != "" {
-			var filtered []models.Voice
-			for _, voice := range voices {
-				// 精确匹配或前缀匹配
-				if voice.Locale == locale || strings.HasPrefix(voice.Locale, locale+"-") {
-					filtered = append(filtered, voice)
-				}
-			}
-
-			c.voicesCacheMu.Lock()
-			c.localeCache[locale] = cachedVoices{
-				voices: filtered,
-				expiry: c.voicesCacheExpiry,
-			}
-			c.voicesCacheMu.Unlock()
-
-			return filtered, nil
-		}
-		return voices, nil
+		return c.filterByLocale(voices, locale), nil
 	}
 	c.voicesCacheMu.RUnlock()
 	atomic.AddUint64(&c.voicesCacheMiss, 1)
 
 	// 缓存无效，需要从API获取
 	log.Println("ListVoices, 缓存未命中，从API获取语音列表")
+	voices, err := c.fetchVoices(ctx)
+	if err != nil {
+		// 上游不可用时回退到状态文件快照或内置语音列表，稍后再重试上游
+		fallback, source, updatedAt := c.fallbackVoices()
+		if len(fallback) == 0 {
+			return nil, err
+		}
+		log.Printf("获取语音列表失败，回退到 %s 语音列表 (%d 个): %v", source, len(fallback), err)
+
+		c.voicesCacheMu.Lock()
+		c.voicesCache = fallback
+		c.voicesCacheExpiry = time.Now().Add(fallbackRetryInterval)
+		c.voicesSource = source
+		c.voicesUpdatedAt = updatedAt
+		c.localeCache = make(map[string]cachedVoices)
+		c.voicesCacheMu.Unlock()
+		return c.filterByLocale(fallback, locale), nil
+	}
+
+	// 更新缓存
+	now := time.Now()
+	c.voicesCacheMu.Lock()
+	c.voicesCache = voices
+	c.voicesCacheExpiry = now.Add(voicesCacheTTL)
+	c.voicesSource = voicesSourceLive
+	c.voicesUpdatedAt = now
+	c.snapshotVoices = voices
+	c.snapshotAt = now
+	c.localeCache = make(map[string]cachedVoices) // 语音列表更新后清空 locale 缓存
+	c.voicesCacheMu.Unlock()
+	c.saveState()
+
+	return c.filterByLocale(voices, locale), nil
+}
+
+// filterByLocale 按 locale 过滤语音列表，并写入 locale 缓存
+func (c *Client) filterByLocale(voices []models.Voice, locale string) []models.Voice {
+	if locale == "" {
+		return voices
+	}
+
+	var filtered []models.Voice
+	for _, voice := range voices {
+		// 精确匹配或前缀匹配
+		if voice.Locale == locale || strings.HasPrefix(voice.Locale, locale+"-") {
+			filtered = append(filtered, voice)
+		}
+	}
+
+	c.voicesCacheMu.Lock()
+	c.localeCache[locale] = cachedVoices{
+		voices: filtered,
+		expiry: c.voicesCacheExpiry,
+	}
+	c.voicesCacheMu.Unlock()
+
+	return filtered
+}
+
+// fetchVoices 从上游获取语音列表
+func (c *Client) fetchVoices(ctx context.Context) ([]models.Voice, error) {
 	endpoint, err := c.getEndpoint(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	url := fmt.Sprintf(voicesEndpoint, endpoint["r"])
-	if region, ok := endpoint["r"]; ok {
-		log.Printf("ListVoices, region: %v\n", region)
-	}
+	region := c.regionFor(endpoint)
+	url := fmt.Sprintf(c.voicesURL, region)
+	log.Printf("ListVoices, region: %v\n", region)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
@@ -229,7 +297,11 @@ func (c *Client) ListVoices(ctx context.Context, locale string) ([]models.Voice,
 		return nil, err
 	}
 
-	// 转换为通用模型
+	return convertVoices(msVoices), nil
+}
+
+// convertVoices 将上游语音转换为通用模型
+func convertVoices(msVoices []MicrosoftVoice) []models.Voice {
 	voices := make([]models.Voice, len(msVoices))
 	for i, v := range msVoices {
 		voices[i] = models.Voice{
@@ -244,35 +316,7 @@ func (c *Client) ListVoices(ctx context.Context, locale string) ([]models.Voice,
 			SampleRateHertz: v.SampleRateHertz, // 直接使用字符串，无需转换
 		}
 	}
-
-	// 更新缓存
-	c.voicesCacheMu.Lock()
-	c.voicesCache = voices
-	c.voicesCacheExpiry = time.Now().Add(8 * time.Hour) // 缓存 8 小时
-	c.localeCache = make(map[string]cachedVoices)       // 语音列表更新后清空 locale 缓存
-	c.voicesCacheMu.Unlock()
-
-	// 如果指定了locale，则过滤结果
-	if locale != "" {
-		var filtered []models.Voice
-		for _, voice := range voices {
-			// 精确匹配或前缀匹配
-			if voice.Locale == locale || strings.HasPrefix(voice.Locale, locale+"-") {
-				filtered = append(filtered, voice)
-			}
-		}
-
-		c.voicesCacheMu.Lock()
-		c.localeCache[locale] = cachedVoices{
-			voices: filtered,
-			expiry: c.voicesCacheExpiry,
-		}
-		c.voicesCacheMu.Unlock()
-
-		return filtered, nil
-	}
-
-	return voices, nil
+	return voices
 }
 
 // WarmupVoicesCache 预热声音列表缓存
@@ -308,19 +352,36 @@ func (c *Client) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*
 	}
 	defer resp.Body.Close()
 
-	// 读取音频数据
+	// 读取音频数据，响应头之后连接中断等读取失败视为上游不可用
 	audio, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, tts.Unavailable(fmt.Errorf("读取音频数据失败: %w", err))
 	}
 
 	return &models.TTSResponse{
 		AudioContent: audio,
-		ContentType:  contentTypeFromFormat(c.defaultFormat),
+		ContentType:  contentTypeFromFormat(c.outputFormat(req)),
 		CacheHit:     false,
 	}, nil
 }
 
+// SynthesizeStream 将文本转换为语音，直接返回上游响应体
+func (c *Client) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	resp, err := c.createTTSRequest(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.Body, contentTypeFromFormat(c.outputFormat(req)), nil
+}
+
+// outputFormat 返回请求指定的输出格式，未指定时使用默认格式
+func (c *Client) outputFormat(req models.TTSRequest) string {
+	if req.Format != "" {
+		return req.Format
+	}
+	return c.defaultFormat
+}
+
 func contentTypeFromFormat(format string) string {
 	if ct, ok := FormatContentTypeMap[format]; ok {
 		return ct
@@ -329,20 +390,96 @@ func contentTypeFromFormat(format string) string {
 }
 
 // createTTSRequest 创建并执行TTS请求，返回HTTP响应
+// 按重试策略重试 429、5xx、网络错误和认证失败，每次尝试都记录日志和统计
 func (c *Client) createTTSRequest(ctx context.Context, req models.TTSRequest) (*http.Response, error) {
-	return c.createTTSRequestWithRetry(ctx, req, false)
+	ssml, err := c.buildSSML(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, endpoint, region, err := c.sendTTSRequest(ctx, req, ssml)
+		if endpoint == nil && err != nil {
+			// 未能取得令牌，令牌获取自身已有重试
+			return nil, tts.Unavailable(err)
+		}
+		if err == nil && resp.StatusCode == http.StatusOK {
+			c.retryStats.recordAttempt(attempt, "")
+			return resp, nil
+		}
+
+		reason := classifyFailure(statusCode(resp), err)
+		var retryAfter time.Duration
+		if err == nil {
+			// 获取响应体以便调试
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			err = upstreamError(resp, body)
+		}
+		c.retryStats.recordAttempt(attempt, reason)
+		log.Printf("TTS请求第 %d/%d 次尝试失败 (%s, 区域: %s): %v", attempt, c.retry.maxAttempts, reason, region, err)
+
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if !c.retry.shouldRetry(reason, attempt) {
+			c.retryStats.recordExhausted()
+			return nil, failureError(reason, err)
+		}
+
+		// 认证失败和限流时停用当前令牌，服务端错误和网络错误时暂停该区域
+		wait := time.Duration(0)
+		switch reason {
+		case retryOnAuth:
+			c.invalidateEndpoint(endpoint)
+		case retryOnThrottle:
+			c.invalidateEndpoint(endpoint)
+			wait = c.retry.backoff(attempt, retryAfter)
+		case retryOnServer, retryOnNetwork:
+			c.markRegionFailure(region, err)
+			wait = c.retry.backoff(attempt, retryAfter)
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			c.retryStats.recordExhausted()
+			return nil, failureError(reason, fmt.Errorf("%w: %w", errRetryDeadline, err))
+		}
+		log.Printf("%v 后进行第 %d 次尝试", wait.Round(time.Millisecond), attempt+1)
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
 }
 
-// createTTSRequestWithRetry 在认证失效时尝试刷新并重试一次
-func (c *Client) createTTSRequestWithRetry(ctx context.Context, req models.TTSRequest, retried bool) (*http.Response, error) {
+// statusCode 返回响应的状态码，响应为空时返回 0
+func statusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// upstreamError 根据上游错误响应构造错误
+func upstreamError(resp *http.Response, body []byte) error {
+	requestID := resp.Header.Get("x-ms-request-id")
+	errText := string(body)
+	if requestID != "" {
+		return fmt.Errorf("TTS API错误: %s, 状态码: %d, x-ms-request-id: %s", errText, resp.StatusCode, requestID)
+	}
+	return fmt.Errorf("TTS API错误: %s, 状态码: %d", errText, resp.StatusCode)
+}
+
+// buildSSML 校验请求并填充默认值，生成SSML内容
+func (c *Client) buildSSML(req models.TTSRequest) (string, error) {
 	// 参数验证
 	if req.Text == "" {
-		return nil, errors.New("文本不能为空")
+		return "", errors.New("文本不能为空")
 	}
 
 	textLen := utf8.RuneCountInString(req.Text)
 	if textLen > c.maxTextLength {
-		return nil, fmt.Errorf("文本长度超过限制 (%d > %d)", textLen, c.maxTextLength)
+		return "", fmt.Errorf("文本长度超过限制 (%d > %d)", textLen, c.maxTextLength)
 	}
 
 	// 使用默认值填充空白参数
@@ -377,54 +514,33 @@ func (c *Client) createTTSRequestWithRetry(ctx context.Context, req models.TTSRe
 	escapedText := c.ssmProcessor.EscapeSSML(req.Text)
 
 	// 准备SSML内容
-	ssml := fmt.Sprintf(ssmlTemplate, locale, voice, style, rate, pitch, escapedText)
+	return fmt.Sprintf(ssmlTemplate, locale, voice, style, rate, pitch, escapedText), nil
+}
 
+// sendTTSRequest 发送一次合成请求，返回所用的令牌和区域以便失败时处理
+func (c *Client) sendTTSRequest(ctx context.Context, req models.TTSRequest, ssml string) (*http.Response, map[string]interface{}, string, error) {
 	// 获取端点信息
 	endpoint, err := c.getEndpoint(ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, "", err
 	}
 
 	// 准备请求
-	url := fmt.Sprintf(ttsEndpoint, endpoint["r"])
+	region := c.regionFor(endpoint)
+	url := fmt.Sprintf(c.synthesisURL, region)
 	reqBody := bytes.NewBufferString(ssml)
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, reqBody)
 	if err != nil {
-		return nil, err
+		return nil, endpoint, region, err
 	}
 
 	httpReq.Header.Set("Authorization", endpoint["t"].(string))
 	httpReq.Header.Set("Content-Type", "application/ssml+xml")
-	httpReq.Header.Set("X-Microsoft-OutputFormat", c.defaultFormat)
+	httpReq.Header.Set("X-Microsoft-OutputFormat", c.outputFormat(req))
 	httpReq.Header.Set("User-Agent", userAgent)
 
 	// 发送请求
 	resp, err := c.httpClient.Do(httpReq)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		// 获取响应体以便调试
-		body, _ := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		requestID := resp.Header.Get("x-ms-request-id")
-		errText := string(body)
-		log.Printf("TTS API错误: %s, 状态码: %d, x-ms-request-id: %s", errText, resp.StatusCode, requestID)
-
-		if !retried && (resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
-			log.Printf("TTS API返回 %d，刷新认证信息后重试一次", resp.StatusCode)
-			c.invalidateEndpoint()
-			return c.createTTSRequestWithRetry(ctx, req, true)
-		}
-
-		if requestID != "" {
-			return nil, fmt.Errorf("TTS API错误: %s, 状态码: %d, x-ms-request-id: %s", errText, resp.StatusCode, requestID)
-		}
-		return nil, fmt.Errorf("TTS API错误: %s, 状态码: %d", errText, resp.StatusCode)
-	}
-
-	return resp, nil
+	return resp, endpoint, region, err
 }
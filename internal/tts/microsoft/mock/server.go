@@ -0,0 +1,160 @@
+// Package mock 提供一个本地的 Microsoft TTS 上游替身，用于离线开发和集成测试
+package mock
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"tts/internal/audio"
+	"tts/internal/config"
+)
+
+// Region 替身返回的区域名
+const Region = "mock"
+
+// UnavailableRegion 合成请求总是返回 503 的区域，用于验证区域故障转移
+const UnavailableRegion = "unavailable"
+
+// FailText 文本中包含该标记的合成请求总是返回 500，用于验证分段失败的处理
+const FailText = "mock-fail"
+
+//go:embed voices.json
+var voicesJSON []byte
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Server 是运行中的上游替身
+type Server struct {
+	URL        string
+	listener   net.Listener
+	httpServer *http.Server
+}
+
+// Start 在 addr 上启动上游替身，addr 为空时监听本地随机端口
+func Start(addr string) (*Server, error) {
+	if addr == "" {
+		addr = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("启动上游替身失败: %w", err)
+	}
+
+	s := &Server{
+		URL:      "http://" + listener.Addr().String(),
+		listener: listener,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /apps/endpoint", s.handleEndpoint)
+	mux.HandleFunc("GET /{region}/cognitiveservices/voices/list", s.handleVoices)
+	mux.HandleFunc("POST /{region}/cognitiveservices/v1", s.handleSynthesis)
+	mux.Handle("GET /{region}/cognitiveservices/websocket/v1", websocket.Handler(s.handleWebSocket))
+	s.httpServer = &http.Server{Handler: mux}
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("上游替身退出: %v", err)
+		}
+	}()
+
+	log.Printf("上游替身已启动: %s", s.URL)
+	return s, nil
+}
+
+// Close 关闭上游替身
+func (s *Server) Close() error {
+	return s.httpServer.Close()
+}
+
+// Apply 将配置中的上游地址指向替身，并关闭状态持久化，避免替身数据写入真实的状态文件
+func (s *Server) Apply(cfg *config.TTSConfig) {
+	cfg.AuthMode = config.AuthModeEndpoint
+	cfg.StateFile = ""
+	cfg.EndpointURL = s.URL + "/apps/endpoint?api-version=1.0"
+	cfg.VoicesURL = s.URL + "/%s/cognitiveservices/voices/list"
+	cfg.SynthesisURL = s.URL + "/%s/cognitiveservices/v1"
+	cfg.WebSocketURL = "ws" + strings.TrimPrefix(s.URL, "http") + "/%s/cognitiveservices/websocket/v1"
+}
+
+// handleEndpoint 返回一个一小时后过期的伪造令牌
+func (s *Server) handleEndpoint(w http.ResponseWriter, r *http.Request) {
+	encode := base64.RawURLEncoding.EncodeToString
+	payload, _ := json.Marshal(map[string]interface{}{
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"region": Region,
+	})
+	token := encode([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + encode(payload) + "." + encode([]byte("mock"))
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"r": Region,
+		"t": token,
+	})
+}
+
+// handleVoices 返回内置的语音列表
+func (s *Server) handleVoices(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		http.Error(w, "missing authorization", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(voicesJSON)
+}
+
+// handleSynthesis 按请求格式返回与文本长度相当的静音
+func (s *Server) handleSynthesis(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") == "" {
+		http.Error(w, "missing authorization", http.StatusUnauthorized)
+		return
+	}
+	if r.PathValue("region") == UnavailableRegion {
+		http.Error(w, "region unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	formatName := r.Header.Get("X-Microsoft-OutputFormat")
+	format, err := audio.ParseFormat(formatName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ssml, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.Contains(string(ssml), FailText) {
+		http.Error(w, "synthesis failed", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := audio.Silence(format, estimateDuration(ssml))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+// estimateDuration 去掉 SSML 标签后按朗读速度估算时长
+func estimateDuration(ssml []byte) time.Duration {
+	text := html.UnescapeString(tagPattern.ReplaceAllString(string(ssml), " "))
+	return audio.EstimateDuration(text)
+}
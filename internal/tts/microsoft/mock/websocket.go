@@ -0,0 +1,172 @@
+package mock
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/websocket"
+
+	"tts/internal/audio"
+)
+
+// metadataEvent 是 audio.metadata 消息中的一个事件
+type metadataEvent struct {
+	Type string                 `json:"Type"`
+	Data map[string]interface{} `json:"Data"`
+}
+
+// handleWebSocket 按 WebSocket 合成协议返回静音音频以及按字符估算的词、句边界
+func (s *Server) handleWebSocket(conn *websocket.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	formatName := ""
+	for {
+		var message string
+		if err := websocket.Message.Receive(conn, &message); err != nil {
+			return
+		}
+
+		head, body, _ := strings.Cut(message, "\r\n\r\n")
+		switch {
+		case strings.Contains(head, "Path:speech.config"):
+			var speechConfig struct {
+				Context struct {
+					Synthesis struct {
+						Audio struct {
+							OutputFormat string `json:"outputFormat"`
+						} `json:"audio"`
+					} `json:"synthesis"`
+				} `json:"context"`
+			}
+			json.Unmarshal([]byte(body), &speechConfig)
+			formatName = speechConfig.Context.Synthesis.Audio.OutputFormat
+		case strings.Contains(head, "Path:ssml"):
+			requestID := headerValue(head, "X-RequestId")
+			s.sendWebSocketTurn(conn, requestID, formatName, body)
+			return
+		}
+	}
+}
+
+// sendWebSocketTurn 发送一次完整的合成应答
+func (s *Server) sendWebSocketTurn(conn *websocket.Conn, requestID, formatName, ssml string) {
+	format, err := audio.ParseFormat(formatName)
+	if err != nil {
+		return
+	}
+
+	text := strings.TrimSpace(html.UnescapeString(tagPattern.ReplaceAllString(ssml, " ")))
+	events, total := mockEvents(text)
+	data, err := audio.Silence(format, total)
+	if err != nil {
+		return
+	}
+
+	sendText := func(path, contentType, body string) error {
+		message := fmt.Sprintf("X-RequestId:%s\r\nContent-Type:%s\r\nPath:%s\r\n\r\n%s", requestID, contentType, path, body)
+		return websocket.Message.Send(conn, message)
+	}
+
+	if sendText("turn.start", "application/json; charset=utf-8", `{"context":{"serviceTag":"mock"}}`) != nil {
+		return
+	}
+
+	metadata, _ := json.Marshal(map[string]interface{}{"Metadata": events})
+	if sendText("audio.metadata", "application/json; charset=utf-8", string(metadata)) != nil {
+		return
+	}
+
+	header := fmt.Sprintf("X-RequestId:%s\r\nContent-Type:audio/mpeg\r\nPath:audio\r\n", requestID)
+	const chunkSize = 4096
+	for offset := 0; offset < len(data); offset += chunkSize {
+		end := offset + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		frame := make([]byte, 2, 2+len(header)+end-offset)
+		binary.BigEndian.PutUint16(frame, uint16(len(header)))
+		frame = append(frame, header...)
+		frame = append(frame, data[offset:end]...)
+		if websocket.Message.Send(conn, frame) != nil {
+			return
+		}
+	}
+
+	sendText("turn.end", "application/json; charset=utf-8", "{}")
+}
+
+// mockEvents 把文本按空白和汉字拆成词，生成词边界和一个整句边界，并返回总时长
+func mockEvents(text string) ([]metadataEvent, time.Duration) {
+	var events []metadataEvent
+	var offset time.Duration
+	var word []rune
+	var wordStart time.Duration
+
+	flush := func() {
+		if len(word) == 0 {
+			return
+		}
+		events = append(events, boundaryEvent("WordBoundary", wordStart, offset-wordStart, string(word)))
+		word = word[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.Is(unicode.Han, r):
+			flush()
+			events = append(events, boundaryEvent("WordBoundary", offset, 250*time.Millisecond, string(r)))
+			offset += 250 * time.Millisecond
+		case unicode.IsPunct(r):
+			flush()
+			offset += 70 * time.Millisecond
+		default:
+			if len(word) == 0 {
+				wordStart = offset
+			}
+			word = append(word, r)
+			offset += 70 * time.Millisecond
+		}
+	}
+	flush()
+
+	if text != "" {
+		events = append(events, boundaryEvent("SentenceBoundary", 0, offset, text))
+	}
+	if offset < 500*time.Millisecond {
+		offset = 500 * time.Millisecond
+	}
+	return events, offset
+}
+
+// boundaryEvent 构造以 100ns 为单位的边界事件
+func boundaryEvent(eventType string, offset, duration time.Duration, text string) metadataEvent {
+	return metadataEvent{
+		Type: eventType,
+		Data: map[string]interface{}{
+			"Offset":   int64(offset / 100),
+			"Duration": int64(duration / 100),
+			"text": map[string]interface{}{
+				"Text":   text,
+				"Length": len([]rune(text)),
+			},
+		},
+	}
+}
+
+// headerValue 从消息头中取出指定字段
+func headerValue(head, key string) string {
+	for _, line := range strings.Split(head, "\r\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
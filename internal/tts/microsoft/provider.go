@@ -0,0 +1,94 @@
+package microsoft
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"tts/internal/config"
+	"tts/internal/tts"
+)
+
+// NewProvider 按后端配置创建Microsoft TTS客户端，供 tts.Registry 使用
+func NewProvider(cfg *config.Config, provider config.ProviderConfig) (tts.Service, error) {
+	merged := *cfg
+	if provider.AuthMode != "" {
+		merged.TTS.AuthMode = provider.AuthMode
+	}
+	// 后端的 region / regions 整体覆盖全局配置，避免与继承的另一项同时生效
+	if provider.Region != "" || len(provider.Regions) > 0 {
+		merged.TTS.Region = provider.Region
+		merged.TTS.Regions = provider.Regions
+	}
+	if provider.DefaultVoice != "" {
+		merged.TTS.DefaultVoice = provider.DefaultVoice
+	}
+	if provider.SubscriptionKey != "" {
+		merged.TTS.SubscriptionKey = provider.SubscriptionKey
+	}
+
+	merged.TTS.StateFile = stateFileFor(cfg.TTS.StateFile, provider)
+	merged.TTS.Retry = mergeRetry(cfg.TTS.Retry, provider.Retry)
+	if _, err := newRetryPolicy(merged.TTS.Retry); err != nil {
+		return nil, err
+	}
+
+	switch merged.TTS.AuthMode {
+	case "", config.AuthModeEndpoint:
+		if merged.TTS.Region != "" {
+			if len(merged.TTS.Regions) > 0 {
+				return nil, errors.New("auth_mode 为 endpoint 时 region 和 regions 只能配置一项")
+			}
+			// 配置了 region 即固定该区域
+			merged.TTS.Regions = []string{merged.TTS.Region}
+		}
+	case config.AuthModeAzure:
+		if merged.TTS.Region == "" || merged.TTS.SubscriptionKey == "" {
+			return nil, errors.New("auth_mode 为 azure 时必须配置 region 和 subscription_key")
+		}
+		if len(merged.TTS.Regions) > 0 {
+			// 订阅密钥换取的令牌只在其所属区域有效
+			return nil, errors.New("auth_mode 为 azure 时使用 region，不支持 regions")
+		}
+	default:
+		return nil, fmt.Errorf("未知的认证方式: %s", merged.TTS.AuthMode)
+	}
+
+	switch merged.TTS.TokenPoolStrategy {
+	case "", config.TokenStrategyRoundRobin, config.TokenStrategyLRU:
+	default:
+		return nil, fmt.Errorf("未知的令牌选择策略: %s", merged.TTS.TokenPoolStrategy)
+	}
+
+	return NewClient(&merged), nil
+}
+
+// stateFileFor 返回后端的状态文件路径，多个 Microsoft 后端不能共用同一个状态文件
+func stateFileFor(base string, provider config.ProviderConfig) string {
+	if provider.StateFile != "" {
+		return provider.StateFile
+	}
+	if base == "" || provider.Name == "" || provider.Name == config.DefaultProviderName {
+		return base
+	}
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "-" + provider.Name + ext
+}
+
+// mergeRetry 用后端配置中的非零字段覆盖全局重试策略
+func mergeRetry(base, override config.RetryConfig) config.RetryConfig {
+	if override.MaxAttempts > 0 {
+		base.MaxAttempts = override.MaxAttempts
+	}
+	if override.InitialBackoff > 0 {
+		base.InitialBackoff = override.InitialBackoff
+	}
+	if override.MaxBackoff > 0 {
+		base.MaxBackoff = override.MaxBackoff
+	}
+	if override.RetryOn != nil {
+		base.RetryOn = override.RetryOn
+	}
+	return base
+}
@@ -0,0 +1,220 @@
+package microsoft
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// 区域延迟探测间隔和单次探测超时
+	regionProbeInterval = time.Minute
+	regionProbeTimeout  = 5 * time.Second
+	// 区域请求出错后暂停使用的时间
+	regionCooldown = 30 * time.Second
+)
+
+// regionState 是一个候选区域的探测结果和故障状态
+type regionState struct {
+	name      string
+	latency   time.Duration
+	probedAt  time.Time
+	probeErr  string
+	downUntil time.Time
+	failures  uint64
+}
+
+// healthy 判断区域是否可用，调用方需持有锁
+func (r *regionState) healthy(now time.Time) bool {
+	return r.probeErr == "" && !now.Before(r.downUntil)
+}
+
+// regionSelector 在候选区域中选择延迟最低的健康区域
+type regionSelector struct {
+	mu      sync.RWMutex
+	regions []*regionState
+	now     func() time.Time // 时钟，测试中可替换
+}
+
+// newRegionSelector 创建区域选择器，未配置候选区域时返回 nil，沿用令牌返回的区域
+func newRegionSelector(names []string) *regionSelector {
+	if len(names) == 0 {
+		return nil
+	}
+	s := &regionSelector{now: time.Now}
+	for _, name := range names {
+		s.regions = append(s.regions, &regionState{name: name})
+	}
+	return s
+}
+
+// pick 返回延迟最低的健康区域；尚未探测时按配置顺序，全部不可用时返回最早恢复的区域
+func (s *regionSelector) pick() string {
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var best, fallback *regionState
+	for _, r := range s.regions {
+		if r.healthy(now) {
+			if best == nil || r.latency < best.latency {
+				best = r
+			}
+			continue
+		}
+		if fallback == nil || r.downUntil.Before(fallback.downUntil) {
+			fallback = r
+		}
+	}
+	if best != nil {
+		return best.name
+	}
+	return fallback.name
+}
+
+// markFailure 区域请求出错后暂停使用一段时间，请求改走其他区域
+func (s *regionSelector) markFailure(name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.regions {
+		if r.name == name {
+			r.failures++
+			r.downUntil = s.now().Add(regionCooldown)
+			log.Printf("区域 %s 请求失败，暂停使用 %v: %v", name, regionCooldown, err)
+			return
+		}
+	}
+}
+
+// status 返回各区域的延迟和健康状态
+func (s *regionSelector) status() []map[string]interface{} {
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	regions := make([]map[string]interface{}, 0, len(s.regions))
+	for _, r := range s.regions {
+		status := map[string]interface{}{
+			"name":     r.name,
+			"healthy":  r.healthy(now),
+			"failures": r.failures,
+		}
+		if !r.probedAt.IsZero() {
+			status["latency_ms"] = r.latency.Milliseconds()
+			status["probed_at"] = r.probedAt.Format(time.RFC3339)
+		}
+		if r.probeErr != "" {
+			status["error"] = r.probeErr
+		}
+		regions = append(regions, status)
+	}
+	return regions
+}
+
+// regionFor 返回请求应发往的区域
+func (c *Client) regionFor(endpoint map[string]interface{}) string {
+	if c.regions == nil {
+		region, _ := endpoint["r"].(string)
+		return region
+	}
+	return c.regions.pick()
+}
+
+// markRegionFailure 记录区域故障，未配置候选区域时忽略
+func (c *Client) markRegionFailure(region string, err error) bool {
+	if c.regions == nil || len(c.regions.regions) < 2 {
+		return false
+	}
+	c.regions.markFailure(region, err)
+	return true
+}
+
+// probeLoop 定期探测各候选区域的延迟
+func (c *Client) probeLoop() {
+	for {
+		c.probeRegions()
+
+		timer := time.NewTimer(regionProbeInterval)
+		select {
+		case <-c.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// probeRegions 并发探测所有候选区域的合成地址，以收到响应头的耗时作为延迟；
+// 探测只反映区域的网络可达性，合成失败由 markRegionFailure 处理
+func (c *Client) probeRegions() {
+	c.regions.probe(c.probeRegion)
+}
+
+// probe 用 probe 函数并发探测所有候选区域，记录延迟和探测错误
+func (s *regionSelector) probe(probe func(region string) (time.Duration, error)) {
+	var wg sync.WaitGroup
+	for _, r := range s.regions {
+		wg.Add(1)
+		go func(r *regionState) {
+			defer wg.Done()
+			latency, err := probe(r.name)
+
+			s.mu.Lock()
+			r.latency = latency
+			r.probedAt = s.now()
+			r.probeErr = ""
+			if err != nil {
+				r.probeErr = err.Error()
+			}
+			s.mu.Unlock()
+		}(r)
+	}
+	wg.Wait()
+}
+
+// probeRegion 以不带令牌的 GET 请求区域的合成地址，只检查 DNS、连接和 TLS 握手是否正常：
+// 合成接口只接受带认证的 POST，4xx 响应同样说明区域可达，但不代表该区域能用当前令牌合成
+func (c *Client) probeRegion(region string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), regionProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(c.synthesisURL, region), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return latency, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return latency, fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+	return latency, nil
+}
+
+// regionHealth 返回当前选中的区域及各候选区域的状态
+func (c *Client) regionHealth() map[string]interface{} {
+	if c.regions == nil {
+		c.endpointMu.RLock()
+		defer c.endpointMu.RUnlock()
+		for _, slot := range c.tokens {
+			if slot.endpoint != nil {
+				return map[string]interface{}{"chosen": slot.endpoint["r"], "source": "token"}
+			}
+		}
+		return map[string]interface{}{"source": "token"}
+	}
+	return map[string]interface{}{
+		"chosen":     c.regions.pick(),
+		"source":     "config",
+		"candidates": c.regions.status(),
+	}
+}
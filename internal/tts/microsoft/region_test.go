@@ -0,0 +1,134 @@
+package microsoft
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock 是可手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// newTestRegionSelector 创建使用 fakeClock 的区域选择器，并按 latencies 完成一次探测；
+// latencies 中没有的区域不探测，值为负数的区域探测失败
+func newTestRegionSelector(names []string, latencies map[string]time.Duration) (*regionSelector, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := newRegionSelector(names)
+	s.now = clock.Now
+	if latencies != nil {
+		s.probe(func(region string) (time.Duration, error) {
+			latency, ok := latencies[region]
+			if ok && latency < 0 {
+				return 0, errors.New("connection refused")
+			}
+			return latency, nil
+		})
+	}
+	return s, clock
+}
+
+func TestRegionPick(t *testing.T) {
+	regions := []string{"eastus", "westus", "southeastasia"}
+	probed := map[string]time.Duration{
+		"eastus":        50 * time.Millisecond,
+		"westus":        10 * time.Millisecond,
+		"southeastasia": 30 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name      string
+		latencies map[string]time.Duration
+		failures  []string // 依次标记失败，每次间隔 1 秒
+		advance   time.Duration
+		want      string
+	}{
+		{"not probed uses config order", nil, nil, 0, "eastus"},
+		{"lowest latency", probed, nil, 0, "westus"},
+		{
+			name:      "probe error skipped",
+			latencies: map[string]time.Duration{"eastus": 50 * time.Millisecond, "westus": -1, "southeastasia": 30 * time.Millisecond},
+			want:      "southeastasia",
+		},
+		{"failed region cools down", probed, []string{"westus"}, 0, "southeastasia"},
+		{"cooldown not yet expired", probed, []string{"westus"}, regionCooldown - time.Second, "southeastasia"},
+		{"cooldown expired", probed, []string{"westus"}, regionCooldown, "westus"},
+		{"next lowest after two failures", probed, []string{"westus", "southeastasia"}, 0, "eastus"},
+		{"all down picks earliest recovery", probed, []string{"southeastasia", "westus", "eastus"}, 0, "southeastasia"},
+		{
+			name:      "all probes failed",
+			latencies: map[string]time.Duration{"eastus": -1, "westus": -1, "southeastasia": -1},
+			want:      "eastus",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clock := newTestRegionSelector(regions, tt.latencies)
+			for i, name := range tt.failures {
+				if i > 0 {
+					clock.advance(time.Second)
+				}
+				s.markFailure(name, errors.New("503"))
+			}
+			// advance 从最后一次失败时算起
+			clock.advance(tt.advance)
+
+			if got := s.pick(); got != tt.want {
+				t.Errorf("pick() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegionStatus(t *testing.T) {
+	s, clock := newTestRegionSelector([]string{"eastus", "westus"}, map[string]time.Duration{
+		"eastus": 20 * time.Millisecond,
+		"westus": -1,
+	})
+	s.markFailure("eastus", errors.New("503"))
+	s.markFailure("eastus", errors.New("503"))
+
+	status := s.status()
+	if status[0]["healthy"] != false || status[0]["failures"] != uint64(2) || status[0]["latency_ms"] != int64(20) {
+		t.Errorf("eastus status = %v", status[0])
+	}
+	if status[1]["healthy"] != false || status[1]["error"] != "connection refused" {
+		t.Errorf("westus status = %v", status[1])
+	}
+
+	clock.advance(regionCooldown)
+	if s.status()[0]["healthy"] != true {
+		t.Error("eastus is still down after the cooldown")
+	}
+}
+
+func TestMarkRegionFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		regions []string
+		want    bool
+	}{
+		{"no candidates", nil, false},
+		{"single candidate", []string{"eastus"}, false},
+		{"several candidates", []string{"eastus", "westus"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{regions: newRegionSelector(tt.regions)}
+			if got := c.markRegionFailure("eastus", errors.New("503")); got != tt.want {
+				t.Fatalf("markRegionFailure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
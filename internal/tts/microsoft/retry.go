@@ -0,0 +1,215 @@
+package microsoft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"tts/internal/config"
+	"tts/internal/tts"
+)
+
+// 失败原因，即可配置重试的状态类别
+const (
+	retryOnAuth      = "auth"    // 400/401/403，换令牌后重试
+	retryOnThrottle  = "429"     // 限流，停用当前令牌并按 Retry-After 或退避等待
+	retryOnServer    = "5xx"     // 上游服务错误
+	retryOnNetwork   = "network" // 连接失败、超时等
+	retryOnOtherCode = "other"   // 其余状态码，不可配置重试
+)
+
+// 默认重试策略
+var defaultRetryOn = []string{retryOnAuth, retryOnThrottle, retryOnServer, retryOnNetwork}
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 200 * time.Millisecond
+	defaultRetryMaxBackoff     = 5 * time.Second
+)
+
+// retryPolicy 是合成请求的重试策略
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	retryOn        map[string]bool
+}
+
+// newRetryPolicy 按配置创建重试策略，未配置的字段使用默认值
+func newRetryPolicy(cfg config.RetryConfig) (*retryPolicy, error) {
+	p := &retryPolicy{
+		maxAttempts:    cfg.MaxAttempts,
+		initialBackoff: time.Duration(cfg.InitialBackoff) * time.Millisecond,
+		maxBackoff:     time.Duration(cfg.MaxBackoff) * time.Millisecond,
+		retryOn:        make(map[string]bool),
+	}
+	if p.maxAttempts <= 0 {
+		p.maxAttempts = defaultRetryMaxAttempts
+	}
+	if p.initialBackoff <= 0 {
+		p.initialBackoff = defaultRetryInitialBackoff
+	}
+	if p.maxBackoff <= 0 {
+		p.maxBackoff = defaultRetryMaxBackoff
+	}
+	if p.maxBackoff < p.initialBackoff {
+		p.maxBackoff = p.initialBackoff
+	}
+
+	retryOn := cfg.RetryOn
+	if retryOn == nil {
+		retryOn = defaultRetryOn
+	}
+	for _, class := range retryOn {
+		switch class {
+		case retryOnAuth, retryOnThrottle, retryOnServer, retryOnNetwork:
+			p.retryOn[class] = true
+		default:
+			return nil, fmt.Errorf("未知的重试类别: %s (可选 auth、429、5xx、network)", class)
+		}
+	}
+	return p, nil
+}
+
+// shouldRetry 判断第 attempt 次尝试因 reason 失败后是否重试；
+// 认证失败（上游对过期令牌返回 400/401/403）换令牌后至少重试一次，不受最多尝试次数限制
+func (p *retryPolicy) shouldRetry(reason string, attempt int) bool {
+	if !p.retryOn[reason] {
+		return false
+	}
+	if reason == retryOnAuth && attempt == 1 {
+		return true
+	}
+	return attempt < p.maxAttempts
+}
+
+// backoff 返回第 attempt 次失败后的等待时间：指数退避加随机抖动，上游给出 Retry-After 时不短于它
+func (p *retryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	wait := p.maxBackoff
+	if attempt < 31 {
+		wait = min(p.initialBackoff<<(attempt-1), p.maxBackoff)
+	}
+	// 在 [wait/2, wait) 之间随机，避免大量分段同时重试
+	wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	return max(wait, retryAfter)
+}
+
+// classifyFailure 判断失败原因
+func classifyFailure(statusCode int, err error) string {
+	if err != nil {
+		return retryOnNetwork
+	}
+	switch {
+	case statusCode == http.StatusBadRequest || statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return retryOnAuth
+	case statusCode == http.StatusTooManyRequests:
+		return retryOnThrottle
+	case statusCode >= http.StatusInternalServerError:
+		return retryOnServer
+	default:
+		return retryOnOtherCode
+	}
+}
+
+// failureError 把限流、服务端错误和网络错误标记为后端不可用，供故障转移链计入熔断
+func failureError(reason string, err error) error {
+	switch reason {
+	case retryOnThrottle, retryOnServer, retryOnNetwork:
+		return tts.Unavailable(err)
+	}
+	return err
+}
+
+// parseRetryAfter 解析 Retry-After 响应头，支持秒数和 HTTP 日期
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// sleepContext 等待指定时间，调用方取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// errRetryDeadline 剩余时间不足以等待下一次重试
+var errRetryDeadline = errors.New("剩余时间不足以等待下一次重试")
+
+// retryStats 记录每次尝试的结果
+type retryStats struct {
+	mu        sync.Mutex
+	requests  uint64            // 合成请求数
+	attempts  uint64            // 尝试次数，含首次
+	retries   uint64            // 重试次数
+	recovered uint64            // 重试后成功的请求数
+	exhausted uint64            // 用尽重试次数或不可重试而失败的请求数
+	failures  map[string]uint64 // 各失败原因的次数
+}
+
+func newRetryStats() *retryStats {
+	return &retryStats{failures: make(map[string]uint64)}
+}
+
+// recordAttempt 记录一次尝试，reason 为空表示成功
+func (s *retryStats) recordAttempt(attempt int, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	if attempt == 1 {
+		s.requests++
+	} else {
+		s.retries++
+	}
+	if reason != "" {
+		s.failures[reason]++
+	} else if attempt > 1 {
+		s.recovered++
+	}
+}
+
+// recordExhausted 记录最终失败的请求
+func (s *retryStats) recordExhausted() {
+	s.mu.Lock()
+	s.exhausted++
+	s.mu.Unlock()
+}
+
+// snapshot 返回重试统计
+func (s *retryStats) snapshot() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	failures := make(map[string]uint64, len(s.failures))
+	for reason, count := range s.failures {
+		failures[reason] = count
+	}
+	return map[string]interface{}{
+		"requests":  s.requests,
+		"attempts":  s.attempts,
+		"retries":   s.retries,
+		"recovered": s.recovered,
+		"exhausted": s.exhausted,
+		"failures":  failures,
+	}
+}
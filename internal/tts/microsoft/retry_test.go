@@ -0,0 +1,164 @@
+package microsoft
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"tts/internal/config"
+	"tts/internal/tts"
+)
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		err         error
+		want        string
+		retried     bool
+		unavailable bool
+	}{
+		{"network error", 0, errors.New("connection reset"), retryOnNetwork, true, true},
+		{"bad request", http.StatusBadRequest, nil, retryOnAuth, true, false},
+		{"unauthorized", http.StatusUnauthorized, nil, retryOnAuth, true, false},
+		{"forbidden", http.StatusForbidden, nil, retryOnAuth, true, false},
+		{"not found", http.StatusNotFound, nil, retryOnOtherCode, false, false},
+		{"throttled", http.StatusTooManyRequests, nil, retryOnThrottle, true, true},
+		{"server error", http.StatusInternalServerError, nil, retryOnServer, true, true},
+		{"unavailable", http.StatusServiceUnavailable, nil, retryOnServer, true, true},
+	}
+
+	policy, err := newRetryPolicy(config.RetryConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := classifyFailure(tt.status, tt.err)
+			if reason != tt.want {
+				t.Fatalf("classifyFailure(%d, %v) = %q, want %q", tt.status, tt.err, reason, tt.want)
+			}
+			if got := policy.retryOn[reason]; got != tt.retried {
+				t.Errorf("retried by default = %v, want %v", got, tt.retried)
+			}
+			if got := tts.IsUnavailable(failureError(reason, errors.New("failed"))); got != tt.unavailable {
+				t.Errorf("unavailable = %v, want %v", got, tt.unavailable)
+			}
+		})
+	}
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.RetryConfig
+		wantErr bool
+		want    retryPolicy
+	}{
+		{
+			name: "defaults",
+			want: retryPolicy{maxAttempts: defaultRetryMaxAttempts, initialBackoff: defaultRetryInitialBackoff, maxBackoff: defaultRetryMaxBackoff},
+		},
+		{
+			name: "max backoff raised to initial",
+			cfg:  config.RetryConfig{MaxAttempts: 5, InitialBackoff: 1000, MaxBackoff: 500},
+			want: retryPolicy{maxAttempts: 5, initialBackoff: time.Second, maxBackoff: time.Second},
+		},
+		{
+			name:    "unknown class",
+			cfg:     config.RetryConfig{RetryOn: []string{"400"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newRetryPolicy(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if p.maxAttempts != tt.want.maxAttempts || p.initialBackoff != tt.want.initialBackoff || p.maxBackoff != tt.want.maxBackoff {
+				t.Fatalf("policy = %+v, want %+v", *p, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.RetryConfig
+		reason  string
+		attempt int
+		want    bool
+	}{
+		{"server error within budget", config.RetryConfig{MaxAttempts: 3}, retryOnServer, 2, true},
+		{"server error exhausted", config.RetryConfig{MaxAttempts: 3}, retryOnServer, 3, false},
+		{"single attempt", config.RetryConfig{MaxAttempts: 1}, retryOnNetwork, 1, false},
+		{"auth refreshes once with a single attempt", config.RetryConfig{MaxAttempts: 1}, retryOnAuth, 1, true},
+		{"auth refreshes only once", config.RetryConfig{MaxAttempts: 1}, retryOnAuth, 2, false},
+		{"auth within budget", config.RetryConfig{MaxAttempts: 3}, retryOnAuth, 2, true},
+		{"auth disabled", config.RetryConfig{RetryOn: []string{"5xx"}}, retryOnAuth, 1, false},
+		{"other status", config.RetryConfig{}, retryOnOtherCode, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newRetryPolicy(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := p.shouldRetry(tt.reason, tt.attempt); got != tt.want {
+				t.Fatalf("shouldRetry(%s, %d) = %v, want %v", tt.reason, tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := &retryPolicy{initialBackoff: 100 * time.Millisecond, maxBackoff: time.Second}
+
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{1, 0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{3, 0, 200 * time.Millisecond, 400 * time.Millisecond},
+		{10, 0, 500 * time.Millisecond, time.Second},
+		{40, 0, 500 * time.Millisecond, time.Second},
+		{1, 3 * time.Second, 3 * time.Second, 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := p.backoff(tt.attempt, tt.retryAfter); got < tt.min || got > tt.max {
+				t.Fatalf("backoff(%d, %v) = %v, want [%v, %v]", tt.attempt, tt.retryAfter, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"2", 2 * time.Second},
+		{"0", 0},
+		{"-1", 0},
+		{"soon", 0},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
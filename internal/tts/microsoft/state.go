@@ -0,0 +1,176 @@
+package microsoft
+
+import (
+	_ "embed"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tts/internal/models"
+)
+
+// 语音列表来源
+const (
+	voicesSourceLive     = "live"     // 本次运行中从上游获取
+	voicesSourceSnapshot = "snapshot" // 来自状态文件
+	voicesSourceEmbedded = "embedded" // 内置语音列表
+)
+
+// 上游不可用时，回退结果的缓存时间，过期后再次尝试上游
+const fallbackRetryInterval = time.Minute
+
+//go:embed catalogue.json
+var embeddedCatalogue []byte
+
+// clientState 是写入状态文件的内容
+type clientState struct {
+	Voices          []models.Voice `json:"voices,omitempty"`
+	VoicesUpdatedAt time.Time      `json:"voices_updated_at,omitempty"`
+	Tokens          []stateToken   `json:"tokens,omitempty"`
+}
+
+// stateToken 是令牌池中一个令牌的持久化形式
+type stateToken struct {
+	Endpoint map[string]interface{} `json:"endpoint"`
+	Expiry   time.Time              `json:"expiry"`
+}
+
+// loadState 从状态文件恢复语音列表和端点令牌，未过期的部分直接作为缓存使用
+func (c *Client) loadState() {
+	if c.stateFile == "" {
+		return
+	}
+	data, err := os.ReadFile(c.stateFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("读取状态文件失败: %v", err)
+		}
+		return
+	}
+	var state clientState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("解析状态文件失败: %v", err)
+		return
+	}
+
+	if len(state.Voices) > 0 {
+		c.snapshotVoices = state.Voices
+		c.snapshotAt = state.VoicesUpdatedAt
+		if expiry := state.VoicesUpdatedAt.Add(voicesCacheTTL); time.Now().Before(expiry) {
+			c.voicesCache = state.Voices
+			c.voicesCacheExpiry = expiry
+			c.voicesSource = voicesSourceSnapshot
+			c.voicesUpdatedAt = state.VoicesUpdatedAt
+		}
+		log.Printf("已从状态文件恢复 %d 个语音，快照时间: %s", len(state.Voices), state.VoicesUpdatedAt.Format(time.RFC3339))
+	}
+	restored := 0
+	for _, token := range state.Tokens {
+		if restored >= len(c.tokens) {
+			break
+		}
+		if token.Endpoint == nil || !time.Now().Before(token.Expiry) {
+			continue
+		}
+		c.tokens[restored].endpoint = token.Endpoint
+		c.tokens[restored].expiry = token.Expiry
+		restored++
+	}
+	if restored > 0 {
+		log.Printf("已从状态文件恢复 %d 个认证令牌", restored)
+	}
+}
+
+// saveState 将当前语音列表和端点令牌写入状态文件
+func (c *Client) saveState() {
+	if c.stateFile == "" {
+		return
+	}
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	var state clientState
+	c.voicesCacheMu.RLock()
+	if c.voicesSource == voicesSourceLive || c.voicesSource == voicesSourceSnapshot {
+		state.Voices = c.voicesCache
+		state.VoicesUpdatedAt = c.voicesUpdatedAt
+	} else {
+		state.Voices = c.snapshotVoices
+		state.VoicesUpdatedAt = c.snapshotAt
+	}
+	c.voicesCacheMu.RUnlock()
+	c.endpointMu.RLock()
+	for _, slot := range c.tokens {
+		if slot.endpoint != nil {
+			state.Tokens = append(state.Tokens, stateToken{Endpoint: slot.endpoint, Expiry: slot.expiry})
+		}
+	}
+	c.endpointMu.RUnlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		log.Printf("序列化状态失败: %v", err)
+		return
+	}
+	if err := writeFileAtomic(c.stateFile, data); err != nil {
+		log.Printf("写入状态文件失败: %v", err)
+	}
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免进程中断时留下半个文件
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// fallbackVoices 上游获取失败时依次使用状态文件快照和内置语音列表
+func (c *Client) fallbackVoices() ([]models.Voice, string, time.Time) {
+	c.voicesCacheMu.RLock()
+	voices, updatedAt := c.snapshotVoices, c.snapshotAt
+	c.voicesCacheMu.RUnlock()
+	if len(voices) > 0 {
+		return voices, voicesSourceSnapshot, updatedAt
+	}
+
+	var msVoices []MicrosoftVoice
+	if err := json.Unmarshal(embeddedCatalogue, &msVoices); err != nil {
+		log.Printf("解析内置语音列表失败: %v", err)
+		return nil, "", time.Time{}
+	}
+	return convertVoices(msVoices), voicesSourceEmbedded, time.Time{}
+}
+
+// CatalogueStatus 报告语音列表的来源和快照时间
+func (c *Client) CatalogueStatus() map[string]interface{} {
+	c.voicesCacheMu.RLock()
+	source, updatedAt, count := c.voicesSource, c.voicesUpdatedAt, len(c.voicesCache)
+	c.voicesCacheMu.RUnlock()
+
+	status := map[string]interface{}{
+		"source": source,
+		"voices": count,
+	}
+	if !updatedAt.IsZero() {
+		status["updatedAt"] = updatedAt.Format(time.RFC3339)
+		status["ageSeconds"] = int64(time.Since(updatedAt).Seconds())
+	}
+	return status
+}
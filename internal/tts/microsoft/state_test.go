@@ -0,0 +1,136 @@
+package microsoft
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tts/internal/models"
+)
+
+// newStateClient 创建使用 stateFile 的客户端，令牌池大小为 size
+func newStateClient(stateFile string, size int) *Client {
+	return &Client{stateFile: stateFile, tokens: newTokenSlots(size)}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state", "microsoft.json")
+	updatedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	expiry := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	saved := newStateClient(stateFile, 2)
+	saved.voicesCache = []models.Voice{{Name: "zh-CN-XiaoxiaoNeural"}, {Name: "en-US-JennyNeural"}}
+	saved.voicesSource = voicesSourceLive
+	saved.voicesUpdatedAt = updatedAt
+	saved.tokens[1].endpoint = map[string]interface{}{"r": "eastus", "t": "Bearer token"}
+	saved.tokens[1].expiry = expiry
+	saved.saveState()
+
+	// 只留下状态文件，不留下临时文件
+	entries, err := os.ReadDir(filepath.Dir(stateFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "microsoft.json" {
+		t.Fatalf("state directory = %v, want only microsoft.json", entries)
+	}
+
+	loaded := newStateClient(stateFile, 2)
+	loaded.loadState()
+	if len(loaded.voicesCache) != 2 || loaded.voicesCache[0].Name != "zh-CN-XiaoxiaoNeural" {
+		t.Fatalf("voices = %v", loaded.voicesCache)
+	}
+	if loaded.voicesSource != voicesSourceSnapshot {
+		t.Errorf("source = %s, want %s", loaded.voicesSource, voicesSourceSnapshot)
+	}
+	if !loaded.voicesUpdatedAt.Equal(updatedAt) {
+		t.Errorf("updated at = %v, want %v", loaded.voicesUpdatedAt, updatedAt)
+	}
+	if !loaded.tokens[0].valid(time.Now()) || loaded.tokens[0].endpoint["t"] != "Bearer token" {
+		t.Errorf("token was not restored: %+v", loaded.tokens[0])
+	}
+	if !loaded.tokens[0].expiry.Equal(expiry) {
+		t.Errorf("expiry = %v, want %v", loaded.tokens[0].expiry, expiry)
+	}
+	if loaded.tokens[1].endpoint != nil {
+		t.Error("token restored into a second slot")
+	}
+}
+
+func TestStateFallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string // 为空时不创建状态文件
+	}{
+		{"missing file", ""},
+		{"corrupt file", `{"voices": [`},
+		{"empty state", `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stateFile := filepath.Join(t.TempDir(), "state.json")
+			if tt.content != "" {
+				if err := os.WriteFile(stateFile, []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			c := newStateClient(stateFile, 1)
+			c.loadState()
+			if c.voicesCache != nil || c.tokens[0].endpoint != nil {
+				t.Fatal("state restored from an unusable file")
+			}
+
+			// 没有快照时回退到内置语音列表
+			voices, source, _ := c.fallbackVoices()
+			if source != voicesSourceEmbedded {
+				t.Errorf("source = %s, want %s", source, voicesSourceEmbedded)
+			}
+			if len(voices) == 0 {
+				t.Error("embedded catalogue is empty")
+			}
+		})
+	}
+}
+
+func TestStateExpired(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	updatedAt := time.Now().Add(-voicesCacheTTL - time.Minute)
+
+	saved := newStateClient(stateFile, 3)
+	saved.voicesCache = []models.Voice{{Name: "zh-CN-XiaoxiaoNeural"}}
+	saved.voicesSource = voicesSourceLive
+	saved.voicesUpdatedAt = updatedAt
+	saved.tokens[0].endpoint = map[string]interface{}{"t": "Bearer expired"}
+	saved.tokens[0].expiry = time.Now().Add(-time.Minute)
+	saved.tokens[1].endpoint = map[string]interface{}{"t": "Bearer valid"}
+	saved.tokens[1].expiry = time.Now().Add(time.Hour)
+	saved.saveState()
+
+	loaded := newStateClient(stateFile, 3)
+	loaded.loadState()
+
+	// 过期的令牌不恢复
+	if loaded.tokens[0].endpoint["t"] != "Bearer valid" {
+		t.Errorf("slot 0 = %v, want the valid token", loaded.tokens[0].endpoint)
+	}
+	for _, slot := range loaded.tokens[1:] {
+		if slot.endpoint != nil {
+			t.Errorf("slot %d = %v, want empty", slot.index, slot.endpoint)
+		}
+	}
+
+	// 过期的语音列表不作为缓存，只在上游不可用时作为快照回退
+	if loaded.voicesCache != nil {
+		t.Error("expired voices used as cache")
+	}
+	voices, source, at := loaded.fallbackVoices()
+	if source != voicesSourceSnapshot || len(voices) != 1 {
+		t.Errorf("fallback = %d voices from %s, want 1 from %s", len(voices), source, voicesSourceSnapshot)
+	}
+	if !at.Equal(updatedAt.Truncate(0)) {
+		t.Errorf("snapshot time = %v, want %v", at, updatedAt)
+	}
+}
@@ -0,0 +1,284 @@
+package microsoft
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"tts/internal/config"
+	"tts/internal/utils"
+)
+
+const (
+	// 令牌在 jwt exp 之前多久视为过期
+	endpointExpiryMargin = 5 * time.Minute
+	// 后台刷新失败后的退避范围
+	refreshBackoffMin = time.Second
+	refreshBackoffMax = time.Minute
+	// 单次令牌获取的超时时间
+	refreshTimeout = 30 * time.Second
+)
+
+// tokenSlot 是令牌池中的一个位置，每个位置独立获取令牌（各自的 X-UserId），有各自的到期时间
+type tokenSlot struct {
+	index      int
+	endpoint   map[string]interface{}
+	expiry     time.Time
+	lastUsed   time.Time
+	refreshing *endpointCall // 该位置正在进行的令牌获取，同一时间只有一个
+	retire     chan struct{} // 令牌被停用时通知后台刷新立即补充
+}
+
+// valid 判断令牌是否可用，调用方需持有 endpointMu
+func (s *tokenSlot) valid(now time.Time) bool {
+	return s.endpoint != nil && !s.expiry.IsZero() && now.Before(s.expiry)
+}
+
+// endpointCall 是一次正在进行的令牌获取，等待者共享其结果
+type endpointCall struct {
+	done     chan struct{}
+	endpoint map[string]interface{}
+	err      error
+}
+
+// newTokenSlots 创建令牌池
+func newTokenSlots(size int) []*tokenSlot {
+	if size <= 0 {
+		size = 1
+	}
+	slots := make([]*tokenSlot, size)
+	for i := range slots {
+		slots[i] = &tokenSlot{index: i, retire: make(chan struct{}, 1)}
+	}
+	return slots
+}
+
+// getEndpoint 按轮询或最久未使用策略从令牌池中选出一个可用令牌；没有可用令牌时等待单飞的令牌获取
+func (c *Client) getEndpoint(ctx context.Context) (map[string]interface{}, error) {
+	now := time.Now()
+	c.endpointMu.Lock()
+	slot := c.pickSlot(now)
+	if slot.valid(now) {
+		slot.lastUsed = now
+		endpoint := slot.endpoint
+		c.endpointMu.Unlock()
+		return endpoint, nil
+	}
+	c.endpointMu.Unlock()
+
+	return c.refreshSlot(ctx, slot)
+}
+
+// pickSlot 选择令牌池中的位置，优先返回可用的令牌，调用方需持有 endpointMu
+func (c *Client) pickSlot(now time.Time) *tokenSlot {
+	if c.tokenStrategy == config.TokenStrategyLRU {
+		var picked *tokenSlot
+		for _, slot := range c.tokens {
+			if slot.valid(now) && (picked == nil || slot.lastUsed.Before(picked.lastUsed)) {
+				picked = slot
+			}
+		}
+		if picked != nil {
+			return picked
+		}
+		return c.tokens[0]
+	}
+
+	start := int(atomic.AddUint64(&c.tokenNext, 1) % uint64(len(c.tokens)))
+	for i := range c.tokens {
+		slot := c.tokens[(start+i)%len(c.tokens)]
+		if slot.valid(now) {
+			return slot
+		}
+	}
+	return c.tokens[start]
+}
+
+// refreshSlot 为指定位置获取新令牌；已有获取在进行时只等待其结果，不会重复请求上游
+func (c *Client) refreshSlot(ctx context.Context, slot *tokenSlot) (map[string]interface{}, error) {
+	c.endpointMu.Lock()
+	call := slot.refreshing
+	if call == nil {
+		call = &endpointCall{done: make(chan struct{})}
+		slot.refreshing = call
+		go c.fetchEndpoint(slot, call)
+	}
+	c.endpointMu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.endpoint, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetchEndpoint 请求上游获取令牌并写入令牌池，不随任何一个等待者取消
+func (c *Client) fetchEndpoint(slot *tokenSlot, call *endpointCall) {
+	defer func() {
+		c.endpointMu.Lock()
+		slot.refreshing = nil
+		c.endpointMu.Unlock()
+		close(call.done)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	var endpoint map[string]interface{}
+	var err error
+	if c.authMode == config.AuthModeAzure {
+		endpoint, err = c.issueAzureToken(ctx)
+	} else {
+		endpoint, err = utils.GetEndpoint(c.endpointURL)
+	}
+	if err != nil {
+		log.Printf("获取认证信息失败 (令牌 %d): %v\n", slot.index, err)
+		call.err = err
+		return
+	}
+	if region, ok := endpoint["r"]; ok {
+		log.Printf("获取认证信息成功 (令牌 %d)，region: %v\n", slot.index, region)
+	} else {
+		log.Printf("获取认证信息成功 (令牌 %d)\n", slot.index)
+	}
+
+	// 从 jwt 中解析出到期时间 exp
+	jwt := strings.TrimPrefix(endpoint["t"].(string), "Bearer ")
+	exp := utils.GetExp(jwt)
+	if exp == 0 {
+		call.err = errors.New("jwt 中缺少 exp 字段")
+		return
+	}
+	expTime := time.Unix(exp, 0)
+	log.Println("jwt  距到期时间:", expTime.Sub(time.Now()))
+
+	// 更新令牌池
+	c.endpointMu.Lock()
+	slot.endpoint = endpoint
+	slot.expiry = expTime.Add(-endpointExpiryMargin)
+	c.endpointMu.Unlock()
+	c.saveState()
+
+	call.endpoint = endpoint
+}
+
+// invalidateEndpoint 上游拒绝或限流某个令牌时将其停用，并通知后台立即补充新令牌
+// 只停用仍在池中的那个令牌，避免并发请求反复作废刚刷新的令牌
+func (c *Client) invalidateEndpoint(stale map[string]interface{}) {
+	if stale == nil {
+		return
+	}
+
+	c.endpointMu.Lock()
+	defer c.endpointMu.Unlock()
+	for _, slot := range c.tokens {
+		if slot.endpoint == nil || slot.endpoint["t"] != stale["t"] {
+			continue
+		}
+		slot.endpoint = nil
+		slot.expiry = time.Time{}
+		atomic.AddUint64(&c.tokensRetired, 1)
+		log.Printf("停用令牌 %d，后台获取新令牌替换", slot.index)
+		select {
+		case slot.retire <- struct{}{}:
+		default:
+		}
+		return
+	}
+}
+
+// refreshLoop 在令牌过期前后台续期，使请求在正常情况下无需等待令牌获取
+func (c *Client) refreshLoop(slot *tokenSlot) {
+	failures := 0
+	for {
+		timer := time.NewTimer(c.nextRefreshDelay(slot, failures))
+		select {
+		case <-c.stop:
+			timer.Stop()
+			return
+		case <-slot.retire:
+			timer.Stop()
+		case <-timer.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		_, err := c.refreshSlot(ctx, slot)
+		cancel()
+		if err != nil {
+			failures++
+			log.Printf("后台刷新认证信息失败 (令牌 %d, 第 %d 次): %v", slot.index, failures, err)
+			continue
+		}
+		failures = 0
+	}
+}
+
+// nextRefreshDelay 计算下次后台刷新的等待时间
+// 成功时在剩余有效期的 80% 处刷新；失败时按指数退避并加入随机抖动，避免多个实例同时重试
+func (c *Client) nextRefreshDelay(slot *tokenSlot, failures int) time.Duration {
+	if failures > 0 {
+		backoff := refreshBackoffMax
+		if failures < 7 {
+			backoff = min(refreshBackoffMin<<(failures-1), refreshBackoffMax)
+		}
+		return backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+	}
+
+	c.endpointMu.RLock()
+	expiry := slot.expiry
+	c.endpointMu.RUnlock()
+	if expiry.IsZero() {
+		return 0
+	}
+	return max(time.Until(expiry)*4/5, 0)
+}
+
+// tokenStats 返回令牌池状态
+func (c *Client) tokenStats() map[string]interface{} {
+	now := time.Now()
+	c.endpointMu.RLock()
+	valid := 0
+	tokens := make([]map[string]interface{}, 0, len(c.tokens))
+	for _, slot := range c.tokens {
+		status := map[string]interface{}{"valid": slot.valid(now)}
+		if slot.valid(now) {
+			valid++
+			status["expires_in"] = int64(slot.expiry.Sub(now).Seconds())
+		}
+		if !slot.lastUsed.IsZero() {
+			status["last_used"] = slot.lastUsed.Format(time.RFC3339)
+		}
+		tokens = append(tokens, status)
+	}
+	c.endpointMu.RUnlock()
+
+	return map[string]interface{}{
+		"size":     len(c.tokens),
+		"strategy": c.tokenStrategy,
+		"valid":    valid,
+		"retired":  atomic.LoadUint64(&c.tokensRetired),
+		"tokens":   tokens,
+	}
+}
+
+// Health 报告令牌池、区域状态和重试统计
+func (c *Client) Health() map[string]interface{} {
+	return map[string]interface{}{
+		"token_pool": c.tokenStats(),
+		"region":     c.regionHealth(),
+		"retry":      c.retryStats.snapshot(),
+	}
+}
+
+// Close 停止后台令牌刷新
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	return nil
+}
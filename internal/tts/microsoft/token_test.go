@@ -0,0 +1,237 @@
+package microsoft
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tts/internal/config"
+)
+
+// tokenServer 是计数的令牌端点，每次签发序号不同的令牌；gate 不为 nil 时请求在其关闭前阻塞
+type tokenServer struct {
+	*httptest.Server
+	calls atomic.Int32
+	gate  chan struct{}
+}
+
+func newTokenServer(t *testing.T, gate chan struct{}) *tokenServer {
+	t.Helper()
+	s := &tokenServer{gate: gate}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := s.calls.Add(1)
+		if s.gate != nil {
+			<-s.gate
+		}
+		encode := base64.RawURLEncoding.EncodeToString
+		payload, _ := json.Marshal(map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()})
+		token := encode([]byte(`{"alg":"none"}`)) + "." + encode(payload) + "." + encode([]byte(fmt.Sprint(n)))
+		json.NewEncoder(w).Encode(map[string]interface{}{"r": "eastus", "t": token})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newTokenClient 创建只使用令牌池的客户端
+func newTokenClient(t *testing.T, endpointURL string, size int) *Client {
+	t.Helper()
+	c := &Client{
+		tokens:        newTokenSlots(size),
+		tokenStrategy: config.TokenStrategyRoundRobin,
+		endpointURL:   endpointURL,
+		stop:          make(chan struct{}),
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// getEndpoints 并发调用 n 次 getEndpoint，返回各自取得的令牌
+func getEndpoints(t *testing.T, c *Client, n int) []string {
+	t.Helper()
+	tokens, err := fetchEndpoints(c, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+func fetchEndpoints(c *Client, n int) ([]string, error) {
+	tokens := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			endpoint, err := c.getEndpoint(context.Background())
+			if err == nil {
+				tokens[i] = endpoint["t"].(string)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	return tokens, errors.Join(errs...)
+}
+
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGetEndpointSingleFlight(t *testing.T) {
+	gate := make(chan struct{})
+	server := newTokenServer(t, gate)
+	c := newTokenClient(t, server.URL, 1)
+
+	// 令牌获取阻塞期间到达的调用方都等待同一次获取
+	const callers = 50
+	var tokens []string
+	var err error
+	done := make(chan struct{})
+	go func() {
+		tokens, err = fetchEndpoints(c, callers)
+		close(done)
+	}()
+	waitUntil(t, "the token request", func() bool { return server.calls.Load() == 1 })
+	time.Sleep(20 * time.Millisecond)
+	close(gate)
+
+	<-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, token := range tokens {
+		if token != tokens[0] {
+			t.Fatalf("caller %d got a different token", i)
+		}
+	}
+	if got := server.calls.Load(); got != 1 {
+		t.Fatalf("token requests = %d, want 1", got)
+	}
+
+	// 令牌有效期内不再请求上游
+	getEndpoints(t, c, callers)
+	if got := server.calls.Load(); got != 1 {
+		t.Fatalf("token requests = %d, want 1", got)
+	}
+}
+
+func TestGetEndpointCallerCancel(t *testing.T) {
+	gate := make(chan struct{})
+	server := newTokenServer(t, gate)
+	c := newTokenClient(t, server.URL, 1)
+
+	// 等待者取消不会中断令牌获取，之后的调用方直接使用其结果
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.getEndpoint(ctx)
+		errc <- err
+	}()
+	waitUntil(t, "the token request", func() bool { return server.calls.Load() == 1 })
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("err = %v, want %v", err, context.Canceled)
+	}
+
+	close(gate)
+	getEndpoints(t, c, 10)
+	if got := server.calls.Load(); got != 1 {
+		t.Fatalf("token requests = %d, want 1", got)
+	}
+}
+
+func TestInvalidateEndpoint(t *testing.T) {
+	server := newTokenServer(t, nil)
+	c := newTokenClient(t, server.URL, 1)
+
+	stale := getEndpoints(t, c, 1)[0]
+
+	// 多个请求同时报告同一个失效令牌时只停用一次
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.invalidateEndpoint(map[string]interface{}{"t": stale})
+		}()
+	}
+	wg.Wait()
+	if got := atomic.LoadUint64(&c.tokensRetired); got != 1 {
+		t.Fatalf("retired = %d, want 1", got)
+	}
+	select {
+	case <-c.tokens[0].retire:
+	default:
+		t.Fatal("background refresh was not notified")
+	}
+
+	// 停用后的调用方共同等待一次新令牌获取
+	tokens := getEndpoints(t, c, 20)
+	for _, token := range tokens {
+		if token == stale || token != tokens[0] {
+			t.Fatalf("got token %q, want one fresh token", token)
+		}
+	}
+	if got := server.calls.Load(); got != 2 {
+		t.Fatalf("token requests = %d, want 2", got)
+	}
+
+	// 已被替换的令牌不会作废新令牌
+	c.invalidateEndpoint(map[string]interface{}{"t": stale})
+	if got := atomic.LoadUint64(&c.tokensRetired); got != 1 {
+		t.Fatalf("retired = %d, want 1", got)
+	}
+	if token := getEndpoints(t, c, 1)[0]; token != tokens[0] {
+		t.Fatal("fresh token was replaced")
+	}
+}
+
+func TestRefreshLoopReplacesRetiredToken(t *testing.T) {
+	server := newTokenServer(t, nil)
+	c := newTokenClient(t, server.URL, 2)
+
+	// 后台刷新填满令牌池
+	for _, slot := range c.tokens {
+		go c.refreshLoop(slot)
+	}
+	slotToken := func(slot *tokenSlot) string {
+		c.endpointMu.RLock()
+		defer c.endpointMu.RUnlock()
+		if !slot.valid(time.Now()) {
+			return ""
+		}
+		return slot.endpoint["t"].(string)
+	}
+	waitUntil(t, "the pool to fill", func() bool {
+		return slotToken(c.tokens[0]) != "" && slotToken(c.tokens[1]) != ""
+	})
+	stale, other := slotToken(c.tokens[0]), slotToken(c.tokens[1])
+
+	// 停用的令牌由后台立即补充，另一个位置不受影响
+	c.invalidateEndpoint(map[string]interface{}{"t": stale})
+	waitUntil(t, "the retired token to be replaced", func() bool {
+		token := slotToken(c.tokens[0])
+		return token != "" && token != stale
+	})
+	if got := slotToken(c.tokens[1]); got != other {
+		t.Fatal("the other slot was refreshed")
+	}
+	if got := server.calls.Load(); got != 3 {
+		t.Fatalf("token requests = %d, want 3", got)
+	}
+}
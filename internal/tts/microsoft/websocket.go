@@ -0,0 +1,246 @@
+package microsoft
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+
+	"tts/internal/models"
+	"tts/internal/tts"
+)
+
+const (
+	websocketEndpoint = "wss://%s.tts.speech.microsoft.com/cognitiveservices/websocket/v1"
+
+	// speech.config 中开启所有元数据事件
+	speechConfigTemplate = `{"context":{"synthesis":{"audio":{"metadataoptions":{"bookmarkEnabled":"true","sentenceBoundaryEnabled":"true","wordBoundaryEnabled":"true","visemeEnabled":"true","punctuationBoundaryEnabled":"false"},"outputFormat":"%s"}}}}`
+)
+
+// wsFrame 是一条 WebSocket 消息及其帧类型
+type wsFrame struct {
+	payloadType byte
+	data        []byte
+}
+
+// frameCodec 收发时保留帧类型，便于区分文本消息和音频数据
+var frameCodec = websocket.Codec{
+	Marshal: func(v interface{}) ([]byte, byte, error) {
+		f := v.(wsFrame)
+		return f.data, f.payloadType, nil
+	},
+	Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+		f := v.(*wsFrame)
+		f.payloadType = payloadType
+		f.data = data
+		return nil
+	},
+}
+
+// wsMetadata 是 audio.metadata 消息的内容
+type wsMetadata struct {
+	Metadata []struct {
+		Type string `json:"Type"`
+		Data struct {
+			Offset   int64  `json:"Offset"`   // 100ns 为单位
+			Duration int64  `json:"Duration"` // 100ns 为单位
+			Bookmark string `json:"Bookmark"`
+			VisemeID int    `json:"VisemeId"`
+			Text     struct {
+				Text string `json:"Text"`
+			} `json:"text"`
+		} `json:"Data"`
+	} `json:"Metadata"`
+}
+
+// SynthesizeWithEvents 通过 WebSocket 合成协议合成语音，同时返回边界、书签和口型事件
+func (c *Client) SynthesizeWithEvents(ctx context.Context, req models.TTSRequest) (*models.TTSEventsResponse, error) {
+	return c.synthesizeWithEventsRetry(ctx, req, false)
+}
+
+// synthesizeWithEventsRetry 连接失败时刷新认证信息，退避后重试一次；等待期间调用方取消则立即返回
+func (c *Client) synthesizeWithEventsRetry(ctx context.Context, req models.TTSRequest, retried bool) (*models.TTSEventsResponse, error) {
+	ssml, err := c.buildSSML(req)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := c.getEndpoint(ctx)
+	if err != nil {
+		return nil, tts.Unavailable(err)
+	}
+
+	region := c.regionFor(endpoint)
+	conn, err := c.dialWebSocket(ctx, endpoint, region)
+	if err != nil {
+		if !retried {
+			wait := c.retry.backoff(1, 0)
+			log.Printf("WebSocket 连接失败，刷新认证信息后 %v 重试一次: %v", wait, err)
+			c.markRegionFailure(region, err)
+			c.invalidateEndpoint(endpoint)
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+			return c.synthesizeWithEventsRetry(ctx, req, true)
+		}
+		return nil, tts.Unavailable(fmt.Errorf("WebSocket 连接失败: %w", err))
+	}
+	defer conn.Close()
+
+	if c.httpClient.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(c.httpClient.Timeout))
+	}
+
+	// 调用方取消时关闭连接以中断读取
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	requestID := strings.ReplaceAll(uuid.New().String(), "-", "")
+	timestamp := wsTimestamp()
+
+	speechConfig := fmt.Sprintf("X-Timestamp:%s\r\nContent-Type:application/json; charset=utf-8\r\nPath:speech.config\r\n\r\n%s",
+		timestamp, fmt.Sprintf(speechConfigTemplate, c.outputFormat(req)))
+	if err := frameCodec.Send(conn, wsFrame{websocket.TextFrame, []byte(speechConfig)}); err != nil {
+		return nil, err
+	}
+
+	ssmlMessage := fmt.Sprintf("X-RequestId:%s\r\nContent-Type:application/ssml+xml\r\nX-Timestamp:%s\r\nPath:ssml\r\n\r\n%s",
+		requestID, timestamp, ssml)
+	if err := frameCodec.Send(conn, wsFrame{websocket.TextFrame, []byte(ssmlMessage)}); err != nil {
+		return nil, err
+	}
+
+	var audioBuf bytes.Buffer
+	var events []models.SynthesisEvent
+	for {
+		var frame wsFrame
+		if err := frameCodec.Receive(conn, &frame); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, tts.Unavailable(fmt.Errorf("读取 WebSocket 消息失败: %w", err))
+		}
+
+		switch frame.payloadType {
+		case websocket.BinaryFrame:
+			// 二进制消息：2 字节大端头长度 + 文本头 + 音频数据
+			if len(frame.data) < 2 {
+				continue
+			}
+			headerLen := int(binary.BigEndian.Uint16(frame.data[:2]))
+			if len(frame.data) < 2+headerLen {
+				continue
+			}
+			headers, _ := parseWSHeaders(string(frame.data[2 : 2+headerLen]))
+			if headers["Path"] == "audio" {
+				audioBuf.Write(frame.data[2+headerLen:])
+			}
+		case websocket.TextFrame:
+			headers, body := parseWSHeaders(string(frame.data))
+			switch headers["Path"] {
+			case "audio.metadata":
+				parsed, err := parseWSMetadata(body)
+				if err != nil {
+					log.Printf("解析合成事件失败: %v", err)
+					continue
+				}
+				events = append(events, parsed...)
+			case "turn.end":
+				return &models.TTSEventsResponse{
+					TTSResponse: models.TTSResponse{
+						AudioContent: audioBuf.Bytes(),
+						ContentType:  contentTypeFromFormat(c.outputFormat(req)),
+						CacheHit:     false,
+					},
+					Events: events,
+				}, nil
+			}
+		}
+	}
+}
+
+// dialWebSocket 使用端点令牌建立到指定区域的 WebSocket 连接
+func (c *Client) dialWebSocket(ctx context.Context, endpoint map[string]interface{}, region string) (*websocket.Conn, error) {
+	connectionID := strings.ReplaceAll(uuid.New().String(), "-", "")
+	wsURL := fmt.Sprintf(c.websocketURL, region)
+
+	u, err := url.Parse(wsURL)
+	if err != nil {
+		return nil, err
+	}
+	origin := "https://" + u.Host
+	if u.Scheme == "ws" {
+		origin = "http://" + u.Host
+	}
+
+	wsConfig, err := websocket.NewConfig(wsURL, origin)
+	if err != nil {
+		return nil, err
+	}
+	wsConfig.Header.Set("Authorization", endpoint["t"].(string))
+	wsConfig.Header.Set("X-ConnectionId", connectionID)
+	wsConfig.Header.Set("User-Agent", userAgent)
+
+	return wsConfig.DialContext(ctx)
+}
+
+// parseWSHeaders 拆分 "Key:Value\r\n...\r\n\r\nbody" 形式的消息
+func parseWSHeaders(message string) (map[string]string, string) {
+	head, body, _ := strings.Cut(message, "\r\n\r\n")
+	headers := make(map[string]string)
+	for _, line := range strings.Split(head, "\r\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return headers, body
+}
+
+// parseWSMetadata 将 audio.metadata 消息转换为合成事件
+func parseWSMetadata(body string) ([]models.SynthesisEvent, error) {
+	var metadata wsMetadata
+	if err := json.Unmarshal([]byte(body), &metadata); err != nil {
+		return nil, err
+	}
+
+	var events []models.SynthesisEvent
+	for _, m := range metadata.Metadata {
+		event := models.SynthesisEvent{
+			Type:     m.Type,
+			Offset:   time.Duration(m.Data.Offset * 100),
+			Duration: time.Duration(m.Data.Duration * 100),
+		}
+		switch m.Type {
+		case models.EventWordBoundary, models.EventSentenceBoundary:
+			event.Text = m.Data.Text.Text
+		case models.EventBookmark:
+			event.Bookmark = m.Data.Bookmark
+		case models.EventViseme:
+			event.VisemeID = m.Data.VisemeID
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// wsTimestamp 返回协议要求的 JavaScript Date 风格时间戳
+func wsTimestamp() string {
+	return time.Now().UTC().Format("Mon Jan 02 2006 15:04:05 GMT-0700 (Coordinated Universal Time)")
+}
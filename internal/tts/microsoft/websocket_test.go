@@ -0,0 +1,77 @@
+package microsoft
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tts/internal/config"
+	"tts/internal/models"
+	"tts/internal/tts"
+)
+
+// newWebSocketClient 创建 WebSocket 握手总是失败的客户端，返回握手次数
+func newWebSocketClient(t *testing.T, initialBackoff int) (*Client, *atomic.Int32) {
+	t.Helper()
+	var dials atomic.Int32
+	ws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dials.Add(1)
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(ws.Close)
+
+	c := NewClient(&config.Config{
+		TTS: config.TTSConfig{
+			DefaultVoice:  "zh-CN-XiaoxiaoNeural",
+			DefaultFormat: "audio-24khz-48kbitrate-mono-mp3",
+			MaxTextLength: 1000,
+			EndpointURL:   newTokenServer(t, nil).URL,
+			WebSocketURL:  "ws" + strings.TrimPrefix(ws.URL, "http") + "/%s",
+			Retry:         config.RetryConfig{InitialBackoff: initialBackoff, MaxBackoff: initialBackoff},
+		},
+	})
+	t.Cleanup(func() { c.Close() })
+	return c, &dials
+}
+
+func TestWebSocketRedial(t *testing.T) {
+	c, dials := newWebSocketClient(t, 1)
+
+	_, err := c.SynthesizeWithEvents(context.Background(), models.TTSRequest{Text: "你好"})
+	if !tts.IsUnavailable(err) {
+		t.Fatalf("err = %v, want an unavailable error", err)
+	}
+	if got := dials.Load(); got != 2 {
+		t.Fatalf("dials = %d, want 2", got)
+	}
+}
+
+func TestWebSocketRedialBackoffCancel(t *testing.T) {
+	// 退避至少 30 秒，取消后应立即返回
+	c, dials := newWebSocketClient(t, 60000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.SynthesizeWithEvents(ctx, models.TTSRequest{Text: "你好"})
+		errc <- err
+	}()
+	waitUntil(t, "the first dial", func() bool { return dials.Load() == 1 })
+	cancel()
+
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Fatalf("err = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("backoff did not stop on cancel")
+	}
+	if got := dials.Load(); got != 1 {
+		t.Fatalf("dials = %d, want 1", got)
+	}
+}
@@ -0,0 +1,109 @@
+package tts
+
+import (
+	"fmt"
+	"time"
+
+	"tts/internal/config"
+)
+
+// Factory 根据配置创建一个TTS后端
+type Factory func(cfg *config.Config, provider config.ProviderConfig) (Service, error)
+
+// Registry 保存后端类型到构造函数的映射
+type Registry struct {
+	factories map[string]Factory
+}
+
+// NewRegistry 创建一个空的后端注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: make(map[string]Factory),
+	}
+}
+
+// Register 注册一种后端类型
+func (r *Registry) Register(typ string, factory Factory) {
+	r.factories[typ] = factory
+}
+
+// Build 按配置创建所有后端，并返回按请求路由的 Router
+func (r *Registry) Build(cfg *config.Config) (*Router, error) {
+	providers := cfg.ProviderList()
+	router := &Router{
+		byName: make(map[string]*provider, len(providers)),
+	}
+
+	for _, pc := range providers {
+		if pc.Name == "" {
+			return nil, fmt.Errorf("后端缺少名称 (type: %s)", pc.Type)
+		}
+		if _, exists := router.byName[pc.Name]; exists {
+			return nil, fmt.Errorf("后端名称重复: %s", pc.Name)
+		}
+
+		factory, ok := r.factories[pc.Type]
+		if !ok {
+			return nil, fmt.Errorf("未知的后端类型: %s (provider: %s)", pc.Type, pc.Name)
+		}
+
+		service, err := factory(cfg, pc)
+		if err != nil {
+			return nil, fmt.Errorf("创建后端 %s 失败: %w", pc.Name, err)
+		}
+
+		p := &provider{
+			name:          pc.Name,
+			voicePrefixes: pc.VoicePrefixes,
+			service:       service,
+		}
+		router.providers = append(router.providers, p)
+		router.byName[pc.Name] = p
+
+		if pc.Default && router.defaultProvider == nil {
+			router.defaultProvider = p
+		}
+	}
+
+	if len(router.providers) == 0 {
+		return nil, fmt.Errorf("未配置任何后端")
+	}
+
+	// 每个后端一个熔断器，为所有后端包装故障转移链（未配置 fallbacks 时只有自身），
+	// 链中成员均使用未包装的原始后端，同一后端出现在多条链中时共用熔断器
+	raw := make(map[string]Service, len(router.providers))
+	breakers := make(map[string]*CircuitBreaker, len(router.providers))
+	for _, p := range router.providers {
+		raw[p.name] = p.service
+		breakers[p.name] = NewCircuitBreaker(cfg.CircuitBreaker.FailureThreshold,
+			time.Duration(cfg.CircuitBreaker.OpenTimeout)*time.Second)
+	}
+	defaultVoices := make(map[string]string, len(providers))
+	for _, pc := range providers {
+		defaultVoices[pc.Name] = pc.DefaultVoice
+	}
+	for _, pc := range providers {
+		names := []string{pc.Name}
+		services := []Service{raw[pc.Name]}
+		voices := []string{pc.DefaultVoice}
+		chain := []*CircuitBreaker{breakers[pc.Name]}
+		for _, name := range pc.Fallbacks {
+			service, ok := raw[name]
+			if !ok {
+				return nil, fmt.Errorf("后端 %s 的 fallbacks 引用了未知后端: %s", pc.Name, name)
+			}
+			names = append(names, name)
+			services = append(services, service)
+			voices = append(voices, defaultVoices[name])
+			chain = append(chain, breakers[name])
+		}
+		p := router.byName[pc.Name]
+		p.service = NewFailover(names, services, voices, chain)
+		p.breaker = breakers[pc.Name]
+	}
+	if router.defaultProvider == nil {
+		router.defaultProvider = router.providers[0]
+	}
+
+	return router, nil
+}
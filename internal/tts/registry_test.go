@@ -0,0 +1,112 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"tts/internal/config"
+	"tts/internal/models"
+)
+
+// buildStubRouter 用 stub 类型的后端按 providers 创建 Router，返回各后端的原始服务
+func buildStubRouter(t *testing.T, providers []config.ProviderConfig) (*Router, map[string]*stubService) {
+	t.Helper()
+	stubs := make(map[string]*stubService)
+	registry := NewRegistry()
+	registry.Register("stub", func(cfg *config.Config, pc config.ProviderConfig) (Service, error) {
+		s := &stubService{}
+		stubs[pc.Name] = s
+		return s, nil
+	})
+
+	router, err := registry.Build(&config.Config{
+		Providers:      providers,
+		CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: 3600},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router, stubs
+}
+
+func breakerState(t *testing.T, r *Router, name string) string {
+	t.Helper()
+	status, ok := r.Health()["providers"].(map[string]interface{})[name].(map[string]interface{})
+	if !ok {
+		t.Fatalf("no health for provider %s", name)
+	}
+	breaker, ok := status["breaker"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("no breaker state for provider %s: %v", name, status)
+	}
+	return breaker["state"].(string)
+}
+
+func TestRegistryBreakers(t *testing.T) {
+	router, stubs := buildStubRouter(t, []config.ProviderConfig{
+		{Name: "a", Type: "stub", Default: true, Fallbacks: []string{"shared"}},
+		{Name: "b", Type: "stub", Fallbacks: []string{"shared"}},
+		{Name: "shared", Type: "stub"},
+	})
+
+	// 每个后端都有熔断器，包括没有配置 fallbacks 的后端
+	for _, name := range router.Providers() {
+		if got := breakerState(t, router, name); got != BreakerClosed {
+			t.Fatalf("%s breaker = %s, want %s", name, got, BreakerClosed)
+		}
+	}
+
+	// 同一后端在各条链中共用一个熔断器
+	shared := router.byName["shared"].breaker
+	for _, name := range []string{"a", "b"} {
+		member := router.byName[name].service.(*Failover).members[1]
+		if member.breaker != shared {
+			t.Fatalf("chain %s uses a separate breaker for shared", name)
+		}
+	}
+
+	// 经链 a 熔断的共用后端，在链 b 和直接请求中同样被跳过
+	stubs["a"].err = Unavailable(errors.New("503"))
+	stubs["b"].err = Unavailable(errors.New("503"))
+	stubs["shared"].err = Unavailable(errors.New("503"))
+	if _, err := router.SynthesizeSpeech(context.Background(), models.TTSRequest{Provider: "a"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := breakerState(t, router, "shared"); got != BreakerOpen {
+		t.Fatalf("shared breaker = %s, want %s", got, BreakerOpen)
+	}
+	if _, err := router.SynthesizeSpeech(context.Background(), models.TTSRequest{Provider: "b"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := router.SynthesizeSpeech(context.Background(), models.TTSRequest{Provider: "shared"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if stubs["shared"].calls != 1 {
+		t.Fatalf("shared calls = %d, want 1", stubs["shared"].calls)
+	}
+}
+
+func TestRegistryBuildErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers []config.ProviderConfig
+	}{
+		{"missing name", []config.ProviderConfig{{Type: "stub"}}},
+		{"duplicate name", []config.ProviderConfig{{Name: "a", Type: "stub"}, {Name: "a", Type: "stub"}}},
+		{"unknown type", []config.ProviderConfig{{Name: "a", Type: "unknown"}}},
+		{"unknown fallback", []config.ProviderConfig{{Name: "a", Type: "stub", Fallbacks: []string{"b"}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.Register("stub", func(cfg *config.Config, pc config.ProviderConfig) (Service, error) {
+				return &stubService{}, nil
+			})
+			if _, err := registry.Build(&config.Config{Providers: tt.providers}); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package tts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"tts/internal/models"
+)
+
+// provider 是 Router 中的一个命名后端
+type provider struct {
+	name          string
+	voicePrefixes []string
+	service       Service
+	breaker       *CircuitBreaker // 该后端自身的熔断器，与引用它的故障转移链共用
+}
+
+// Router 是按请求选择后端的 Service 实现
+type Router struct {
+	providers       []*provider
+	byName          map[string]*provider
+	defaultProvider *provider
+
+	// 语音短名到后端的索引，由 ListVoices 维护
+	voiceIndex   map[string]*provider
+	voiceIndexMu sync.RWMutex
+}
+
+// Providers 返回所有后端名称，按配置顺序排列
+func (r *Router) Providers() []string {
+	names := make([]string, 0, len(r.providers))
+	for _, p := range r.providers {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// Provider 按名称返回后端
+func (r *Router) Provider(name string) (Service, bool) {
+	p, ok := r.byName[name]
+	if !ok {
+		return nil, false
+	}
+	return p.service, true
+}
+
+// resolve 为请求选择后端，并返回去掉后端前缀后的请求
+// 优先级：provider 字段 > "名称:语音" 前缀 > voice_prefixes > 语音列表索引 > 默认后端
+func (r *Router) resolve(req models.TTSRequest) (*provider, models.TTSRequest, error) {
+	if req.Provider != "" {
+		p, ok := r.byName[req.Provider]
+		if !ok {
+			return nil, req, fmt.Errorf("未知的后端: %s", req.Provider)
+		}
+		if name, voice, found := strings.Cut(req.Voice, ":"); found && name == p.name {
+			req.Voice = voice
+		}
+		return p, req, nil
+	}
+
+	if name, voice, found := strings.Cut(req.Voice, ":"); found {
+		if p, ok := r.byName[name]; ok {
+			req.Voice = voice
+			req.Provider = p.name
+			return p, req, nil
+		}
+	}
+
+	var matched *provider
+	matchedLen := 0
+	for _, p := range r.providers {
+		for _, prefix := range p.voicePrefixes {
+			if len(prefix) > matchedLen && strings.HasPrefix(req.Voice, prefix) {
+				matched = p
+				matchedLen = len(prefix)
+			}
+		}
+	}
+	if matched != nil {
+		req.Provider = matched.name
+		return matched, req, nil
+	}
+
+	r.voiceIndexMu.RLock()
+	p, ok := r.voiceIndex[req.Voice]
+	r.voiceIndexMu.RUnlock()
+	if ok {
+		req.Provider = p.name
+		return p, req, nil
+	}
+
+	req.Provider = r.defaultProvider.name
+	return r.defaultProvider, req, nil
+}
+
+// ListVoices 合并所有后端的语音列表，并标记所属后端
+func (r *Router) ListVoices(ctx context.Context, locale string) ([]models.Voice, error) {
+	var merged []models.Voice
+	var errs []error
+	index := make(map[string]*provider)
+
+	for _, p := range r.providers {
+		voices, err := p.service.ListVoices(ctx, locale)
+		if err != nil {
+			log.Printf("后端 %s 获取语音列表失败: %v", p.name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.name, err))
+			continue
+		}
+
+		for _, voice := range voices {
+			voice.Provider = p.name
+			merged = append(merged, voice)
+			if _, exists := index[voice.ShortName]; !exists {
+				index[voice.ShortName] = p
+			}
+		}
+	}
+
+	if len(errs) == len(r.providers) {
+		return nil, errors.Join(errs...)
+	}
+
+	if locale == "" && len(errs) == 0 {
+		r.voiceIndexMu.Lock()
+		r.voiceIndex = index
+		r.voiceIndexMu.Unlock()
+	}
+
+	return merged, nil
+}
+
+// SynthesizeSpeech 将请求转发到选中的后端
+func (r *Router) SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error) {
+	p, req, err := r.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	return p.service.SynthesizeSpeech(ctx, req)
+}
+
+// SynthesizeStream 将流式请求转发到选中的后端
+func (r *Router) SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error) {
+	p, req, err := r.resolve(req)
+	if err != nil {
+		return nil, "", err
+	}
+	return p.service.SynthesizeStream(ctx, req)
+}
+
+// SynthesizeWithEvents 将请求转发到选中的后端，后端需实现 EventSynthesizer
+func (r *Router) SynthesizeWithEvents(ctx context.Context, req models.TTSRequest) (*models.TTSEventsResponse, error) {
+	p, req, err := r.resolve(req)
+	if err != nil {
+		return nil, err
+	}
+	synthesizer, ok := p.service.(EventSynthesizer)
+	if !ok {
+		return nil, fmt.Errorf("后端 %s 不支持合成事件", p.name)
+	}
+	return synthesizer.SynthesizeWithEvents(ctx, req)
+}
+
+// WarmupVoicesCache 预热所有后端的语音列表，全部失败时返回错误
+func (r *Router) WarmupVoicesCache(ctx context.Context) error {
+	var errs []error
+	for _, p := range r.providers {
+		if err := p.service.WarmupVoicesCache(ctx); err != nil {
+			log.Printf("后端 %s 预热声音列表缓存失败: %v", p.name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.name, err))
+		}
+	}
+	if len(errs) == len(r.providers) {
+		return errors.Join(errs...)
+	}
+
+	// 建立语音索引，便于按语音短名路由
+	if _, err := r.ListVoices(ctx, ""); err != nil {
+		log.Printf("建立语音索引失败: %v", err)
+	}
+	return nil
+}
+
+// Health 汇总各后端的熔断器状态及实现了 HealthReporter 的后端状态
+func (r *Router) Health() map[string]interface{} {
+	providers := make(map[string]interface{}, len(r.providers))
+	for _, p := range r.providers {
+		var status map[string]interface{}
+		if reporter, ok := p.service.(HealthReporter); ok {
+			status = reporter.Health()
+		}
+		if status == nil {
+			status = map[string]interface{}{}
+		}
+		if p.breaker != nil {
+			status["breaker"] = p.breaker.Snapshot()
+		}
+		providers[p.name] = status
+	}
+	return map[string]interface{}{
+		"default":   r.defaultProvider.name,
+		"providers": providers,
+	}
+}
+
+// CatalogueStatus 汇总实现了 CatalogueReporter 的后端语音列表状态
+func (r *Router) CatalogueStatus() map[string]interface{} {
+	status := make(map[string]interface{}, len(r.providers))
+	for _, p := range r.providers {
+		if reporter, ok := p.service.(CatalogueReporter); ok {
+			status[p.name] = reporter.CatalogueStatus()
+		}
+	}
+	return status
+}
+
+// Close 关闭实现了 io.Closer 的后端，如停止后台令牌刷新
+func (r *Router) Close() error {
+	var errs []error
+	for _, p := range r.providers {
+		if closer, ok := p.service.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", p.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package tts
+
+import (
+	"testing"
+
+	"tts/internal/config"
+	"tts/internal/models"
+)
+
+func TestRouterResolve(t *testing.T) {
+	router, _ := buildStubRouter(t, []config.ProviderConfig{
+		{Name: "edge", Type: "stub", Default: true},
+		{Name: "azure", Type: "stub", VoicePrefixes: []string{"zh-"}},
+		{Name: "local", Type: "stub", VoicePrefixes: []string{"zh-CN-local"}},
+	})
+	router.voiceIndex = map[string]*provider{"en-US-Indexed": router.byName["local"]}
+
+	tests := []struct {
+		name         string
+		req          models.TTSRequest
+		wantProvider string
+		wantVoice    string
+		wantErr      bool
+	}{
+		{"explicit provider", models.TTSRequest{Provider: "edge", Voice: "zh-CN-XiaoxiaoNeural"}, "edge", "zh-CN-XiaoxiaoNeural", false},
+		{"explicit provider strips its own prefix", models.TTSRequest{Provider: "azure", Voice: "azure:en-US-Jenny"}, "azure", "en-US-Jenny", false},
+		{"explicit provider keeps other prefix", models.TTSRequest{Provider: "azure", Voice: "local:en-US-Jenny"}, "azure", "local:en-US-Jenny", false},
+		{"explicit provider beats voice prefix", models.TTSRequest{Provider: "local", Voice: "edge:zh-CN-XiaoxiaoNeural"}, "local", "edge:zh-CN-XiaoxiaoNeural", false},
+		{"unknown provider", models.TTSRequest{Provider: "missing", Voice: "zh-CN-XiaoxiaoNeural"}, "", "", true},
+		{"name prefix", models.TTSRequest{Voice: "edge:zh-CN-XiaoxiaoNeural"}, "edge", "zh-CN-XiaoxiaoNeural", false},
+		{"unknown name prefix falls through", models.TTSRequest{Voice: "other:voice"}, "edge", "other:voice", false},
+		{"voice prefix", models.TTSRequest{Voice: "zh-TW-HsiaoChenNeural"}, "azure", "zh-TW-HsiaoChenNeural", false},
+		{"longest voice prefix", models.TTSRequest{Voice: "zh-CN-local-1"}, "local", "zh-CN-local-1", false},
+		{"voice index", models.TTSRequest{Voice: "en-US-Indexed"}, "local", "en-US-Indexed", false},
+		{"default", models.TTSRequest{Voice: "en-US-JennyNeural"}, "edge", "en-US-JennyNeural", false},
+		{"empty voice uses default", models.TTSRequest{}, "edge", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, req, err := router.resolve(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if p.name != tt.wantProvider || req.Provider != tt.wantProvider {
+				t.Errorf("provider = %s (req %s), want %s", p.name, req.Provider, tt.wantProvider)
+			}
+			if req.Voice != tt.wantVoice {
+				t.Errorf("voice = %q, want %q", req.Voice, tt.wantVoice)
+			}
+		})
+	}
+}
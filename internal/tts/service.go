@@ -2,6 +2,7 @@ package tts
 
 import (
 	"context"
+	"io"
 	"tts/internal/models"
 )
 
@@ -13,6 +14,19 @@ type Service interface {
 	// SynthesizeSpeech 将文本转换为语音
 	SynthesizeSpeech(ctx context.Context, req models.TTSRequest) (*models.TTSResponse, error)
 
+	// SynthesizeStream 将文本转换为语音，边合成边返回音频流及其MIME类型，调用方负责关闭
+	SynthesizeStream(ctx context.Context, req models.TTSRequest) (io.ReadCloser, string, error)
+
 	// WarmupVoicesCache 预热声音列表缓存
 	WarmupVoicesCache(ctx context.Context) error
 }
+
+// EventSynthesizer 是可选接口，合成时同时返回词、句边界、书签和口型事件
+type EventSynthesizer interface {
+	SynthesizeWithEvents(ctx context.Context, req models.TTSRequest) (*models.TTSEventsResponse, error)
+}
+
+// CatalogueReporter 是可选接口，报告语音列表的来源（上游、状态文件快照或内置列表）和快照时间
+type CatalogueReporter interface {
+	CatalogueStatus() map[string]interface{}
+}
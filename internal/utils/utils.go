@@ -25,7 +25,8 @@ var (
 )
 
 const (
-	endpointURL          = "https://dev.microsofttranslator.com/apps/endpoint?api-version=1.0"
+	// DefaultEndpointURL 默认的令牌端点
+	DefaultEndpointURL   = "https://dev.microsofttranslator.com/apps/endpoint?api-version=1.0"
 	userAgent            = "okhttp/4.5.0"
 	clientVersion        = "4.0.530a 5fe1dc6c"
 	homeGeographicRegion = "zh-Hans-CN"
@@ -45,8 +46,11 @@ func generateUserID() string {
 	return string(result)
 }
 
-// GetEndpoint 获取语音合成服务的端点信息
-func GetEndpoint() (map[string]interface{}, error) {
+// GetEndpoint 获取语音合成服务的端点信息，endpointURL 为空时使用默认地址
+func GetEndpoint(endpointURL string) (map[string]interface{}, error) {
+	if endpointURL == "" {
+		endpointURL = DefaultEndpointURL
+	}
 	signature := Sign(endpointURL)
 	userId := generateUserID()
 	traceId := uuid.New().String()